@@ -0,0 +1,107 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/containernetworking/cni/pkg/conformance"
+	"github.com/containernetworking/cni/pkg/ns"
+)
+
+func main() {
+	passed, err := run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if !passed {
+		os.Exit(1)
+	}
+}
+
+func run() (bool, error) {
+	confFile := flag.String("config", "", "network configuration to test with, defaults to a minimal one for the plugin")
+	netnsPath := flag.String("netns", "", "network namespace for ADD and DEL, defaults to a new temporary one")
+	ifName := flag.String("ifname", "eth0", "interface name for ADD and DEL")
+	jsonOutput := flag.Bool("json", false, "print the findings as JSON")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] <plugin>\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	plugin, err := filepath.Abs(flag.Arg(0))
+	if err != nil {
+		return false, err
+	}
+
+	netconf := []byte(fmt.Sprintf(`{"name": "cni-conformance", "type": %q}`, filepath.Base(plugin)))
+	if *confFile != "" {
+		if netconf, err = ioutil.ReadFile(*confFile); err != nil {
+			return false, err
+		}
+	}
+
+	if *netnsPath == "" {
+		netns, err := ns.NewNS()
+		if err != nil {
+			return false, err
+		}
+		defer netns.Close()
+		*netnsPath = netns.Path()
+	}
+
+	r := &conformance.Runner{
+		Plugin:  plugin,
+		NetConf: netconf,
+		NetNS:   *netnsPath,
+		IfName:  *ifName,
+		Path:    os.Getenv("CNI_PATH"),
+	}
+	findings := r.Run()
+
+	if *jsonOutput {
+		data, err := json.MarshalIndent(findings, "", "    ")
+		if err != nil {
+			return false, err
+		}
+		fmt.Println(string(data))
+	} else {
+		for _, f := range findings {
+			if f.Message != "" {
+				fmt.Printf("%s  %s: %s\n", f.Status, f.Check, f.Message)
+			} else {
+				fmt.Printf("%s  %s\n", f.Status, f.Check)
+			}
+		}
+	}
+
+	for _, f := range findings {
+		if f.Status == conformance.StatusFail {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/containernetworking/cni/pkg/gc"
+	"github.com/containernetworking/cni/pkg/state"
+)
+
+func main() {
+	dataDir := flag.String("data-dir", "", "data dir of host-local, defaults to /var/lib/cni/networks")
+	dhcpSocket := flag.String("dhcp-socket", state.DefaultDHCPSocket, "socket of the dhcp daemon")
+	containersFile := flag.String("containers", "", "file listing the IDs of the containers known to the runtime, one per line, or - for stdin (required)")
+	minAge := flag.Duration("min-age", time.Minute, "leave the resources of containers attached less than min-age before the containers file was written alone")
+	dryRun := flag.Bool("dry-run", false, "print the orphans without removing them")
+	interval := flag.Duration("interval", 0, "keep running and collect every interval, re-reading the containers file")
+	jsonOutput := flag.Bool("json", false, "print the orphans as JSON")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s -containers <file>|- [flags]\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 0 || *containersFile == "" || (*interval != 0 && *containersFile == "-") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opts := gc.Options{
+		DataDir:    *dataDir,
+		DHCPSocket: *dhcpSocket,
+		DryRun:     *dryRun,
+	}
+	collect := func() bool {
+		clean, err := run(opts, *containersFile, *minAge, *jsonOutput)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return false
+		}
+		return clean
+	}
+
+	if *interval == 0 {
+		if !collect() {
+			os.Exit(1)
+		}
+		return
+	}
+	for {
+		collect()
+		time.Sleep(*interval)
+	}
+}
+
+// run collects once and returns whether all orphans were removed
+func run(opts gc.Options, containersFile string, minAge time.Duration, jsonOutput bool) (bool, error) {
+	containers, written, err := readContainers(containersFile)
+	if err != nil {
+		return false, err
+	}
+	opts.Containers = containers
+	opts.Cutoff = written.Add(-minAge)
+
+	orphans, err := gc.Collect(opts)
+	if err != nil {
+		return false, err
+	}
+
+	clean := true
+	for _, o := range orphans {
+		if o.Error != "" {
+			clean = false
+		}
+	}
+
+	if jsonOutput {
+		data, err := json.Marshal(orphans)
+		if err != nil {
+			return false, err
+		}
+		fmt.Println(string(data))
+		return clean, nil
+	}
+
+	verb := "removed"
+	if opts.DryRun {
+		verb = "would remove"
+	}
+	for _, o := range orphans {
+		if o.Error != "" {
+			fmt.Printf("failed to remove %s\n", o)
+		} else {
+			fmt.Printf("%s %s\n", verb, o)
+		}
+	}
+	return clean, nil
+}
+
+// readContainers returns the containers listed in path, and when they were
+// listed: the mtime of the file, or now for stdin
+func readContainers(path string) ([]string, time.Time, error) {
+	f, written := os.Stdin, time.Now()
+	if path != "-" {
+		var err error
+		if f, err = os.Open(path); err != nil {
+			return nil, time.Time{}, err
+		}
+		defer f.Close()
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		written = fi.ModTime()
+	}
+
+	containers := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if id := strings.TrimSpace(scanner.Text()); id != "" {
+			containers = append(containers, id)
+		}
+	}
+	return containers, written, scanner.Err()
+}
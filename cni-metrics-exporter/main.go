@@ -0,0 +1,77 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/containernetworking/cni/pkg/metrics"
+	"github.com/containernetworking/cni/pkg/tlsconfig"
+)
+
+func main() {
+	listen := flag.String("listen", ":9275", "address to serve /metrics on")
+	dir := flag.String("dir", metrics.DefaultDir, "directory the plugins record their metrics in")
+	tlsConfigFile := flag.String("tls-config", "", "file holding the TLS configuration, metrics are served over TLS if set")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags]\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// plugins only record metrics once the directory exists
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		log.Fatalf("failed to create %s: %v", *dir, err)
+	}
+
+	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		files, err := metrics.ReadDir(*dir)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := metrics.WritePrometheus(w, files); err != nil {
+			log.Printf("failed to write metrics: %v", err)
+		}
+	})
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *tlsConfigFile != "" {
+		c, err := tlsconfig.Load(*tlsConfigFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cfg, err := c.ServerConfig()
+		if err != nil {
+			log.Fatal(err)
+		}
+		l = tls.NewListener(l, cfg)
+	}
+	log.Fatal(http.Serve(l, nil))
+}
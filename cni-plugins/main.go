@@ -0,0 +1,81 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// cni-plugins is a single binary holding the plugins, busybox-style.
+// It runs the plugin it is invoked as, so a node can install it once and
+// link it under the name of each plugin, or the one given with -plugin.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	dhcp "github.com/containernetworking/cni/plugins/ipam/dhcp/plugin"
+	hostlocal "github.com/containernetworking/cni/plugins/ipam/host-local/plugin"
+	static "github.com/containernetworking/cni/plugins/ipam/static/plugin"
+	bridge "github.com/containernetworking/cni/plugins/main/bridge/plugin"
+	ipvlan "github.com/containernetworking/cni/plugins/main/ipvlan/plugin"
+	loopback "github.com/containernetworking/cni/plugins/main/loopback/plugin"
+	macvlan "github.com/containernetworking/cni/plugins/main/macvlan/plugin"
+	ptp "github.com/containernetworking/cni/plugins/main/ptp/plugin"
+	chaos "github.com/containernetworking/cni/plugins/meta/chaos/plugin"
+	flannel "github.com/containernetworking/cni/plugins/meta/flannel/plugin"
+	tuning "github.com/containernetworking/cni/plugins/meta/tuning/plugin"
+)
+
+// plugins maps the name of each plugin to its Main, which its own binary,
+// a stub main, runs as well
+var plugins = map[string]func(){
+	"bridge":     bridge.Main,
+	"chaos":      chaos.Main,
+	"dhcp":       dhcp.Main,
+	"flannel":    flannel.Main,
+	"host-local": hostlocal.Main,
+	"ipvlan":     ipvlan.Main,
+	"loopback":   loopback.Main,
+	"macvlan":    macvlan.Main,
+	"ptp":        ptp.Main,
+	"static":     static.Main,
+	"tuning":     tuning.Main,
+}
+
+func main() {
+	self := filepath.Base(os.Args[0])
+	name := self
+	if len(os.Args) > 2 && os.Args[1] == "-plugin" {
+		name = os.Args[2]
+		// the plugin sees the arguments it would as its own binary
+		os.Args = append([]string{name}, os.Args[3:]...)
+	}
+
+	run, ok := plugins[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "usage: link %s as one of its plugins or run %s -plugin <name>\n", self, self)
+		fmt.Fprintf(os.Stderr, "plugins: %s\n", strings.Join(names(), ", "))
+		os.Exit(2)
+	}
+	run()
+}
+
+func names() []string {
+	names := []string{}
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,58 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/containernetworking/cni/pkg/scaffold"
+)
+
+func usage() {
+	exe := filepath.Base(os.Args[0])
+	fmt.Fprintf(os.Stderr, "usage: %s new [-dir <dir>] <name>\n", exe)
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "new" {
+		usage()
+	}
+
+	flags := flag.NewFlagSet("new", flag.ExitOnError)
+	dir := flags.String("dir", "", "directory to write the plugin to, defaults to ./<name>")
+	flags.Usage = usage
+	flags.Parse(os.Args[2:])
+	if flags.NArg() != 1 {
+		usage()
+	}
+	name := flags.Arg(0)
+	if *dir == "" {
+		*dir = name
+	}
+
+	files, err := scaffold.Generate(*dir, name, time.Now().Year())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	for _, f := range files {
+		fmt.Println(filepath.Join(*dir, f))
+	}
+}
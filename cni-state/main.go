@@ -0,0 +1,136 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/containernetworking/cni/pkg/state"
+)
+
+func main() {
+	consistent, err := run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if !consistent {
+		os.Exit(1)
+	}
+}
+
+func run() (bool, error) {
+	dataDir := flag.String("data-dir", "", "data dir of host-local, defaults to /var/lib/cni/networks")
+	dhcpSocket := flag.String("dhcp-socket", state.DefaultDHCPSocket, "socket of the dhcp daemon")
+	containersFile := flag.String("containers", "", "file listing the IDs of the containers known to the runtime, one per line, or - for stdin")
+	jsonOutput := flag.Bool("json", false, "print the state as JSON")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags]\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opts := state.Options{
+		DataDir:    *dataDir,
+		DHCPSocket: *dhcpSocket,
+	}
+	if *containersFile != "" {
+		containers, err := readContainers(*containersFile)
+		if err != nil {
+			return false, err
+		}
+		opts.Containers = containers
+	}
+
+	s, err := state.Inspect(opts)
+	if err != nil {
+		return false, err
+	}
+
+	if *jsonOutput {
+		data, err := json.MarshalIndent(s, "", "    ")
+		if err != nil {
+			return false, err
+		}
+		fmt.Println(string(data))
+	} else {
+		printState(os.Stdout, s)
+	}
+	return len(s.Problems) == 0, nil
+}
+
+func readContainers(path string) ([]string, error) {
+	f := os.Stdin
+	if path != "-" {
+		var err error
+		if f, err = os.Open(path); err != nil {
+			return nil, err
+		}
+		defer f.Close()
+	}
+
+	containers := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if id := strings.TrimSpace(scanner.Text()); id != "" {
+			containers = append(containers, id)
+		}
+	}
+	return containers, scanner.Err()
+}
+
+func printState(out io.Writer, s *state.State) {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "host-local allocations:")
+	fmt.Fprintln(w, "  NETWORK\tPOOL\tIP\tCONTAINER")
+	for _, a := range s.Allocations {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", a.Network, a.Pool, a.IP, a.ContainerID)
+	}
+	w.Flush()
+
+	fmt.Fprintln(out)
+	if s.Leases == nil {
+		fmt.Fprintln(out, "dhcp leases: daemon not running")
+	} else {
+		fmt.Fprintln(w, "dhcp leases:")
+		fmt.Fprintln(w, "  NETWORK\tIP\tCONTAINER\tNETNS\tIFNAME")
+		for _, l := range s.Leases {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", l.Network, l.IP, l.ContainerID, l.Netns, l.IfName)
+		}
+		w.Flush()
+	}
+
+	fmt.Fprintln(out)
+	if len(s.Problems) == 0 {
+		fmt.Fprintln(out, "no problems found")
+		return
+	}
+	fmt.Fprintln(out, "problems:")
+	for _, p := range s.Problems {
+		fmt.Fprintf(out, "  %s\n", p)
+	}
+}
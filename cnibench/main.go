@@ -0,0 +1,112 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/bench"
+	"github.com/containernetworking/cni/pkg/ns"
+)
+
+const (
+	EnvCNIPath = "CNI_PATH"
+	EnvNetDir  = "NETCONFPATH"
+
+	DefaultNetDir = "/etc/cni/net.d"
+)
+
+func main() {
+	namespaces := flag.Int("namespaces", 10, "number of network namespaces to attach and detach")
+	cycles := flag.Int("cycles", 10, "ADD/DEL cycles per namespace")
+	concurrency := flag.Int("concurrency", 1, "number of namespaces worked on at the same time")
+	jsonOutput := flag.Bool("json", false, "print the report as JSON")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] <net>\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), *namespaces, *cycles, *concurrency, *jsonOutput); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(name string, namespaces, cycles, concurrency int, jsonOutput bool) error {
+	netdir := os.Getenv(EnvNetDir)
+	if netdir == "" {
+		netdir = DefaultNetDir
+	}
+	netconf, err := libcni.LoadConf(netdir, name)
+	if err != nil {
+		return err
+	}
+
+	cninet := &libcni.CNIConfig{
+		Path: filepath.SplitList(os.Getenv(EnvCNIPath)),
+	}
+
+	opts := bench.Options{
+		Cycles:      cycles,
+		Concurrency: concurrency,
+	}
+	for i := 0; i < namespaces; i++ {
+		netns, err := ns.NewNS()
+		if err != nil {
+			return err
+		}
+		defer netns.Close()
+		opts.Namespaces = append(opts.Namespaces, netns.Path())
+	}
+
+	report, err := bench.Run(cninet, netconf, opts)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		data, err := json.MarshalIndent(report, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("%d namespaces, %d cycles each, concurrency %d\n", namespaces, cycles, concurrency)
+	for _, op := range []struct {
+		name  string
+		stats bench.Stats
+	}{{"ADD", report.Add}, {"DEL", report.Del}} {
+		s := op.stats
+		fmt.Printf("%s  n=%d min=%v mean=%v p50=%v p90=%v p99=%v max=%v\n",
+			op.name, s.Count, s.Min, s.Mean, s.P50, s.P90, s.P99, s.Max)
+	}
+	fmt.Printf("%.1f cycles/s over %v\n", report.Throughput, report.Duration)
+	if report.Errors > 0 {
+		fmt.Printf("%d operations failed, first error: %s\n", report.Errors, report.FirstError)
+	}
+	return nil
+}
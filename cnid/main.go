@@ -0,0 +1,151 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/cnid"
+	"github.com/containernetworking/cni/pkg/tlsconfig"
+	"github.com/coreos/go-systemd/activation"
+)
+
+const (
+	EnvCNIPath = "CNI_PATH"
+	EnvNetDir  = "NETCONFPATH"
+
+	DefaultNetDir     = "/etc/cni/net.d"
+	DefaultSocketPath = "/run/cni/cnid.sock"
+)
+
+func getListener(socketPath string) (net.Listener, error) {
+	l, err := activation.Listeners(true)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(l) == 0:
+		if err := os.MkdirAll(filepath.Dir(socketPath), 0700); err != nil {
+			return nil, err
+		}
+		return net.Listen("unix", socketPath)
+
+	case len(l) == 1:
+		if l[0] == nil {
+			return nil, fmt.Errorf("LISTEN_FDS=1 but no FD found")
+		}
+		return l[0], nil
+
+	default:
+		return nil, fmt.Errorf("Too many (%v) FDs passed through socket activation", len(l))
+	}
+}
+
+func serveHTTP(addr, tokenFile, tlsConfigFile string, service *cnid.Service) error {
+	if tokenFile == "" {
+		return fmt.Errorf("-http requires -http-token-file")
+	}
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return err
+	}
+	t := strings.TrimSpace(string(token))
+	if t == "" {
+		return fmt.Errorf("token file %v is empty", tokenFile)
+	}
+
+	l, err := cnid.ListenHTTP(addr)
+	if err != nil {
+		return err
+	}
+	if tlsConfigFile != "" {
+		c, err := tlsconfig.Load(tlsConfigFile)
+		if err != nil {
+			l.Close()
+			return err
+		}
+		cfg, err := c.ServerConfig()
+		if err != nil {
+			l.Close()
+			return err
+		}
+		l = tls.NewListener(l, cfg)
+	}
+	go func() {
+		log.Fatal(http.Serve(l, cnid.NewHTTPHandler(service, t)))
+	}()
+	return nil
+}
+
+func main() {
+	socketPath := flag.String("socket", DefaultSocketPath, "unix socket to listen on")
+	httpAddr := flag.String("http", "", "loopback address to serve the REST API on, e.g. 127.0.0.1:8080")
+	tokenFile := flag.String("http-token-file", "", "file holding the token REST API clients must present")
+	tlsConfigFile := flag.String("http-tls-config", "", "file holding the TLS configuration of the REST API, which is served over TLS if set")
+	flag.Parse()
+
+	netdir := os.Getenv(EnvNetDir)
+	if netdir == "" {
+		netdir = DefaultNetDir
+	}
+
+	cninet := &libcni.CNIConfig{
+		Path: filepath.SplitList(os.Getenv(EnvCNIPath)),
+	}
+
+	service, err := cnid.NewService(cninet, netdir)
+	if err != nil {
+		log.Fatalf("Error loading network configurations: %v", err)
+	}
+
+	// re-read the network configurations on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := service.Reload(); err != nil {
+				log.Printf("Error reloading network configurations: %v", err)
+			} else {
+				log.Printf("Reloaded network configurations from %v", netdir)
+			}
+		}
+	}()
+
+	if *httpAddr != "" {
+		if err := serveHTTP(*httpAddr, *tokenFile, *tlsConfigFile, service); err != nil {
+			log.Fatalf("Error serving REST API: %v", err)
+		}
+	}
+
+	l, err := getListener(*socketPath)
+	if err != nil {
+		log.Fatalf("Error getting listener: %v", err)
+	}
+
+	log.Fatal(cnid.Serve(l, service))
+}
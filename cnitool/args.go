@@ -0,0 +1,109 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// pluginArgs collects the repeated -arg K=V flags, and the pairs of
+// -args-file files, into CNI_ARGS pairs. A pair overrides an earlier one
+// with the same key.
+type pluginArgs [][2]string
+
+func (a *pluginArgs) register(flags *flag.FlagSet) {
+	flags.Var(a, "arg", "K=V pair passed to the plugin in CNI_ARGS, may be repeated")
+	flags.Var(argsFile{a}, "args-file", "file of K=V lines passed to the plugin in CNI_ARGS, may be repeated")
+}
+
+func (a *pluginArgs) String() string {
+	pairs := []string{}
+	for _, kv := range *a {
+		pairs = append(pairs, kv[0]+"="+kv[1])
+	}
+	return strings.Join(pairs, ";")
+}
+
+func (a *pluginArgs) Set(s string) error {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return fmt.Errorf("expected K=V, got %q", s)
+	}
+	if strings.Contains(s, ";") {
+		return fmt.Errorf("%q must not contain ';'", s)
+	}
+	for i := range *a {
+		if (*a)[i][0] == kv[0] {
+			(*a)[i][1] = kv[1]
+			return nil
+		}
+	}
+	*a = append(*a, [2]string{kv[0], kv[1]})
+	return nil
+}
+
+// argsFile reads K=V pairs from a file, one per line. Empty lines and
+// lines starting with # are skipped, and $VAR or ${VAR} in values is
+// replaced by the environment variable VAR, which must be set.
+type argsFile struct {
+	args *pluginArgs
+}
+
+func (f argsFile) String() string {
+	return ""
+}
+
+func (f argsFile) Set(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		expanded, err := expandEnv(line)
+		if err == nil {
+			err = f.args.Set(expanded)
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", path, lineno, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func expandEnv(s string) (string, error) {
+	var missing []string
+	expanded := os.Expand(s, func(name string) string {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/ns"
+)
+
+// batchNetNSPrefix is the name prefix of the namespaces created by
+// batch -generate
+const batchNetNSPrefix = "cnitool-batch-"
+
+// batchMain applies add or del to many namespaces at once, e.g. to
+// scale-test IPAM pools or bridges
+func batchMain(argv []string) {
+	if len(argv) < 1 || (argv[0] != CmdAdd && argv[0] != CmdDel) {
+		usage()
+	}
+	cmd := argv[0]
+
+	var args pluginArgs
+	flags := flag.NewFlagSet(CmdBatch, flag.ExitOnError)
+	args.register(flags)
+	concurrency := flags.Int("concurrency", 10, "number of namespaces worked on at the same time")
+	netnsFile := flags.String("netns-file", "", "file listing the netns paths, one per line")
+	generate := flags.Int("generate", 0, "number of namespaces to create on add, and delete on del")
+	flags.Usage = usage
+	flags.Parse(argv[1:])
+	if flags.NArg() != 1 || (*netnsFile == "") == (*generate == 0) || *concurrency < 1 {
+		usage()
+	}
+
+	netconf, err := loadConf(flags.Arg(0))
+	if err != nil {
+		exitText(nil, err)
+	}
+
+	var netnses []string
+	if *netnsFile != "" {
+		netnses, err = readNetNSFile(*netnsFile)
+	} else {
+		netnses, err = generateNetNS(*generate, cmd == CmdAdd)
+	}
+	if err != nil {
+		exitText(nil, err)
+	}
+
+	cninet := cniConfig()
+	jobs := make(chan string)
+	failed := 0
+	var mux sync.Mutex
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	for w := 0; w < *concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for netns := range jobs {
+				err := batchOne(cninet, netconf, cmd, netns, args, *generate > 0)
+				if err != nil {
+					mux.Lock()
+					failed++
+					fmt.Fprintf(os.Stderr, "%s: %v\n", netns, err)
+					mux.Unlock()
+				}
+			}
+		}()
+	}
+	for _, netns := range netnses {
+		jobs <- netns
+	}
+	close(jobs)
+	wg.Wait()
+
+	fmt.Printf("%s: %d of %d namespaces succeeded in %v\n", cmd, len(netnses)-failed, len(netnses), time.Since(start))
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func batchOne(cninet *libcni.CNIConfig, netconf *libcni.NetworkConfig, cmd, netns string, args pluginArgs, generated bool) error {
+	rt := &libcni.RuntimeConf{
+		ContainerID: batchContainerID(netns),
+		NetNS:       netns,
+		IfName:      "eth0",
+		Args:        args,
+	}
+
+	if cmd == CmdAdd {
+		_, err := cninet.AddNetwork(netconf, rt)
+		return err
+	}
+
+	if err := cninet.DelNetwork(netconf, rt); err != nil {
+		return err
+	}
+	if generated {
+		return ns.DeleteNamedNS(filepath.Base(netns))
+	}
+	return nil
+}
+
+// batchContainerID derives a container ID from the netns path, so that
+// each namespace gets its own, e.g. for IPAM, and del finds the same
+// one as add did
+func batchContainerID(netns string) string {
+	sum := sha256.Sum256([]byte(netns))
+	return fmt.Sprintf("cni-%x", sum[:8])
+}
+
+func readNetNSFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	netnses := []string{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if line := strings.TrimSpace(s.Text()); line != "" {
+			netnses = append(netnses, line)
+		}
+	}
+	return netnses, s.Err()
+}
+
+// generateNetNS returns the paths of the n namespaces used by -generate,
+// creating those that don't exist yet if create is set
+func generateNetNS(n int, create bool) ([]string, error) {
+	netnses := []string{}
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("%s%d", batchNetNSPrefix, i)
+		netnsPath := filepath.Join("/var/run/netns", name)
+
+		if create {
+			if _, err := os.Stat(netnsPath); os.IsNotExist(err) {
+				netns, err := ns.NewNamedNS(name)
+				if err != nil {
+					return nil, err
+				}
+				netns.Close()
+			}
+		}
+		netnses = append(netnses, netnsPath)
+	}
+	return netnses, nil
+}
@@ -15,12 +15,15 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/ns"
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/version"
 )
 
 const (
@@ -29,43 +32,123 @@ const (
 
 	DefaultNetDir = "/etc/cni/net.d"
 
-	CmdAdd = "add"
-	CmdDel = "del"
+	CmdAdd      = "add"
+	CmdDel      = "del"
+	CmdNetNS    = "netns"
+	CmdBatch    = "batch"
+	CmdValidate = "validate"
+
+	OutputText = "text"
+	OutputJSON = "json"
 )
 
 func main() {
-	if len(os.Args) < 3 {
+	if len(os.Args) < 2 {
 		usage()
 		return
 	}
 
-	netdir := os.Getenv(EnvNetDir)
-	if netdir == "" {
-		netdir = DefaultNetDir
+	switch os.Args[1] {
+	case CmdNetNS:
+		netnsMain(os.Args[2:])
+		return
+	case CmdBatch:
+		batchMain(os.Args[2:])
+		return
+	case CmdValidate:
+		validateMain(os.Args[2:])
+		return
 	}
-	netconf, err := libcni.LoadConf(netdir, os.Args[2])
-	if err != nil {
-		exit(err)
+
+	var args pluginArgs
+	flags := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	args.register(flags)
+	output := flags.String("output", OutputText, "output format, \"text\" or \"json\"")
+	flags.Usage = usage
+	flags.Parse(os.Args[2:])
+	if flags.NArg() != 2 {
+		usage()
+		return
 	}
 
-	netns := os.Args[3]
+	jsonOutput := false
+	switch *output {
+	case OutputText:
+	case OutputJSON:
+		jsonOutput = true
+	default:
+		usage()
+	}
 
-	cninet := &libcni.CNIConfig{
-		Path: strings.Split(os.Getenv(EnvCNIPath), ":"),
+	netconf, err := loadConf(flags.Arg(0))
+	exit := exitText
+	if jsonOutput {
+		cniVersion := ""
+		if err == nil {
+			cniVersion = netconf.Network.CNIVersion
+		}
+		exit = func(result *types.Result, err error) {
+			exitJSON(result, err, cniVersion)
+		}
+	}
+	if err != nil {
+		exit(nil, err)
 	}
 
+	netns := flags.Arg(1)
+
+	cninet := cniConfig()
+
 	rt := &libcni.RuntimeConf{
 		ContainerID: "cni",
 		NetNS:       netns,
 		IfName:      "eth0",
+		Args:        args,
 	}
 
 	switch os.Args[1] {
 	case CmdAdd:
-		_, err := cninet.AddNetwork(netconf, rt)
-		exit(err)
+		exit(cninet.AddNetwork(netconf, rt))
 	case CmdDel:
-		exit(cninet.DelNetwork(netconf, rt))
+		exit(nil, cninet.DelNetwork(netconf, rt))
+	default:
+		usage()
+	}
+}
+
+func loadConf(name string) (*libcni.NetworkConfig, error) {
+	netdir := os.Getenv(EnvNetDir)
+	if netdir == "" {
+		netdir = DefaultNetDir
+	}
+	return libcni.LoadConf(netdir, name)
+}
+
+func cniConfig() *libcni.CNIConfig {
+	return &libcni.CNIConfig{
+		Path: filepath.SplitList(os.Getenv(EnvCNIPath)),
+	}
+}
+
+// netnsMain creates and deletes namespaces for use as the <netns> of
+// add and del, compatible with `ip netns`
+func netnsMain(args []string) {
+	if len(args) != 2 {
+		usage()
+	}
+
+	switch args[0] {
+	case "create":
+		netns, err := ns.NewNamedNS(args[1])
+		if err != nil {
+			exitText(nil, err)
+		}
+		fmt.Println(netns.Path())
+		exitText(nil, netns.Close())
+	case "delete":
+		exitText(nil, ns.DeleteNamedNS(args[1]))
+	default:
+		usage()
 	}
 }
 
@@ -73,15 +156,44 @@ func usage() {
 	exe := filepath.Base(os.Args[0])
 
 	fmt.Fprintf(os.Stderr, "%s: Add or remove network interfaces from a network namespace\n", exe)
-	fmt.Fprintf(os.Stderr, "  %s %s <net> <netns>\n", exe, CmdAdd)
-	fmt.Fprintf(os.Stderr, "  %s %s <net> <netns>\n", exe, CmdDel)
+	fmt.Fprintf(os.Stderr, "  %s %s [-arg K=V]... [-args-file <file>]... [-output text|json] <net> <netns>\n", exe, CmdAdd)
+	fmt.Fprintf(os.Stderr, "  %s %s [-arg K=V]... [-args-file <file>]... [-output text|json] <net> <netns>\n", exe, CmdDel)
+	fmt.Fprintf(os.Stderr, "  %s %s create|delete <name>\n", exe, CmdNetNS)
+	fmt.Fprintf(os.Stderr, "  %s %s add|del [-arg K=V]... [-args-file <file>]... [-concurrency N] -netns-file <file>|-generate N <net>\n", exe, CmdBatch)
+	fmt.Fprintf(os.Stderr, "  %s %s <net>|<file>\n", exe, CmdValidate)
 	os.Exit(1)
 }
 
-func exit(err error) {
+func exitText(_ *types.Result, err error) {
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
 		os.Exit(1)
 	}
 	os.Exit(0)
 }
+
+// exitJSON prints the result in the format of cniVersion, or the error in
+// the CNI error format, to stdout so that scripts can parse it
+func exitJSON(result *types.Result, err error, cniVersion string) {
+	if printJSON(result, err, cniVersion) != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// printJSON does the printing of exitJSON, and returns err or the error
+// printing failed with
+func printJSON(result *types.Result, err error, cniVersion string) error {
+	if err != nil {
+		e, ok := err.(*types.Error)
+		if !ok {
+			e = &types.Error{Code: types.ErrPlugin, Msg: err.Error()}
+		}
+		e.Print()
+		return err
+	}
+	if result != nil {
+		return version.PrintResult(result, cniVersion)
+	}
+	return nil
+}
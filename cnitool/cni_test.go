@@ -0,0 +1,70 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+
+	"github.com/containernetworking/cni/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("printJSON", func() {
+	// capture returns what printJSON prints to stdout
+	capture := func(result *types.Result, err error, cniVersion string) (string, error) {
+		r, w, pipeErr := os.Pipe()
+		Expect(pipeErr).NotTo(HaveOccurred())
+		stdout := os.Stdout
+		os.Stdout = w
+		err = printJSON(result, err, cniVersion)
+		os.Stdout = stdout
+		w.Close()
+		out, readErr := ioutil.ReadAll(r)
+		Expect(readErr).NotTo(HaveOccurred())
+		return string(out), err
+	}
+
+	result := &types.Result{
+		IP4: &types.IPConfig{IP: net.IPNet{IP: net.IPv4(10, 1, 2, 3), Mask: net.CIDRMask(24, 32)}},
+	}
+
+	It("prints the result in the version of the network", func() {
+		out, err := capture(result, nil, "0.2.0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(MatchJSON(`{"cniVersion": "0.2.0", "ip4": {"ip": "10.1.2.3/24"}, "dns": {}}`))
+	})
+
+	It("prints the result of networks without a cniVersion in the oldest version", func() {
+		out, err := capture(result, nil, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(MatchJSON(`{"cniVersion": "0.1.0", "ip4": {"ip": "10.1.2.3/24"}, "dns": {}}`))
+	})
+
+	It("fails for versions it can't print", func() {
+		_, err := capture(result, nil, "9.9.9")
+		Expect(err).To(MatchError(ContainSubstring("incompatible CNI versions")))
+	})
+
+	It("prints errors in the CNI error format", func() {
+		out, err := capture(nil, errors.New("boom"), "0.2.0")
+		Expect(err).To(MatchError("boom"))
+		Expect(out).To(MatchJSON(`{"code": 100, "msg": "boom"}`))
+	})
+})
@@ -0,0 +1,119 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/invoke"
+	"github.com/containernetworking/cni/pkg/schema"
+)
+
+// validateMain checks a network configuration against the schemas
+// exported by its plugin and IPAM plugin
+func validateMain(args []string) {
+	if len(args) != 1 {
+		usage()
+	}
+
+	// a path to a file, or the name of a network in NETCONFPATH
+	var netconf *libcni.NetworkConfig
+	var err error
+	if _, statErr := os.Stat(args[0]); statErr == nil {
+		netconf, err = libcni.ConfFromFile(args[0])
+	} else {
+		netconf, err = loadConf(args[0])
+	}
+	if err != nil {
+		exitText(nil, err)
+	}
+
+	problems, err := validate(netconf, filepath.SplitList(os.Getenv(EnvCNIPath)))
+	if err != nil {
+		exitText(nil, err)
+	}
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func validate(netconf *libcni.NetworkConfig, path []string) ([]string, error) {
+	var conf map[string]interface{}
+	if err := json.Unmarshal(netconf.Bytes, &conf); err != nil {
+		return nil, err
+	}
+
+	problems := []string{}
+	if netconf.Network.Type == "" {
+		return append(problems, `top level: missing field "type"`), nil
+	}
+
+	s, err := pluginSchema(netconf.Network.Type, path)
+	if err != nil {
+		return nil, err
+	}
+	if s != nil {
+		if s.Properties != nil && conf["ipam"] != nil {
+			// the IPAM plugin checks its own section below
+			s.Properties["ipam"] = &schema.Schema{}
+		}
+		problems = append(problems, s.ValidateValue(conf, "")...)
+	}
+
+	if ipamType := netconf.Network.IPAM.Type; ipamType != "" {
+		s, err := pluginSchema(ipamType, path)
+		if err != nil {
+			return nil, err
+		}
+		if s != nil && s.Properties["ipam"] != nil {
+			problems = append(problems, s.Properties["ipam"].ValidateValue(conf["ipam"], "ipam")...)
+		}
+	}
+
+	sort.Strings(problems)
+	return problems, nil
+}
+
+// pluginSchema asks the plugin for its schema. It returns nil, after
+// telling the user, for plugins which don't export one.
+func pluginSchema(plugin string, path []string) (*schema.Schema, error) {
+	pluginPath, err := invoke.FindInPath(plugin, path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := &invoke.RawExec{Stderr: ioutil.Discard}
+	out, err := raw.ExecPlugin(pluginPath, nil, []string{"CNI_COMMAND=SCHEMA"})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s does not export a schema, not checking its fields: %v\n", plugin, err)
+		return nil, nil
+	}
+
+	s := &schema.Schema{}
+	if err := json.Unmarshal(out, s); err != nil {
+		return nil, fmt.Errorf("error parsing the schema of %s: %v", plugin, err)
+	}
+	return s, nil
+}
@@ -15,6 +15,7 @@
 package libcni
 
 import (
+	"os"
 	"strings"
 
 	"github.com/containernetworking/cni/pkg/invoke"
@@ -26,6 +27,9 @@ type RuntimeConf struct {
 	NetNS       string
 	IfName      string
 	Args        [][2]string
+	// TraceID is passed to the plugins as CNI_TRACE_ID, so that their
+	// logs can be correlated. Plugins generate one if it's empty.
+	TraceID string
 }
 
 type NetworkConfig struct {
@@ -68,6 +72,7 @@ func (c *CNIConfig) args(action string, rt *RuntimeConf) *invoke.Args {
 		NetNS:       rt.NetNS,
 		PluginArgs:  rt.Args,
 		IfName:      rt.IfName,
-		Path:        strings.Join(c.Path, ":"),
+		Path:        strings.Join(c.Path, string(os.PathListSeparator)),
+		TraceID:     rt.TraceID,
 	}
 }
@@ -0,0 +1,193 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bench measures the latency and throughput of attaching
+// containers to a network and detaching them again.
+package bench
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/containernetworking/cni/libcni"
+)
+
+// Options controls the shape of a benchmark run
+type Options struct {
+	// Network namespaces to attach and detach, one container each
+	Namespaces []string
+	// Number of ADD/DEL cycles per namespace
+	Cycles int
+	// Number of namespaces worked on at the same time
+	Concurrency int
+	// Interface name passed to the plugin
+	IfName string
+}
+
+// Stats summarizes the latencies of one operation
+type Stats struct {
+	Count int           `json:"count"`
+	Min   time.Duration `json:"min"`
+	Mean  time.Duration `json:"mean"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+// Report is the outcome of a benchmark run
+type Report struct {
+	Add Stats `json:"add"`
+	Del Stats `json:"del"`
+	// Wall clock time of the whole run
+	Duration time.Duration `json:"duration"`
+	// Completed ADD/DEL cycles per second
+	Throughput float64 `json:"throughput"`
+	// Failed operations, and the first error seen
+	Errors     int    `json:"errors"`
+	FirstError string `json:"firstError,omitempty"`
+}
+
+type recorder struct {
+	mux        sync.Mutex
+	add, del   []time.Duration
+	cycles     int
+	errors     int
+	firstError error
+}
+
+func (r *recorder) record(latencies *[]time.Duration, d time.Duration, err error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if err != nil {
+		r.errors++
+		if r.firstError == nil {
+			r.firstError = err
+		}
+		return
+	}
+	*latencies = append(*latencies, d)
+}
+
+// Run attaches and detaches a container in each namespace to net, cycle
+// times in a row. Namespaces are worked on concurrently, but a single
+// namespace never has more than one operation in flight.
+func Run(cni libcni.CNI, net *libcni.NetworkConfig, opts Options) (*Report, error) {
+	if len(opts.Namespaces) == 0 {
+		return nil, fmt.Errorf("no namespaces to benchmark with")
+	}
+	if opts.Cycles < 1 {
+		opts.Cycles = 1
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.IfName == "" {
+		opts.IfName = "eth0"
+	}
+
+	r := &recorder{}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	for w := 0; w < opts.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				rt := &libcni.RuntimeConf{
+					ContainerID: fmt.Sprintf("cnibench-%d", i),
+					NetNS:       opts.Namespaces[i],
+					IfName:      opts.IfName,
+				}
+				cycle(cni, net, rt, opts.Cycles, r)
+			}
+		}()
+	}
+	for i := range opts.Namespaces {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	duration := time.Since(start)
+
+	report := &Report{
+		Add:        summarize(r.add),
+		Del:        summarize(r.del),
+		Duration:   duration,
+		Throughput: float64(r.cycles) / duration.Seconds(),
+		Errors:     r.errors,
+	}
+	if r.firstError != nil {
+		report.FirstError = r.firstError.Error()
+	}
+	return report, nil
+}
+
+func cycle(cni libcni.CNI, net *libcni.NetworkConfig, rt *libcni.RuntimeConf, cycles int, r *recorder) {
+	for c := 0; c < cycles; c++ {
+		t := time.Now()
+		_, err := cni.AddNetwork(net, rt)
+		r.record(&r.add, time.Since(t), err)
+		if err != nil {
+			// nothing to detach, but a DEL must be safe to call anyway
+			cni.DelNetwork(net, rt)
+			continue
+		}
+
+		t = time.Now()
+		err = cni.DelNetwork(net, rt)
+		r.record(&r.del, time.Since(t), err)
+		if err == nil {
+			r.mux.Lock()
+			r.cycles++
+			r.mux.Unlock()
+		}
+	}
+}
+
+type byDuration []time.Duration
+
+func (d byDuration) Len() int           { return len(d) }
+func (d byDuration) Less(i, j int) bool { return d[i] < d[j] }
+func (d byDuration) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+func summarize(latencies []time.Duration) Stats {
+	if len(latencies) == 0 {
+		return Stats{}
+	}
+	sorted := append([]time.Duration{}, latencies...)
+	sort.Sort(byDuration(sorted))
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	percentile := func(p int) time.Duration {
+		return sorted[(len(sorted)-1)*p/100]
+	}
+	return Stats{
+		Count: len(sorted),
+		Min:   sorted[0],
+		Mean:  total / time.Duration(len(sorted)),
+		P50:   percentile(50),
+		P90:   percentile(90),
+		P99:   percentile(99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
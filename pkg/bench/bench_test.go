@@ -0,0 +1,107 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench_test
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/bench"
+	"github.com/containernetworking/cni/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakeCNI struct {
+	mux      sync.Mutex
+	attached map[string]bool
+	overlap  bool
+	failAdd  map[string]bool
+}
+
+func (f *fakeCNI) AddNetwork(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) (*types.Result, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.failAdd[rt.NetNS] {
+		return nil, errors.New("banana")
+	}
+	if f.attached[rt.NetNS] {
+		f.overlap = true
+	}
+	f.attached[rt.NetNS] = true
+	return &types.Result{}, nil
+}
+
+func (f *fakeCNI) DelNetwork(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	delete(f.attached, rt.NetNS)
+	return nil
+}
+
+var _ = Describe("Run", func() {
+	var (
+		fake *fakeCNI
+		net  *libcni.NetworkConfig
+	)
+
+	BeforeEach(func() {
+		fake = &fakeCNI{
+			attached: make(map[string]bool),
+			failAdd:  make(map[string]bool),
+		}
+		net = &libcni.NetworkConfig{Network: &types.NetConf{Name: "test", Type: "fake"}}
+	})
+
+	It("runs every cycle in every namespace", func() {
+		report, err := bench.Run(fake, net, bench.Options{
+			Namespaces:  []string{"/ns/1", "/ns/2", "/ns/3"},
+			Cycles:      5,
+			Concurrency: 2,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(report.Add.Count).To(Equal(15))
+		Expect(report.Del.Count).To(Equal(15))
+		Expect(report.Errors).To(BeZero())
+		Expect(report.Throughput).To(BeNumerically(">", 0))
+		Expect(report.Add.Min).To(BeNumerically("<=", report.Add.P50))
+		Expect(report.Add.P50).To(BeNumerically("<=", report.Add.P99))
+		Expect(report.Add.P99).To(BeNumerically("<=", report.Add.Max))
+
+		Expect(fake.overlap).To(BeFalse())
+		Expect(fake.attached).To(BeEmpty())
+	})
+
+	It("counts failed operations", func() {
+		fake.failAdd["/ns/2"] = true
+		report, err := bench.Run(fake, net, bench.Options{
+			Namespaces: []string{"/ns/1", "/ns/2"},
+			Cycles:     3,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(report.Add.Count).To(Equal(3))
+		Expect(report.Errors).To(Equal(3))
+		Expect(report.FirstError).To(Equal("banana"))
+	})
+
+	It("needs at least one namespace", func() {
+		_, err := bench.Run(fake, net, bench.Options{})
+		Expect(err).To(HaveOccurred())
+	})
+})
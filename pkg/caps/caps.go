@@ -0,0 +1,37 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package caps drops the Linux capabilities of a plugin once it is done
+// with the work that needs them, so that a bug in the code running
+// afterwards, like a JSON parser or a client of a remote store, can't
+// make use of them.
+package caps
+
+import "errors"
+
+// Cap is a Linux capability, see capabilities(7)
+type Cap uint
+
+const (
+	DACOverride    Cap = 1
+	SetPCap        Cap = 8
+	NetBindService Cap = 10
+	NetAdmin       Cap = 12
+	NetRaw         Cap = 13
+	SysAdmin       Cap = 21
+)
+
+// ErrNotSupported is returned by Drop in programs linked with cgo, since
+// the capabilities of threads created by C can't be changed from Go
+var ErrNotSupported = errors.New("dropping capabilities is not supported with cgo")
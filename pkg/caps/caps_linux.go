@@ -0,0 +1,122 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caps
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const linuxCapabilityVersion3 = 0x20080522
+
+// from linux/prctl.h, missing in the vendored x/sys for most architectures
+const (
+	prCapAmbient         = 47
+	prCapAmbientClearAll = 4
+)
+
+type capHeader struct {
+	version uint32
+	pid     int32
+}
+
+type capData struct {
+	effective   uint32
+	permitted   uint32
+	inheritable uint32
+}
+
+// Drop removes all capabilities but keep from the effective, permitted
+// and inheritable sets of every thread of the process, and clears their
+// ambient sets. If the process may, they are removed from the bounding
+// set too, so that programs it executes don't regain them.
+func Drop(keep ...Cap) error {
+	var mask [2]uint32
+	for _, c := range keep {
+		mask[c/32] |= 1 << (c % 32)
+	}
+
+	hdr := capHeader{version: linuxCapabilityVersion3}
+	var data [2]capData
+	if _, _, e := syscall.RawSyscall(syscall.SYS_CAPGET, uintptr(unsafe.Pointer(&hdr)), uintptr(unsafe.Pointer(&data[0])), 0); e != 0 {
+		return fmt.Errorf("failed to get capabilities: %v", e)
+	}
+
+	// dropping from the bounding set needs CAP_SETPCAP, so it goes first
+	if data[0].effective&(1<<SetPCap) != 0 {
+		last, err := lastCap()
+		if err != nil {
+			return err
+		}
+		for c := Cap(0); c <= last; c++ {
+			if mask[c/32]&(1<<(c%32)) != 0 {
+				continue
+			}
+			if err := allThreads(syscall.SYS_PRCTL, unix.PR_CAPBSET_DROP, uintptr(c), 0); err != nil {
+				return fmt.Errorf("failed to drop capability %d from the bounding set: %v", c, err)
+			}
+		}
+	}
+
+	for i := range data {
+		data[i].effective &= mask[i]
+		data[i].permitted &= mask[i]
+		data[i].inheritable &= mask[i]
+	}
+	if err := allThreads(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&hdr)), uintptr(unsafe.Pointer(&data[0])), 0); err != nil {
+		return fmt.Errorf("failed to set capabilities: %v", err)
+	}
+
+	// kernels before 4.3 have no ambient set, and reject the prctl
+	err := allThreads(syscall.SYS_PRCTL, prCapAmbient, prCapAmbientClearAll, 0)
+	if err != nil && err != syscall.EINVAL {
+		return fmt.Errorf("failed to clear the ambient capabilities: %v", err)
+	}
+	return nil
+}
+
+// allThreads runs a syscall on all threads, since capabilities are a
+// property of each thread rather than of the process. The remaining
+// arguments are zero, as prctl requires of the unused ones.
+func allThreads(trap, a1, a2, a3 uintptr) error {
+	_, _, e := syscall.AllThreadsSyscall6(trap, a1, a2, a3, 0, 0, 0)
+	switch e {
+	case 0:
+		return nil
+	case syscall.ENOTSUP:
+		return ErrNotSupported
+	default:
+		return e
+	}
+}
+
+// lastCap returns the highest capability known to the kernel
+func lastCap() (Cap, error) {
+	data, err := ioutil.ReadFile("/proc/sys/kernel/cap_last_cap")
+	if err != nil {
+		return 0, err
+	}
+	last, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse cap_last_cap: %v", err)
+	}
+	return Cap(last), nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caps_test
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+
+	"testing"
+)
+
+var pathToDrop string
+
+var _ = BeforeSuite(func() {
+	// capabilities can only be dropped from all threads without cgo
+	os.Setenv("CGO_ENABLED", "0")
+	defer os.Unsetenv("CGO_ENABLED")
+
+	var err error
+	pathToDrop, err = gexec.Build("github.com/containernetworking/cni/pkg/caps/testdata/drop")
+	Expect(err).NotTo(HaveOccurred())
+})
+
+var _ = AfterSuite(func() {
+	gexec.CleanupBuildArtifacts()
+})
+
+func TestCaps(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Caps Suite")
+}
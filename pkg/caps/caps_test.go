@@ -0,0 +1,75 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caps_test
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/containernetworking/cni/pkg/caps"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Drop", func() {
+	// drop runs the helper keeping the given capabilities and returns the
+	// capability sets of its threads
+	drop := func(ambient []uintptr, keep ...caps.Cap) []string {
+		args := []string{}
+		for _, c := range keep {
+			args = append(args, fmt.Sprint(c))
+		}
+		cmd := exec.Command(pathToDrop, args...)
+		cmd.SysProcAttr = &syscall.SysProcAttr{AmbientCaps: ambient}
+		out, err := cmd.CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(out))
+		return strings.Split(strings.TrimSpace(string(out)), "\n")
+	}
+
+	It("keeps only the given capabilities on every thread", func() {
+		lines := drop(nil, caps.NetAdmin, caps.SysAdmin)
+		Expect(len(lines)).To(BeNumerically(">=", 5))
+
+		for _, line := range lines {
+			fields := strings.Fields(line)
+			Expect(fields).To(HaveLen(2))
+			switch fields[0] {
+			case "CapInh:", "CapAmb:":
+				Expect(fields[1]).To(Equal("0000000000000000"), line)
+			default:
+				Expect(fields[1]).To(Equal("0000000000201000"), line)
+			}
+		}
+	})
+
+	It("drops all capabilities when none are kept", func() {
+		for _, line := range drop(nil) {
+			Expect(strings.Fields(line)[1]).To(Equal("0000000000000000"), line)
+		}
+	})
+
+	It("clears the ambient set, kept capabilities included", func() {
+		lines := drop([]uintptr{uintptr(caps.NetAdmin), uintptr(caps.SysAdmin)}, caps.NetAdmin)
+		Expect(lines).To(ContainElement(HavePrefix("CapAmb:")))
+		for _, line := range lines {
+			if strings.HasPrefix(line, "CapAmb:") {
+				Expect(strings.Fields(line)[1]).To(Equal("0000000000000000"), line)
+			}
+		}
+	})
+})
@@ -0,0 +1,101 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnid
+
+import (
+	"fmt"
+	"net/rpc"
+	"path/filepath"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/ns"
+	"github.com/containernetworking/cni/pkg/types"
+)
+
+// Client talks to a cnid daemon. It implements libcni.CNI, so it can
+// be used in place of libcni.CNIConfig.
+type Client struct {
+	rpc *rpc.Client
+}
+
+var _ libcni.CNI = &Client{}
+
+// Dial connects to the daemon listening on socketPath
+func Dial(socketPath string) (*Client, error) {
+	c, err := rpc.DialHTTP("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("error dialing cnid: %v", err)
+	}
+	return &Client{c}, nil
+}
+
+func (c *Client) Close() error {
+	return c.rpc.Close()
+}
+
+// AddNetwork attaches the container to the network described by net
+func (c *Client) AddNetwork(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) (*types.Result, error) {
+	return c.add(&Request{Config: net.Bytes}, rt)
+}
+
+// DelNetwork detaches the container from the network described by net
+func (c *Client) DelNetwork(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) error {
+	return c.del(&Request{Config: net.Bytes}, rt)
+}
+
+// AddNamedNetwork attaches the container to a network loaded by the daemon
+func (c *Client) AddNamedNetwork(name string, rt *libcni.RuntimeConf) (*types.Result, error) {
+	return c.add(&Request{Network: name}, rt)
+}
+
+// DelNamedNetwork detaches the container from a network loaded by the daemon
+func (c *Client) DelNamedNetwork(name string, rt *libcni.RuntimeConf) error {
+	return c.del(&Request{Network: name}, rt)
+}
+
+func (c *Client) add(req *Request, rt *libcni.RuntimeConf) (*types.Result, error) {
+	if err := setRuntime(req, rt); err != nil {
+		return nil, err
+	}
+	result := &types.Result{}
+	if err := c.rpc.Call("CNI.Add", req, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *Client) del(req *Request, rt *libcni.RuntimeConf) error {
+	if err := setRuntime(req, rt); err != nil {
+		return err
+	}
+	return c.rpc.Call("CNI.Del", req, &struct{}{})
+}
+
+func setRuntime(req *Request, rt *libcni.RuntimeConf) error {
+	req.Runtime = *rt
+
+	// The daemon may be running under a different working dir
+	// so make sure the netns path is absolute.
+	netns, err := ns.ResolvePath(rt.NetNS)
+	if err != nil {
+		return err
+	}
+	netns, err = filepath.Abs(netns)
+	if err != nil {
+		return fmt.Errorf("failed to make %q an absolute path: %v", rt.NetNS, err)
+	}
+	req.Runtime.NetNS = netns
+	return nil
+}
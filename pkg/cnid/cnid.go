@@ -0,0 +1,142 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cnid exposes libcni operations over net/rpc on a unix socket,
+// for runtimes which can't or don't want to exec plugins themselves.
+package cnid
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/rpc"
+	"sync"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/types"
+)
+
+// Request names the network to operate on and the container to attach.
+// If Config is set it is used as the network configuration, otherwise
+// the configuration loaded by the daemon for Network is used.
+type Request struct {
+	Network string
+	Config  []byte
+	Runtime libcni.RuntimeConf
+}
+
+// Service implements the RPC methods. The network configurations are
+// loaded once and only re-read when Reload is called.
+type Service struct {
+	cni     libcni.CNI
+	confDir string
+
+	mux      sync.RWMutex
+	networks map[string]*libcni.NetworkConfig
+}
+
+// NewService creates a Service running the plugins with cni and
+// loads the network configurations found in confDir
+func NewService(cni libcni.CNI, confDir string) (*Service, error) {
+	s := &Service{
+		cni:     cni,
+		confDir: confDir,
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload re-reads the network configurations from the config dir
+func (s *Service) Reload() error {
+	files, err := libcni.ConfFiles(s.confDir)
+	if err != nil {
+		return err
+	}
+
+	networks := make(map[string]*libcni.NetworkConfig)
+	for _, f := range files {
+		conf, err := libcni.ConfFromFile(f)
+		if err != nil {
+			return err
+		}
+		if _, ok := networks[conf.Network.Name]; !ok {
+			networks[conf.Network.Name] = conf
+		}
+	}
+
+	s.mux.Lock()
+	s.networks = networks
+	s.mux.Unlock()
+	return nil
+}
+
+// NotFoundError is returned for requests naming a network the daemon
+// has no configuration for
+type NotFoundError struct {
+	Network string
+	ConfDir string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf(`no net configuration with name "%s" in %s`, e.Network, e.ConfDir)
+}
+
+func (s *Service) netConf(req *Request) (*libcni.NetworkConfig, error) {
+	if len(req.Config) > 0 {
+		return libcni.ConfFromBytes(req.Config)
+	}
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	conf, ok := s.networks[req.Network]
+	if !ok {
+		return nil, &NotFoundError{req.Network, s.confDir}
+	}
+	return conf, nil
+}
+
+// Add attaches the container to the network
+func (s *Service) Add(req *Request, result *types.Result) error {
+	conf, err := s.netConf(req)
+	if err != nil {
+		return err
+	}
+
+	r, err := s.cni.AddNetwork(conf, &req.Runtime)
+	if err != nil {
+		return err
+	}
+	*result = *r
+	return nil
+}
+
+// Del detaches the container from the network
+func (s *Service) Del(req *Request, _ *struct{}) error {
+	conf, err := s.netConf(req)
+	if err != nil {
+		return err
+	}
+	return s.cni.DelNetwork(conf, &req.Runtime)
+}
+
+// Serve answers the requests for s arriving on l until l is closed
+func Serve(l net.Listener, s *Service) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName("CNI", s); err != nil {
+		return err
+	}
+	return http.Serve(l, server)
+}
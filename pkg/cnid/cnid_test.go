@@ -0,0 +1,159 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnid_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/cnid"
+	"github.com/containernetworking/cni/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakeCNI struct {
+	added   []string
+	deleted []string
+	runtime *libcni.RuntimeConf
+	err     error
+}
+
+func (f *fakeCNI) AddNetwork(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) (*types.Result, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.added = append(f.added, net.Network.Name)
+	f.runtime = rt
+	return &types.Result{
+		IP4: &types.IPConfig{
+			IP: parseIPNet("10.1.2.3/24"),
+		},
+	}, nil
+}
+
+func (f *fakeCNI) DelNetwork(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) error {
+	f.deleted = append(f.deleted, net.Network.Name)
+	f.runtime = rt
+	return f.err
+}
+
+func parseIPNet(s string) net.IPNet {
+	ip, ipn, err := net.ParseCIDR(s)
+	Expect(err).NotTo(HaveOccurred())
+	ipn.IP = ip
+	return *ipn
+}
+
+var _ = Describe("cnid", func() {
+	var (
+		tmpDir   string
+		fake     *fakeCNI
+		service  *cnid.Service
+		listener net.Listener
+		client   *cnid.Client
+		rt       *libcni.RuntimeConf
+	)
+
+	writeConf := func(name, conf string) {
+		Expect(ioutil.WriteFile(filepath.Join(tmpDir, name), []byte(conf), 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "cnid")
+		Expect(err).NotTo(HaveOccurred())
+		writeConf("10-mynet.conf", `{"name": "mynet", "type": "bridge"}`)
+
+		fake = &fakeCNI{}
+		service, err = cnid.NewService(fake, tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+
+		socketPath := filepath.Join(tmpDir, "cnid.sock")
+		listener, err = net.Listen("unix", socketPath)
+		Expect(err).NotTo(HaveOccurred())
+		go cnid.Serve(listener, service)
+
+		client, err = cnid.Dial(socketPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		rt = &libcni.RuntimeConf{
+			ContainerID: "some-container-id",
+			NetNS:       "/some/netns/path",
+			IfName:      "eth0",
+			Args:        [][2]string{{"FOO", "bar"}},
+		}
+	})
+
+	AfterEach(func() {
+		Expect(client.Close()).To(Succeed())
+		listener.Close()
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	It("adds and deletes networks loaded by the daemon", func() {
+		result, err := client.AddNamedNetwork("mynet", rt)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.IP4.IP.String()).To(Equal("10.1.2.3/24"))
+		Expect(fake.added).To(Equal([]string{"mynet"}))
+		Expect(fake.runtime).To(Equal(rt))
+
+		Expect(client.DelNamedNetwork("mynet", rt)).To(Succeed())
+		Expect(fake.deleted).To(Equal([]string{"mynet"}))
+	})
+
+	It("uses the configuration passed by the client", func() {
+		conf, err := libcni.ConfFromBytes([]byte(`{"name": "othernet", "type": "bridge"}`))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = client.AddNetwork(conf, rt)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(client.DelNetwork(conf, rt)).To(Succeed())
+		Expect(fake.added).To(Equal([]string{"othernet"}))
+		Expect(fake.deleted).To(Equal([]string{"othernet"}))
+	})
+
+	It("picks up new configurations on reload", func() {
+		_, err := client.AddNamedNetwork("newnet", rt)
+		Expect(err).To(MatchError(ContainSubstring(`no net configuration with name "newnet"`)))
+
+		writeConf("20-newnet.conf", `{"name": "newnet", "type": "bridge"}`)
+		Expect(service.Reload()).To(Succeed())
+
+		_, err = client.AddNamedNetwork("newnet", rt)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("returns the plugin's error", func() {
+		fake.err = errors.New("banana")
+		_, err := client.AddNamedNetwork("mynet", rt)
+		Expect(err).To(MatchError("banana"))
+	})
+
+	It("makes the netns path absolute", func() {
+		rt.NetNS = "relative/netns"
+		_, err := client.AddNamedNetwork("mynet", rt)
+		Expect(err).NotTo(HaveOccurred())
+
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fake.runtime.NetNS).To(Equal(filepath.Join(cwd, "relative/netns")))
+	})
+})
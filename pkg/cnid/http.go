@@ -0,0 +1,161 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnid
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/types"
+)
+
+// Attachment is the body of a POST /networks/{name}/attachments request
+type Attachment struct {
+	ContainerID string      `json:"containerID"`
+	NetNS       string      `json:"netns"`
+	IfName      string      `json:"ifName"`
+	Args        [][2]string `json:"args,omitempty"`
+}
+
+type httpHandler struct {
+	service *Service
+	token   string
+}
+
+// NewHTTPHandler maps a REST API onto s:
+//
+//	POST   /networks/{name}/attachments                  adds a container to the network
+//	DELETE /networks/{name}/attachments/{containerID}    removes it again
+//
+// The DELETE request takes the netns and ifName as query parameters,
+// and both take CNI_ARGS as repeated arg=K=V query parameters.
+// Every request must carry "Authorization: Bearer <token>".
+func NewHTTPHandler(s *Service, token string) http.Handler {
+	return &httpHandler{s, token}
+}
+
+// ListenHTTP listens on addr, which must be a loopback address
+func ListenHTTP(addr string) (net.Listener, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return nil, fmt.Errorf("refusing to listen on %q: not a loopback address", addr)
+	}
+	return net.Listen("tcp", addr)
+}
+
+func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+h.token)) != 1 {
+		writeError(w, http.StatusUnauthorized, fmt.Errorf("missing or invalid token"))
+		return
+	}
+
+	// networks/{name}/attachments[/{containerID}]
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 3 || len(parts) > 4 || parts[0] != "networks" || parts[2] != "attachments" {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no such resource %q", r.URL.Path))
+		return
+	}
+	network := parts[1]
+
+	args, err := queryArgs(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	switch {
+	case r.Method == "POST" && len(parts) == 3:
+		a := Attachment{}
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("error parsing attachment: %v", err))
+			return
+		}
+		req := &Request{
+			Network: network,
+			Runtime: libcni.RuntimeConf{
+				ContainerID: a.ContainerID,
+				NetNS:       a.NetNS,
+				IfName:      a.IfName,
+				Args:        append(a.Args, args...),
+			},
+		}
+		result := &types.Result{}
+		if err := h.service.Add(req, result); err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(result)
+
+	case r.Method == "DELETE" && len(parts) == 4:
+		req := &Request{
+			Network: network,
+			Runtime: libcni.RuntimeConf{
+				ContainerID: parts[3],
+				NetNS:       r.URL.Query().Get("netns"),
+				IfName:      r.URL.Query().Get("ifName"),
+				Args:        args,
+			},
+		}
+		if err := h.service.Del(req, &struct{}{}); err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%v not allowed on %q", r.Method, r.URL.Path))
+	}
+}
+
+func queryArgs(r *http.Request) ([][2]string, error) {
+	args := [][2]string{}
+	for _, a := range r.URL.Query()["arg"] {
+		kv := strings.SplitN(a, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid arg %q, expected K=V", a)
+		}
+		args = append(args, [2]string{kv[0], kv[1]})
+	}
+	return args, nil
+}
+
+func errorStatus(err error) int {
+	if _, ok := err.(*NotFoundError); ok {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// writeError replies with err in the CNI error format
+func writeError(w http.ResponseWriter, status int, err error) {
+	e, ok := err.(*types.Error)
+	if !ok {
+		e = &types.Error{Code: types.ErrPlugin, Msg: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(e)
+}
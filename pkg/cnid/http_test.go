@@ -0,0 +1,148 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnid_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/cnid"
+	"github.com/containernetworking/cni/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("REST API", func() {
+	var (
+		tmpDir string
+		fake   *fakeCNI
+		server *httptest.Server
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "cnid")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ioutil.WriteFile(filepath.Join(tmpDir, "10-mynet.conf"), []byte(`{"name": "mynet", "type": "bridge"}`), 0644)).To(Succeed())
+
+		fake = &fakeCNI{}
+		service, err := cnid.NewService(fake, tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+		server = httptest.NewServer(cnid.NewHTTPHandler(service, "secret"))
+	})
+
+	AfterEach(func() {
+		server.Close()
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	do := func(method, path, token, body string) *http.Response {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		return resp
+	}
+
+	decodeError := func(resp *http.Response) *types.Error {
+		defer resp.Body.Close()
+		e := &types.Error{}
+		Expect(json.NewDecoder(resp.Body).Decode(e)).To(Succeed())
+		return e
+	}
+
+	It("adds an attachment on POST", func() {
+		resp := do("POST", "/networks/mynet/attachments?arg=FOO=bar", "secret",
+			`{"containerID": "c1", "netns": "/some/netns", "ifName": "eth0"}`)
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+
+		result := &types.Result{}
+		Expect(json.NewDecoder(resp.Body).Decode(result)).To(Succeed())
+		Expect(result.IP4.IP.String()).To(Equal("10.1.2.3/24"))
+
+		Expect(fake.added).To(Equal([]string{"mynet"}))
+		Expect(fake.runtime).To(Equal(&libcni.RuntimeConf{
+			ContainerID: "c1",
+			NetNS:       "/some/netns",
+			IfName:      "eth0",
+			Args:        [][2]string{{"FOO", "bar"}},
+		}))
+	})
+
+	It("deletes an attachment on DELETE", func() {
+		resp := do("DELETE", "/networks/mynet/attachments/c1?netns=/some/netns&ifName=eth0", "secret", "")
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+
+		Expect(fake.deleted).To(Equal([]string{"mynet"}))
+		Expect(fake.runtime.ContainerID).To(Equal("c1"))
+		Expect(fake.runtime.NetNS).To(Equal("/some/netns"))
+		Expect(fake.runtime.IfName).To(Equal("eth0"))
+	})
+
+	It("rejects requests without the token", func() {
+		resp := do("POST", "/networks/mynet/attachments", "", `{}`)
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+		resp.Body.Close()
+
+		resp = do("POST", "/networks/mynet/attachments", "wrong", `{}`)
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+		resp.Body.Close()
+		Expect(fake.added).To(BeEmpty())
+	})
+
+	It("returns 404 for unknown networks", func() {
+		resp := do("POST", "/networks/othernet/attachments", "secret", `{"containerID": "c1"}`)
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		Expect(decodeError(resp).Msg).To(ContainSubstring(`no net configuration with name "othernet"`))
+	})
+
+	It("returns the plugin's error", func() {
+		fake.err = &types.Error{Code: 7, Msg: "banana"}
+		resp := do("POST", "/networks/mynet/attachments", "secret", `{"containerID": "c1"}`)
+		Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+		Expect(decodeError(resp)).To(Equal(&types.Error{Code: 7, Msg: "banana"}))
+	})
+
+	It("rejects unknown resources and methods", func() {
+		resp := do("GET", "/networks/mynet/attachments", "secret", "")
+		Expect(resp.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+		resp.Body.Close()
+
+		resp = do("POST", "/foo", "secret", "")
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		resp.Body.Close()
+	})
+
+	It("only listens on loopback addresses", func() {
+		_, err := cnid.ListenHTTP("0.0.0.0:0")
+		Expect(err).To(MatchError(ContainSubstring("not a loopback address")))
+
+		l, err := cnid.ListenHTTP("127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		l.Close()
+	})
+})
@@ -0,0 +1,227 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package conformance exercises a plugin binary the way the spec says
+// runtimes may invoke it, and reports where the plugin deviates.
+package conformance
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/containernetworking/cni/pkg/types"
+)
+
+const (
+	StatusPass = "PASS"
+	StatusFail = "FAIL"
+	StatusSkip = "SKIP"
+)
+
+// Finding is the outcome of a single check
+type Finding struct {
+	Check   string `json:"check"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// Runner runs the checks against Plugin
+type Runner struct {
+	// Path of the plugin binary
+	Plugin string
+	// Network configuration handed to the plugin on stdin
+	NetConf []byte
+	// Network namespace and interface name used for ADD and DEL
+	NetNS  string
+	IfName string
+	// Value of CNI_PATH, so that e.g. IPAM plugins can be found.
+	// Defaults to the directory of Plugin.
+	Path string
+	// Receives the plugin's stderr, discarded if nil
+	Stderr io.Writer
+}
+
+type check struct {
+	name string
+	run  func(r *Runner) (status, message string)
+}
+
+var checks = []check{
+	{"VERSION reports the supported spec version", checkVersion},
+	{"unknown CNI_COMMAND is rejected", checkRejected("FOO", nil, nil)},
+	{"missing CNI_COMMAND is rejected", checkRejected("", []string{"CNI_COMMAND"}, nil)},
+	{"ADD without CNI_NETNS is rejected", checkRejected("ADD", []string{"CNI_NETNS"}, nil)},
+	{"ADD without CNI_IFNAME is rejected", checkRejected("ADD", []string{"CNI_IFNAME"}, nil)},
+	{"DEL without CNI_IFNAME is rejected", checkRejected("DEL", []string{"CNI_IFNAME"}, nil)},
+	{"ADD with malformed configuration is rejected", checkRejected("ADD", nil, []byte(`{"name": `))},
+	{"ADD succeeds and prints a result", checkAdd},
+	{"DEL succeeds", checkDel},
+	{"CHECK", func(*Runner) (string, string) {
+		return StatusSkip, "CHECK is not defined by this version of the spec"
+	}},
+}
+
+// Run runs all checks in order. ADD and DEL are issued against the real
+// netns, so the plugin is left detached from it when Run returns.
+func (r *Runner) Run() []Finding {
+	findings := []Finding{}
+	for _, c := range checks {
+		status, message := c.run(r)
+		findings = append(findings, Finding{c.name, status, message})
+	}
+	return findings
+}
+
+type output struct {
+	exitCode int
+	stdout   []byte
+}
+
+func (r *Runner) env(command string) map[string]string {
+	path := r.Path
+	if path == "" {
+		path = filepath.Dir(r.Plugin)
+	}
+	return map[string]string{
+		"CNI_COMMAND":     command,
+		"CNI_CONTAINERID": "cni-conformance",
+		"CNI_NETNS":       r.NetNS,
+		"CNI_IFNAME":      r.IfName,
+		"CNI_PATH":        path,
+	}
+}
+
+func (r *Runner) exec(env map[string]string, stdin []byte) (*output, error) {
+	environ := []string{}
+	for k, v := range env {
+		environ = append(environ, k+"="+v)
+	}
+
+	stdout := &bytes.Buffer{}
+	c := exec.Cmd{
+		Env:    environ,
+		Path:   r.Plugin,
+		Args:   []string{r.Plugin},
+		Stdin:  bytes.NewReader(stdin),
+		Stdout: stdout,
+		Stderr: r.Stderr,
+	}
+
+	out := &output{}
+	if err := c.Run(); err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return nil, err
+		}
+		out.exitCode = 1
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			out.exitCode = status.ExitStatus()
+		}
+	}
+	out.stdout = stdout.Bytes()
+	return out, nil
+}
+
+func checkVersion(r *Runner) (string, string) {
+	out, err := r.exec(map[string]string{"CNI_COMMAND": "VERSION"}, r.NetConf)
+	if err != nil {
+		return StatusFail, err.Error()
+	}
+	if out.exitCode != 0 {
+		return StatusFail, fmt.Sprintf("exited with %d: %s", out.exitCode, out.stdout)
+	}
+
+	v := struct {
+		CNIVersion string `json:"cniVersion"`
+	}{}
+	if err := json.Unmarshal(out.stdout, &v); err != nil {
+		return StatusFail, fmt.Sprintf("output %q is not JSON: %v", out.stdout, err)
+	}
+	if v.CNIVersion == "" {
+		return StatusFail, fmt.Sprintf("output %q has no cniVersion", out.stdout)
+	}
+	return StatusPass, fmt.Sprintf("cniVersion %s", v.CNIVersion)
+}
+
+// checkRejected invokes command with the unset variables removed and,
+// if stdin is given, instead of the network configuration. The plugin
+// has to fail and print an error in the CNI error format.
+func checkRejected(command string, unset []string, stdin []byte) func(r *Runner) (string, string) {
+	return func(r *Runner) (string, string) {
+		env := r.env(command)
+		for _, v := range unset {
+			delete(env, v)
+		}
+		input := stdin
+		if input == nil {
+			input = r.NetConf
+		}
+
+		out, err := r.exec(env, input)
+		if err != nil {
+			return StatusFail, err.Error()
+		}
+		if out.exitCode == 0 {
+			return StatusFail, "exited with 0"
+		}
+		return checkError(out.stdout)
+	}
+}
+
+func checkError(stdout []byte) (string, string) {
+	e := types.Error{}
+	if err := json.Unmarshal(stdout, &e); err != nil {
+		return StatusFail, fmt.Sprintf("error output %q is not JSON: %v", stdout, err)
+	}
+	switch {
+	case e.Code == 0:
+		return StatusFail, fmt.Sprintf("error output %q has no code", stdout)
+	case e.Msg == "":
+		return StatusFail, fmt.Sprintf("error output %q has no msg", stdout)
+	}
+	return StatusPass, e.Error()
+}
+
+func checkAdd(r *Runner) (string, string) {
+	out, err := r.exec(r.env("ADD"), r.NetConf)
+	if err != nil {
+		return StatusFail, err.Error()
+	}
+	if out.exitCode != 0 {
+		return StatusFail, fmt.Sprintf("exited with %d: %s", out.exitCode, strings.TrimSpace(string(out.stdout)))
+	}
+
+	result := types.Result{}
+	if err := json.Unmarshal(out.stdout, &result); err != nil {
+		return StatusFail, fmt.Sprintf("result %q is not valid: %v", out.stdout, err)
+	}
+	return StatusPass, result.String()
+}
+
+func checkDel(r *Runner) (string, string) {
+	out, err := r.exec(r.env("DEL"), r.NetConf)
+	if err != nil {
+		return StatusFail, err.Error()
+	}
+	if out.exitCode != 0 {
+		return StatusFail, fmt.Sprintf("exited with %d: %s", out.exitCode, strings.TrimSpace(string(out.stdout)))
+	}
+	return StatusPass, ""
+}
@@ -0,0 +1,83 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance_test
+
+import (
+	"github.com/containernetworking/cni/pkg/conformance"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Runner", func() {
+	statuses := func(findings []conformance.Finding) map[string]string {
+		s := make(map[string]string)
+		for _, f := range findings {
+			s[f.Check] = f.Status
+		}
+		return s
+	}
+
+	It("passes a plugin that validates its parameters", func() {
+		r := &conformance.Runner{
+			Plugin:  pathToNoop,
+			NetConf: []byte(`{"name": "test", "type": "noop"}`),
+			NetNS:   "/some/netns/path",
+			IfName:  "eth0",
+		}
+		s := statuses(r.Run())
+
+		Expect(s).To(HaveKeyWithValue("VERSION reports the supported spec version", conformance.StatusPass))
+		Expect(s).To(HaveKeyWithValue("unknown CNI_COMMAND is rejected", conformance.StatusPass))
+		Expect(s).To(HaveKeyWithValue("missing CNI_COMMAND is rejected", conformance.StatusPass))
+		Expect(s).To(HaveKeyWithValue("DEL without CNI_IFNAME is rejected", conformance.StatusPass))
+		Expect(s).To(HaveKeyWithValue("ADD without CNI_NETNS is rejected", conformance.StatusPass))
+		Expect(s).To(HaveKeyWithValue("ADD without CNI_IFNAME is rejected", conformance.StatusPass))
+		Expect(s).To(HaveKeyWithValue("ADD succeeds and prints a result", conformance.StatusPass))
+		Expect(s).To(HaveKeyWithValue("DEL succeeds", conformance.StatusPass))
+		Expect(s).To(HaveKeyWithValue("CHECK", conformance.StatusSkip))
+
+		// noop doesn't look at its configuration
+		Expect(s).To(HaveKeyWithValue("ADD with malformed configuration is rejected", conformance.StatusFail))
+	})
+
+	It("reports the deviations of a plugin that doesn't", func() {
+		r := &conformance.Runner{
+			Plugin:  pathToTestPlugin,
+			NetConf: []byte(`{"name": "test", "type": "test-plugin"}`),
+			NetNS:   "/some/netns/path",
+			IfName:  "eth0",
+		}
+		findings := r.Run()
+		s := statuses(findings)
+
+		Expect(s).To(HaveKeyWithValue("ADD with malformed configuration is rejected", conformance.StatusPass))
+		Expect(s).To(HaveKeyWithValue("unknown CNI_COMMAND is rejected", conformance.StatusFail))
+		Expect(s).To(HaveKeyWithValue("ADD without CNI_NETNS is rejected", conformance.StatusFail))
+
+		for _, f := range findings {
+			if f.Check == "ADD without CNI_NETNS is rejected" {
+				Expect(f.Message).To(Equal("exited with 0"))
+			}
+		}
+	})
+
+	It("fails every check for a missing plugin", func() {
+		r := &conformance.Runner{Plugin: "/some/missing/plugin"}
+		for _, f := range r.Run() {
+			Expect(f.Status).To(Or(Equal(conformance.StatusFail), Equal(conformance.StatusSkip)))
+		}
+	})
+})
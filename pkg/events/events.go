@@ -0,0 +1,163 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package events emits an event for every attach and detach of a
+// container, for consumers like audit systems and capacity dashboards
+// which shouldn't have to parse logs. skel emits them to the sink given
+// in the "events" section of the network configuration, or in the
+// CNI_EVENTS_SINK environment variable, which overrides it:
+//
+//	"events": {
+//		"sink": "journald"
+//	}
+package events
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SinkJournald sends events to the systemd journal. Any other sink
+	// is the path of a unix stream socket which gets each event as a
+	// line of JSON.
+	SinkJournald = "journald"
+
+	TypeAttach = "attach"
+	TypeDetach = "detach"
+)
+
+// journalSocket is where journald receives native protocol messages
+var journalSocket = "/run/systemd/journal/socket"
+
+// timeout bounds the time spent on emitting an event, so that a stuck
+// sink doesn't hold up the plugin
+const timeout = time.Second
+
+// Config is the "events" section of a network configuration
+type Config struct {
+	// Sink is "journald" or the path of a unix socket. No events are
+	// emitted unless it is set.
+	Sink string `json:"sink,omitempty"`
+}
+
+// Event describes an attach (ADD) or detach (DEL) of a container, which
+// may have failed
+type Event struct {
+	Time            time.Time `json:"time"`
+	Type            string    `json:"type"`
+	Plugin          string    `json:"plugin"`
+	Network         string    `json:"network"`
+	ContainerID     string    `json:"containerId"`
+	Netns           string    `json:"netns,omitempty"`
+	IfName          string    `json:"ifName"`
+	IPs             []string  `json:"ips,omitempty"`
+	DurationSeconds float64   `json:"durationSeconds"`
+	TraceID         string    `json:"traceId,omitempty"`
+	// Error and Code are the error the operation failed with, if any
+	Error string `json:"error,omitempty"`
+	Code  uint   `json:"code,omitempty"`
+}
+
+// SinkFromConfig returns the sink of c, or of CNI_EVENTS_SINK if set
+func SinkFromConfig(c Config, getenv func(string) string) string {
+	if sink := getenv("CNI_EVENTS_SINK"); sink != "" {
+		return sink
+	}
+	return c.Sink
+}
+
+// Emit sends e to sink
+func Emit(sink string, e *Event) error {
+	var (
+		network string
+		addr    string
+		msg     []byte
+	)
+	switch {
+	case sink == SinkJournald:
+		network, addr, msg = "unixgram", journalSocket, journalMessage(e)
+	case filepath.IsAbs(sink):
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		network, addr, msg = "unix", sink, append(data, '\n')
+	default:
+		return fmt.Errorf("event sink %q is neither %q nor an absolute path", sink, SinkJournald)
+	}
+
+	conn, err := net.DialTimeout(network, addr, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	_, err = conn.Write(msg)
+	return err
+}
+
+// journalMessage encodes e in the native protocol of journald, with the
+// event in CNI_* fields
+func journalMessage(e *Event) []byte {
+	priority := "6" // info
+	message := fmt.Sprintf("%s %s of container %s to network %s", e.Plugin, e.Type, e.ContainerID, e.Network)
+	if e.Error != "" {
+		priority = "3" // err
+		message += " failed: " + e.Error
+	}
+
+	fields := []struct{ key, value string }{
+		{"MESSAGE", message},
+		{"PRIORITY", priority},
+		{"SYSLOG_IDENTIFIER", e.Plugin},
+		{"CNI_EVENT", e.Type},
+		{"CNI_NETWORK", e.Network},
+		{"CNI_CONTAINERID", e.ContainerID},
+		{"CNI_NETNS", e.Netns},
+		{"CNI_IFNAME", e.IfName},
+		{"CNI_IPS", strings.Join(e.IPs, ",")},
+		{"CNI_DURATION_SECONDS", strconv.FormatFloat(e.DurationSeconds, 'f', -1, 64)},
+		{"CNI_TRACE_ID", e.TraceID},
+		{"CNI_ERROR", e.Error},
+	}
+	if e.Code != 0 {
+		fields = append(fields, struct{ key, value string }{"CNI_ERROR_CODE", strconv.FormatUint(uint64(e.Code), 10)})
+	}
+
+	b := &bytes.Buffer{}
+	for _, f := range fields {
+		if f.value == "" {
+			continue
+		}
+		if !strings.Contains(f.value, "\n") {
+			fmt.Fprintf(b, "%s=%s\n", f.key, f.value)
+			continue
+		}
+		// values spanning lines are sent with their length instead
+		b.WriteString(f.key + "\n")
+		binary.Write(b, binary.LittleEndian, uint64(len(f.value)))
+		b.WriteString(f.value + "\n")
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,153 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("events", func() {
+	var (
+		dir   string
+		event *Event
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "events")
+		Expect(err).NotTo(HaveOccurred())
+
+		event = &Event{
+			Time:            time.Date(2016, 9, 1, 10, 0, 0, 0, time.UTC),
+			Type:            TypeAttach,
+			Plugin:          "bridge",
+			Network:         "mynet",
+			ContainerID:     "some-container-id",
+			Netns:           "/var/run/netns/test",
+			IfName:          "eth0",
+			IPs:             []string{"10.1.2.3/24", "2001:db8::3/64"},
+			DurationSeconds: 0.25,
+			TraceID:         "some-trace-id",
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	Describe("SinkFromConfig", func() {
+		It("returns the sink of the configuration", func() {
+			getenv := func(string) string { return "" }
+			Expect(SinkFromConfig(Config{Sink: SinkJournald}, getenv)).To(Equal("journald"))
+			Expect(SinkFromConfig(Config{}, getenv)).To(BeEmpty())
+		})
+
+		It("lets CNI_EVENTS_SINK override it", func() {
+			getenv := func(key string) string {
+				if key == "CNI_EVENTS_SINK" {
+					return "/run/cni/events.sock"
+				}
+				return ""
+			}
+			Expect(SinkFromConfig(Config{Sink: SinkJournald}, getenv)).To(Equal("/run/cni/events.sock"))
+		})
+	})
+
+	It("writes events to a socket as lines of JSON", func() {
+		path := filepath.Join(dir, "events.sock")
+		l, err := net.Listen("unix", path)
+		Expect(err).NotTo(HaveOccurred())
+		defer l.Close()
+
+		lines := make(chan string, 1)
+		go func() {
+			defer GinkgoRecover()
+			conn, err := l.Accept()
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+			line, err := bufio.NewReader(conn).ReadString('\n')
+			Expect(err).NotTo(HaveOccurred())
+			lines <- line
+		}()
+
+		Expect(Emit(path, event)).To(Succeed())
+
+		var line string
+		Eventually(lines).Should(Receive(&line))
+		Expect(line).To(HaveSuffix("\n"))
+		Expect(line).To(MatchJSON(`{
+			"time": "2016-09-01T10:00:00Z",
+			"type": "attach",
+			"plugin": "bridge",
+			"network": "mynet",
+			"containerId": "some-container-id",
+			"netns": "/var/run/netns/test",
+			"ifName": "eth0",
+			"ips": ["10.1.2.3/24", "2001:db8::3/64"],
+			"durationSeconds": 0.25,
+			"traceId": "some-trace-id"
+		}`))
+
+		decoded := &Event{}
+		Expect(json.Unmarshal([]byte(line), decoded)).To(Succeed())
+		Expect(decoded).To(Equal(event))
+	})
+
+	It("sends events to journald in its native protocol", func() {
+		journalSocket = filepath.Join(dir, "journal.sock")
+		defer func() { journalSocket = "/run/systemd/journal/socket" }()
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+
+		event.Error = "failed to set up\nthe bridge"
+		event.Code = 100
+		Expect(Emit(SinkJournald, event)).To(Succeed())
+
+		buf := make([]byte, 4096)
+		n, err := conn.Read(buf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(buf[:n])).To(Equal("MESSAGE\n" +
+			"\x61\x00\x00\x00\x00\x00\x00\x00" +
+			"bridge attach of container some-container-id to network mynet failed: failed to set up\nthe bridge\n" +
+			"PRIORITY=3\n" +
+			"SYSLOG_IDENTIFIER=bridge\n" +
+			"CNI_EVENT=attach\n" +
+			"CNI_NETWORK=mynet\n" +
+			"CNI_CONTAINERID=some-container-id\n" +
+			"CNI_NETNS=/var/run/netns/test\n" +
+			"CNI_IFNAME=eth0\n" +
+			"CNI_IPS=10.1.2.3/24,2001:db8::3/64\n" +
+			"CNI_DURATION_SECONDS=0.25\n" +
+			"CNI_TRACE_ID=some-trace-id\n" +
+			"CNI_ERROR\n" +
+			"\x1b\x00\x00\x00\x00\x00\x00\x00" +
+			"failed to set up\nthe bridge\n" +
+			"CNI_ERROR_CODE=100\n"))
+	})
+
+	It("rejects a sink that is no absolute path", func() {
+		Expect(Emit("events.sock", event)).To(MatchError(`event sink "events.sock" is neither "journald" nor an absolute path`))
+	})
+})
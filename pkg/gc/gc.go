@@ -0,0 +1,374 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gc removes what CNI plugins left on a node for containers which
+// no longer exist, after their DEL failed or was never called.
+package gc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/rpc"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/cni/pkg/state"
+	"github.com/containernetworking/cni/plugins/ipam/host-local/backend/disk"
+	"github.com/coreos/go-iptables/iptables"
+)
+
+const (
+	KindAllocation = "host-local allocation"
+	KindLease      = "dhcp lease"
+	KindChain      = "iptables chain"
+)
+
+// Orphan is a resource held for a container which is gone
+type Orphan struct {
+	Kind        string `json:"kind"`
+	Network     string `json:"network"`
+	Pool        string `json:"pool,omitempty"`
+	ContainerID string `json:"containerId"`
+	// Name is the IP of an allocation or lease, or the name of a chain
+	Name string `json:"name"`
+	// Error is set if the orphan could not be removed
+	Error string `json:"error,omitempty"`
+}
+
+func (o Orphan) String() string {
+	where := o.Network
+	if o.Pool != "" {
+		where += "/" + o.Pool
+	}
+	s := fmt.Sprintf("%s %s of %s in %s", o.Kind, o.Name, o.ContainerID, where)
+	if o.Error != "" {
+		s += ": " + o.Error
+	}
+	return s
+}
+
+// Options select where to look for orphans and which containers exist
+type Options struct {
+	// Data dir of host-local, defaults to /var/lib/cni/networks
+	DataDir string
+	// Socket of the dhcp daemon, defaults to state.DefaultDHCPSocket
+	DHCPSocket string
+	// IDs of the containers known to the runtime. Everything held for
+	// other containers is an orphan, so this must be complete.
+	Containers []string
+	// Resources reserved after Cutoff are left alone along with all
+	// other resources of their container, which may be in the middle of
+	// its ADD and so missing from Containers. The zero Cutoff leaves
+	// nothing alone.
+	Cutoff time.Time
+	// DryRun finds the orphans without removing them
+	DryRun bool
+}
+
+// table is the part of iptables.IPTables used to find and remove chains
+type table interface {
+	List(table, chain string) ([]string, error)
+	Delete(table, chain string, rulespec ...string) error
+	ClearChain(table, chain string) error
+	DeleteChain(table, chain string) error
+}
+
+// newTable returns the iptables of the node, or nil if there are none
+var newTable = func() table {
+	ipt, err := iptables.New()
+	if err != nil {
+		return nil
+	}
+	return ipt
+}
+
+// Collect finds the orphans of all components and removes them, unless
+// opts.DryRun is set. Orphans which could not be removed have their Error
+// set; err is only set if the orphans could not be found.
+func Collect(opts Options) ([]Orphan, error) {
+	if opts.Containers == nil {
+		return nil, fmt.Errorf("the containers known to the runtime are required")
+	}
+
+	s, err := state.Inspect(state.Options{
+		DataDir:    opts.DataDir,
+		DHCPSocket: opts.DHCPSocket,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	live := map[string]bool{}
+	for _, id := range opts.Containers {
+		live[id] = true
+	}
+	recent, err := recentContainers(opts, s)
+	if err != nil {
+		return nil, err
+	}
+	for id := range recent {
+		live[id] = true
+	}
+
+	orphans := []Orphan{}
+	orphans = append(orphans, collectAllocations(opts, s.Allocations, live)...)
+	orphans = append(orphans, collectLeases(opts, s.Leases, live)...)
+	if ipt := newTable(); ipt != nil {
+		chains, err := collectChains(opts, ipt, live)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list iptables rules: %v", err)
+		}
+		orphans = append(orphans, chains...)
+	}
+	return orphans, nil
+}
+
+// recentContainers returns the IDs of the containers with an allocation or
+// lease newer than opts.Cutoff. Stores which don't know when an allocation
+// was made don't make its container recent.
+func recentContainers(opts Options, s *state.State) (map[string]bool, error) {
+	recent := map[string]bool{}
+	if opts.Cutoff.IsZero() {
+		return recent, nil
+	}
+
+	type storeName struct{ network, pool string }
+	times := map[storeName]map[string]time.Time{}
+	for _, a := range s.Allocations {
+		key := storeName{a.Network, a.Pool}
+		if _, ok := times[key]; !ok {
+			t, err := reservationTimes(opts.DataDir, a.Network, a.Pool)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read host-local state: %v", err)
+			}
+			times[key] = t
+		}
+		if times[key][a.IP].After(opts.Cutoff) {
+			recent[a.ContainerID] = true
+		}
+	}
+	for _, l := range s.Leases {
+		if l.Acquired.After(opts.Cutoff) {
+			recent[l.ContainerID] = true
+		}
+	}
+	return recent, nil
+}
+
+func reservationTimes(dataDir, network, pool string) (map[string]time.Time, error) {
+	store, err := disk.New(dataDir, network, pool)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	return store.ReservationTimes()
+}
+
+func collectAllocations(opts Options, allocations []state.Allocation, live map[string]bool) []Orphan {
+	orphans := []Orphan{}
+	// all addresses of a container in a store are released at once
+	type storeID struct{ network, pool, id string }
+	released := map[storeID]error{}
+	for _, a := range allocations {
+		if live[a.ContainerID] {
+			continue
+		}
+		o := Orphan{
+			Kind:        KindAllocation,
+			Network:     a.Network,
+			Pool:        a.Pool,
+			ContainerID: a.ContainerID,
+			Name:        a.IP,
+		}
+		if !opts.DryRun {
+			key := storeID{a.Network, a.Pool, a.ContainerID}
+			err, ok := released[key]
+			if !ok {
+				err = releaseAllocations(opts.DataDir, a.Network, a.Pool, a.ContainerID)
+				released[key] = err
+			}
+			if err != nil {
+				o.Error = err.Error()
+			}
+		}
+		orphans = append(orphans, o)
+	}
+	return orphans
+}
+
+func releaseAllocations(dataDir, network, pool, id string) error {
+	store, err := disk.New(dataDir, network, pool)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	if err := store.Lock(); err != nil {
+		return err
+	}
+	defer store.Unlock()
+
+	// the container was neither known nor recent when the reservations
+	// were read, so an ADD racing with this one can only be for a
+	// container that was already deleted
+	return store.ReleaseByID(id)
+}
+
+func collectLeases(opts Options, leases []state.Lease, live map[string]bool) []Orphan {
+	orphans := []Orphan{}
+	for _, l := range leases {
+		if live[l.ContainerID] {
+			if _, err := os.Stat(l.Netns); err == nil {
+				continue
+			}
+		}
+		o := Orphan{
+			Kind:        KindLease,
+			Network:     l.Network,
+			ContainerID: l.ContainerID,
+			Name:        l.IP,
+		}
+		if !opts.DryRun {
+			if err := releaseLease(opts.DHCPSocket, l); err != nil {
+				o.Error = err.Error()
+			}
+		}
+		orphans = append(orphans, o)
+	}
+	return orphans
+}
+
+func releaseLease(socketPath string, l state.Lease) error {
+	if socketPath == "" {
+		socketPath = state.DefaultDHCPSocket
+	}
+	client, err := rpc.DialHTTP("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	netconf, err := json.Marshal(struct {
+		Name string `json:"name"`
+	}{l.Network})
+	if err != nil {
+		return err
+	}
+	args := &skel.CmdArgs{
+		ContainerID: l.ContainerID,
+		Netns:       l.Netns,
+		IfName:      l.IfName,
+		StdinData:   netconf,
+	}
+	return client.Call("DHCP.Release", args, &struct{}{})
+}
+
+// chainComment matches the comment of the rules installed by
+// ip.SetupIPMasq, as formatted by utils.FormatComment
+var chainComment = regexp.MustCompile(`^name: "(.*)" id: "(.*)"$`)
+
+// collectChains finds the masquerading chains of bridge and ptp by the
+// POSTROUTING rules jumping to them
+func collectChains(opts Options, ipt table, live map[string]bool) ([]Orphan, error) {
+	rules, err := ipt.List("nat", "POSTROUTING")
+	if err != nil {
+		return nil, err
+	}
+
+	orphans := []Orphan{}
+	for _, rule := range rules {
+		args := splitRule(rule)
+		if len(args) < 2 || args[0] != "-A" {
+			continue
+		}
+		rulespec := args[2:]
+		chain := argValue(rulespec, "-j")
+		m := chainComment.FindStringSubmatch(argValue(rulespec, "--comment"))
+		if !strings.HasPrefix(chain, "CNI-") || m == nil || live[m[2]] {
+			continue
+		}
+
+		o := Orphan{
+			Kind:        KindChain,
+			Network:     m[1],
+			ContainerID: m[2],
+			Name:        chain,
+		}
+		if !opts.DryRun {
+			if err := deleteChain(ipt, chain, rulespec); err != nil {
+				o.Error = err.Error()
+			}
+		}
+		orphans = append(orphans, o)
+	}
+	return orphans, nil
+}
+
+// deleteChain is ip.TeardownIPMasq for a rule which is known as listed
+func deleteChain(ipt table, chain string, rulespec []string) error {
+	if err := ipt.Delete("nat", "POSTROUTING", rulespec...); err != nil {
+		return err
+	}
+	if err := ipt.ClearChain("nat", chain); err != nil {
+		return err
+	}
+	return ipt.DeleteChain("nat", chain)
+}
+
+// argValue returns the argument following flag in args
+func argValue(args []string, flag string) string {
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == flag {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// splitRule splits a rule as printed by iptables -S into its arguments.
+// iptables quotes arguments with spaces in double quotes, escaping double
+// quotes and backslashes within.
+func splitRule(rule string) []string {
+	args := []string{}
+	var (
+		arg      []byte
+		inArg    bool
+		inQuotes bool
+	)
+	for i := 0; i < len(rule); i++ {
+		c := rule[i]
+		switch {
+		case c == '\\' && inQuotes && i+1 < len(rule):
+			i++
+			arg = append(arg, rule[i])
+		case c == '"':
+			inQuotes = !inQuotes
+			inArg = true
+		case c == ' ' && !inQuotes:
+			if inArg {
+				args = append(args, string(arg))
+			}
+			arg, inArg = nil, false
+		default:
+			arg = append(arg, c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, string(arg))
+	}
+	return args
+}
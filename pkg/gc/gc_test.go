@@ -0,0 +1,260 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/cni/pkg/state"
+	"github.com/containernetworking/cni/plugins/ipam/host-local/backend/disk"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakeDHCP struct {
+	leases   []state.Lease
+	released []string
+}
+
+func (d *fakeDHCP) Leases(args struct{}, reply *[]state.Lease) error {
+	*reply = d.leases
+	return nil
+}
+
+func (d *fakeDHCP) Release(args *skel.CmdArgs, reply *struct{}) error {
+	conf := struct {
+		Name string `json:"name"`
+	}{}
+	if err := json.Unmarshal(args.StdinData, &conf); err != nil {
+		return err
+	}
+	d.released = append(d.released, args.ContainerID+"/"+conf.Name)
+	return nil
+}
+
+type fakeTable struct {
+	rules []string
+	calls []string
+}
+
+func (t *fakeTable) List(table, chain string) ([]string, error) {
+	return t.rules, nil
+}
+
+func (t *fakeTable) Delete(table, chain string, rulespec ...string) error {
+	t.calls = append(t.calls, strings.Join(append([]string{"-D", chain}, rulespec...), "|"))
+	return nil
+}
+
+func (t *fakeTable) ClearChain(table, chain string) error {
+	t.calls = append(t.calls, "-F|"+chain)
+	return nil
+}
+
+func (t *fakeTable) DeleteChain(table, chain string) error {
+	t.calls = append(t.calls, "-X|"+chain)
+	return nil
+}
+
+var _ = Describe("Collect", func() {
+	var (
+		tmpDir string
+		opts   Options
+		ipt    *fakeTable
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "cni-gc")
+		Expect(err).NotTo(HaveOccurred())
+		opts = Options{
+			DataDir:    filepath.Join(tmpDir, "networks"),
+			DHCPSocket: filepath.Join(tmpDir, "dhcp.sock"),
+			Containers: []string{"c1"},
+		}
+		ipt = &fakeTable{}
+		newTable = func() table { return ipt }
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	reserve := func(network, pool, id, ip string) {
+		store, err := disk.New(opts.DataDir, network, pool)
+		Expect(err).NotTo(HaveOccurred())
+		defer store.Close()
+		reserved, err := store.Reserve(id, net.ParseIP(ip))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reserved).To(BeTrue())
+	}
+
+	reservations := func(network, pool string) map[string]string {
+		store, err := disk.New(opts.DataDir, network, pool)
+		Expect(err).NotTo(HaveOccurred())
+		defer store.Close()
+		r, err := store.Reservations()
+		Expect(err).NotTo(HaveOccurred())
+		return r
+	}
+
+	It("requires the containers known to the runtime", func() {
+		opts.Containers = nil
+		_, err := Collect(opts)
+		Expect(err).To(MatchError("the containers known to the runtime are required"))
+	})
+
+	It("releases the host-local allocations of unknown containers", func() {
+		reserve("net1", "", "c1", "10.0.0.2")
+		reserve("net1", "", "gone", "10.0.0.3")
+		reserve("net1", "", "gone", "10.0.0.4")
+		reserve("net1", "tenant", "gone", "10.0.0.2")
+
+		orphans, err := Collect(opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orphans).To(Equal([]Orphan{
+			{Kind: KindAllocation, Network: "net1", ContainerID: "gone", Name: "10.0.0.3"},
+			{Kind: KindAllocation, Network: "net1", ContainerID: "gone", Name: "10.0.0.4"},
+			{Kind: KindAllocation, Network: "net1", Pool: "tenant", ContainerID: "gone", Name: "10.0.0.2"},
+		}))
+		Expect(reservations("net1", "")).To(Equal(map[string]string{"10.0.0.2": "c1"}))
+		Expect(reservations("net1", "tenant")).To(BeEmpty())
+	})
+
+	It("leaves the resources of containers attached after the cutoff alone", func() {
+		opts.Cutoff = time.Now().Add(-time.Minute)
+		reserve("net1", "", "gone", "10.0.0.3")
+		old := time.Now().Add(-time.Hour)
+		Expect(os.Chtimes(filepath.Join(opts.DataDir, "net1", "10.0.0.3"), old, old)).To(Succeed())
+		reserve("net1", "", "fresh", "10.0.0.4")
+		reserve("net1", "tenant", "fresh", "10.0.0.2")
+		ipt.rules = []string{
+			`-A POSTROUTING -s 10.0.0.4/24 -m comment --comment "name: \"net1\" id: \"fresh\"" -j CNI-fresh`,
+		}
+
+		orphans, err := Collect(opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orphans).To(Equal([]Orphan{
+			{Kind: KindAllocation, Network: "net1", ContainerID: "gone", Name: "10.0.0.3"},
+		}))
+		Expect(reservations("net1", "")).To(Equal(map[string]string{"10.0.0.4": "fresh"}))
+		Expect(reservations("net1", "tenant")).To(HaveLen(1))
+		Expect(ipt.calls).To(BeEmpty())
+	})
+
+	It("removes masquerading chains of unknown containers", func() {
+		ipt.rules = []string{
+			`-P POSTROUTING ACCEPT`,
+			`-A POSTROUTING -s 10.0.0.2/24 -m comment --comment "name: \"net1\" id: \"c1\"" -j CNI-live`,
+			`-A POSTROUTING -s 10.0.0.3/24 -m comment --comment "name: \"net1\" id: \"gone\"" -j CNI-gone`,
+			`-A POSTROUTING -s 10.0.0.0/24 -m comment --comment "name: \"net1\" id: \"gone\"" -j MASQUERADE`,
+			`-A POSTROUTING -s 10.1.0.0/16 -j CNI-other`,
+		}
+
+		orphans, err := Collect(opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orphans).To(Equal([]Orphan{
+			{Kind: KindChain, Network: "net1", ContainerID: "gone", Name: "CNI-gone"},
+		}))
+		Expect(ipt.calls).To(Equal([]string{
+			`-D|POSTROUTING|-s|10.0.0.3/24|-m|comment|--comment|name: "net1" id: "gone"|-j|CNI-gone`,
+			"-F|CNI-gone",
+			"-X|CNI-gone",
+		}))
+	})
+
+	Context("when the dhcp daemon is running", func() {
+		var (
+			listener net.Listener
+			dhcp     *fakeDHCP
+		)
+
+		BeforeEach(func() {
+			var err error
+			listener, err = net.Listen("unix", opts.DHCPSocket)
+			Expect(err).NotTo(HaveOccurred())
+
+			dhcp = &fakeDHCP{leases: []state.Lease{
+				{Network: "net1", ContainerID: "c1", Netns: tmpDir, IfName: "eth0", IP: "192.168.1.2"},
+				{Network: "net1", ContainerID: "c1", Netns: filepath.Join(tmpDir, "gone"), IfName: "eth1", IP: "192.168.1.3"},
+				{Network: "net2", ContainerID: "gone", Netns: tmpDir, IfName: "eth0", IP: "192.168.2.2"},
+			}}
+			server := rpc.NewServer()
+			Expect(server.RegisterName("DHCP", dhcp)).To(Succeed())
+			go http.Serve(listener, server)
+		})
+
+		AfterEach(func() {
+			listener.Close()
+		})
+
+		It("releases leases of unknown containers and of netns which are gone", func() {
+			orphans, err := Collect(opts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(orphans).To(Equal([]Orphan{
+				{Kind: KindLease, Network: "net1", ContainerID: "c1", Name: "192.168.1.3"},
+				{Kind: KindLease, Network: "net2", ContainerID: "gone", Name: "192.168.2.2"},
+			}))
+			Expect(dhcp.released).To(Equal([]string{"c1/net1", "gone/net2"}))
+		})
+
+		It("leaves leases acquired after the cutoff alone", func() {
+			opts.Cutoff = time.Now().Add(-time.Minute)
+			dhcp.leases[2].Acquired = time.Now()
+
+			orphans, err := Collect(opts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(orphans).To(Equal([]Orphan{
+				{Kind: KindLease, Network: "net1", ContainerID: "c1", Name: "192.168.1.3"},
+			}))
+			Expect(dhcp.released).To(Equal([]string{"c1/net1"}))
+		})
+	})
+
+	Context("in dry-run mode", func() {
+		It("finds the orphans without removing them", func() {
+			opts.DryRun = true
+			reserve("net1", "", "gone", "10.0.0.3")
+			ipt.rules = []string{
+				`-A POSTROUTING -s 10.0.0.3/24 -m comment --comment "name: \"net1\" id: \"gone\"" -j CNI-gone`,
+			}
+
+			orphans, err := Collect(opts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(orphans).To(HaveLen(2))
+			Expect(reservations("net1", "")).To(HaveLen(1))
+			Expect(ipt.calls).To(BeEmpty())
+		})
+	})
+})
+
+var _ = Describe("splitRule", func() {
+	It("splits quoted arguments", func() {
+		Expect(splitRule(`-A POSTROUTING -m comment --comment "a \"b\" \\c" -j X`)).To(Equal([]string{
+			"-A", "POSTROUTING", "-m", "comment", "--comment", `a "b" \c`, "-j", "X",
+		}))
+		Expect(splitRule(`-A X --comment ""`)).To(Equal([]string{"-A", "X", "--comment", ""}))
+	})
+})
@@ -0,0 +1,111 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hooks runs site-specific executables before and after the ADD
+// and DEL of a plugin. skel runs the executables of the directory given
+// in the "hooks" section of the network configuration, or in the
+// CNI_HOOKS_DIR environment variable, which overrides it:
+//
+//	"hooks": {
+//		"dir": "/etc/cni/hooks.d"
+//	}
+package hooks
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/containernetworking/cni/pkg/logging"
+)
+
+const (
+	// StagePre and StagePost are the values of CNI_HOOK for the hooks
+	// run before and after the plugin
+	StagePre  = "pre"
+	StagePost = "post"
+)
+
+// Config is the "hooks" section of a network configuration
+type Config struct {
+	// Dir is the directory of the hook executables. No hooks are run
+	// unless it is set.
+	Dir string `json:"dir,omitempty"`
+}
+
+// DirFromConfig returns the hook directory of c, or of CNI_HOOKS_DIR if
+// set
+func DirFromConfig(c Config, getenv func(string) string) string {
+	if dir := getenv("CNI_HOOKS_DIR"); dir != "" {
+		return dir
+	}
+	return c.Dir
+}
+
+// List returns the paths of the hooks in dir, in the order they are run.
+// These are the executable files not starting with a dot, sorted by
+// name. A dir which doesn't exist has no hooks.
+func List(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hook dir: %v", err)
+	}
+
+	paths := []string{}
+	for _, info := range infos {
+		if strings.HasPrefix(info.Name(), ".") || !info.Mode().IsRegular() || info.Mode()&0111 == 0 {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, info.Name()))
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Run runs the hooks of dir one after the other, with env plus CNI_HOOK
+// set to stage, and stdin on their standard input. It stops at the first
+// hook which fails.
+func Run(dir, stage string, env []string, stdin []byte) error {
+	paths, err := List(dir)
+	if err != nil {
+		return err
+	}
+
+	env = append(append([]string{}, env...), "CNI_HOOK="+stage)
+	for _, path := range paths {
+		logging.Debugf("running %s hook %s", stage, path)
+
+		output := &bytes.Buffer{}
+		c := exec.Cmd{
+			Path:   path,
+			Args:   []string{path},
+			Env:    env,
+			Stdin:  bytes.NewReader(stdin),
+			Stdout: output,
+			Stderr: output,
+		}
+		if err := c.Run(); err != nil {
+			return fmt.Errorf("%s hook %s failed: %v: %s", stage, filepath.Base(path), err, strings.TrimSpace(output.String()))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hooks_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/containernetworking/cni/pkg/hooks"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("hooks", func() {
+	var dir, logFile string
+
+	writeHook := func(name, script string, mode os.FileMode) {
+		Expect(ioutil.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), mode)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "hooks")
+		Expect(err).NotTo(HaveOccurred())
+		logFile = filepath.Join(dir, "log")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	Describe("DirFromConfig", func() {
+		It("returns the hook dir of the configuration", func() {
+			getenv := func(string) string { return "" }
+			Expect(hooks.DirFromConfig(hooks.Config{Dir: "/etc/cni/hooks.d"}, getenv)).To(Equal("/etc/cni/hooks.d"))
+			Expect(hooks.DirFromConfig(hooks.Config{}, getenv)).To(BeEmpty())
+		})
+
+		It("lets CNI_HOOKS_DIR override it", func() {
+			getenv := func(key string) string {
+				if key == "CNI_HOOKS_DIR" {
+					return "/run/hooks"
+				}
+				return ""
+			}
+			Expect(hooks.DirFromConfig(hooks.Config{Dir: "/etc/cni/hooks.d"}, getenv)).To(Equal("/run/hooks"))
+		})
+	})
+
+	Describe("List", func() {
+		It("lists the executables of the dir by name", func() {
+			writeHook("20-b", "", 0755)
+			writeHook("10-a", "", 0700)
+			writeHook(".hidden", "", 0755)
+			writeHook("README", "", 0644)
+			Expect(os.Mkdir(filepath.Join(dir, "30-dir"), 0755)).To(Succeed())
+
+			paths, err := hooks.List(dir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(Equal([]string{filepath.Join(dir, "10-a"), filepath.Join(dir, "20-b")}))
+		})
+
+		It("has no hooks for a dir that doesn't exist", func() {
+			paths, err := hooks.List(filepath.Join(dir, "missing"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(BeEmpty())
+		})
+	})
+
+	Describe("Run", func() {
+		It("runs the hooks with the environment and stdin", func() {
+			writeHook("10-a", `echo "a $CNI_HOOK $CNI_COMMAND $(cat)" >> `+logFile+"\n", 0755)
+			writeHook("20-b", `echo "b $CNI_HOOK $CNI_COMMAND $(cat)" >> `+logFile+"\n", 0755)
+
+			Expect(hooks.Run(dir, hooks.StagePost, []string{"CNI_COMMAND=DEL"}, []byte(`{}`))).To(Succeed())
+
+			log, err := ioutil.ReadFile(logFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(log)).To(Equal("a post DEL {}\nb post DEL {}\n"))
+		})
+
+		It("stops at the first hook that fails", func() {
+			writeHook("10-a", "echo something went wrong >&2\nexit 3\n", 0755)
+			writeHook("20-b", "touch "+logFile+"\n", 0755)
+
+			err := hooks.Run(dir, hooks.StagePre, nil, nil)
+			Expect(err).To(MatchError("pre hook 10-a failed: exit status 3: something went wrong"))
+			Expect(logFile).NotTo(BeAnExistingFile())
+		})
+	})
+})
@@ -16,6 +16,7 @@ package invoke
 
 import (
 	"os"
+	"runtime"
 	"strings"
 )
 
@@ -45,10 +46,24 @@ type Args struct {
 	PluginArgsStr string
 	IfName        string
 	Path          string
+	// TraceID is passed as CNI_TRACE_ID. If empty, the plugin inherits
+	// the trace ID of this process, if any.
+	TraceID string
 }
 
+// AsEnv returns the environment of this process with the CNI_* variables
+// of args. Inherited values of these variables are dropped, so that the
+// plugin doesn't have to pick between duplicates, which Windows matches
+// regardless of case.
 func (args *Args) AsEnv() []string {
-	env := os.Environ()
+	env := []string{}
+	for _, kv := range os.Environ() {
+		if isArgsEnv(kv, argsEnv) || (args.TraceID != "" && isArgsEnv(kv, []string{"CNI_TRACE_ID"})) {
+			continue
+		}
+		env = append(env, kv)
+	}
+
 	pluginArgsStr := args.PluginArgsStr
 	if pluginArgsStr == "" {
 		pluginArgsStr = stringify(args.PluginArgs)
@@ -61,9 +76,24 @@ func (args *Args) AsEnv() []string {
 		"CNI_ARGS="+pluginArgsStr,
 		"CNI_IFNAME="+args.IfName,
 		"CNI_PATH="+args.Path)
+	if args.TraceID != "" {
+		env = append(env, "CNI_TRACE_ID="+args.TraceID)
+	}
 	return env
 }
 
+var argsEnv = []string{"CNI_COMMAND", "CNI_CONTAINERID", "CNI_NETNS", "CNI_ARGS", "CNI_IFNAME", "CNI_PATH"}
+
+func isArgsEnv(kv string, names []string) bool {
+	key := strings.SplitN(kv, "=", 2)[0]
+	for _, name := range names {
+		if key == name || (runtime.GOOS == "windows" && strings.EqualFold(key, name)) {
+			return true
+		}
+	}
+	return false
+}
+
 // taken from rkt/networking/net_plugin.go
 func stringify(pluginArgs [][2]string) string {
 	entries := make([]string, len(pluginArgs))
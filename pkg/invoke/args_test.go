@@ -0,0 +1,77 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package invoke_test
+
+import (
+	"os"
+	"strings"
+
+	"github.com/containernetworking/cni/pkg/invoke"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Args", func() {
+	BeforeEach(func() {
+		os.Setenv("CNI_COMMAND", "ADD")
+		os.Setenv("CNI_IFNAME", "eth0")
+		os.Setenv("CNI_OTHER", "kept")
+	})
+
+	AfterEach(func() {
+		os.Unsetenv("CNI_COMMAND")
+		os.Unsetenv("CNI_IFNAME")
+		os.Unsetenv("CNI_OTHER")
+		os.Unsetenv("CNI_TRACE_ID")
+	})
+
+	envValues := func(args *invoke.Args) map[string][]string {
+		values := map[string][]string{}
+		for _, kv := range args.AsEnv() {
+			parts := strings.SplitN(kv, "=", 2)
+			values[parts[0]] = append(values[parts[0]], parts[1])
+		}
+		return values
+	}
+
+	It("replaces the inherited CNI variables", func() {
+		args := &invoke.Args{
+			Command:    "DEL",
+			IfName:     "eth1",
+			PluginArgs: [][2]string{{"K", "V"}},
+		}
+
+		values := envValues(args)
+		Expect(values).To(HaveKeyWithValue("CNI_COMMAND", []string{"DEL"}))
+		Expect(values).To(HaveKeyWithValue("CNI_IFNAME", []string{"eth1"}))
+		Expect(values).To(HaveKeyWithValue("CNI_ARGS", []string{"K=V"}))
+		Expect(values).To(HaveKeyWithValue("CNI_OTHER", []string{"kept"}))
+	})
+	Context("with a trace ID", func() {
+		BeforeEach(func() {
+			os.Setenv("CNI_TRACE_ID", "inherited")
+		})
+
+		It("passes on the inherited one", func() {
+			values := envValues(&invoke.Args{Command: "ADD"})
+			Expect(values).To(HaveKeyWithValue("CNI_TRACE_ID", []string{"inherited"}))
+		})
+
+		It("replaces the inherited one if set", func() {
+			values := envValues(&invoke.Args{Command: "ADD", TraceID: "abc"})
+			Expect(values).To(HaveKeyWithValue("CNI_TRACE_ID", []string{"abc"}))
+		})
+	})
+})
@@ -20,6 +20,7 @@ import (
 	"os"
 
 	"github.com/containernetworking/cni/pkg/invoke"
+	"github.com/containernetworking/cni/pkg/types"
 
 	noop_debug "github.com/containernetworking/cni/plugins/test/noop/debug"
 
@@ -111,6 +112,12 @@ var _ = Describe("RawExec", func() {
 			Expect(err).To(HaveOccurred())
 			Expect(err).To(MatchError("banana"))
 		})
+
+		It("returns the error reported by the plugin", func() {
+			_, err := execer.ExecPlugin(pathToPlugin, stdin, environ)
+			Expect(err).To(BeAssignableToTypeOf(&types.Error{}))
+			Expect(err.(*types.Error).Code).To(BeEquivalentTo(100))
+		})
 	})
 
 	Context("when the system is unable to execute the plugin", func() {
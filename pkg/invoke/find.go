@@ -18,8 +18,19 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 )
 
+// ExecutableFileExtensions are tried in turn as suffix of a plugin name
+// when looking up its binary. Windows binaries have an .exe suffix.
+var ExecutableFileExtensions = []string{""}
+
+func init() {
+	if runtime.GOOS == "windows" {
+		ExecutableFileExtensions = []string{".exe", ""}
+	}
+}
+
 // FindInPath returns the full path of the plugin by searching in the provided path
 func FindInPath(plugin string, paths []string) (string, error) {
 	if plugin == "" {
@@ -30,18 +41,14 @@ func FindInPath(plugin string, paths []string) (string, error) {
 		return "", fmt.Errorf("no paths provided")
 	}
 
-	var fullpath string
 	for _, path := range paths {
-		full := filepath.Join(path, plugin)
-		if fi, err := os.Stat(full); err == nil && fi.Mode().IsRegular() {
-			fullpath = full
-			break
+		for _, ext := range ExecutableFileExtensions {
+			full := filepath.Join(path, plugin+ext)
+			if fi, err := os.Stat(full); err == nil && fi.Mode().IsRegular() {
+				return full, nil
+			}
 		}
 	}
 
-	if fullpath == "" {
-		return "", fmt.Errorf("failed to find plugin %q in path %s", plugin, paths)
-	}
-
-	return fullpath, nil
+	return "", fmt.Errorf("failed to find plugin %q in path %s", plugin, paths)
 }
@@ -52,6 +52,33 @@ var _ = Describe("FindInPath", func() {
 		})
 	})
 
+	Context("when executable file extensions are configured", func() {
+		var extensions []string
+
+		BeforeEach(func() {
+			extensions = invoke.ExecutableFileExtensions
+			invoke.ExecutableFileExtensions = []string{".exe", ""}
+		})
+
+		AfterEach(func() {
+			invoke.ExecutableFileExtensions = extensions
+		})
+
+		It("finds plugins with the extension", func() {
+			Expect(ioutil.WriteFile(filepath.Join(anotherTempDir, "windows-plugin.exe"), nil, 0700)).To(Succeed())
+
+			pluginPath, err := invoke.FindInPath("windows-plugin", multiplePaths)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pluginPath).To(Equal(filepath.Join(anotherTempDir, "windows-plugin.exe")))
+		})
+
+		It("still finds plugins without it", func() {
+			pluginPath, err := invoke.FindInPath(pluginName, multiplePaths)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pluginPath).To(Equal(filepath.Join(pluginDir, pluginName)))
+		})
+	})
+
 	Context("when an error occurs", func() {
 		Context("when no paths are provided", func() {
 			It("returns an error noting no paths were provided", func() {
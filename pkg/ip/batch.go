@@ -0,0 +1,178 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ip
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"github.com/vishvananda/netns"
+)
+
+// Batch queues netlink requests for the network namespace it was created
+// in and sends them to the kernel in a single message, over one socket
+// which is reused until the Batch is closed. The functions of the netlink
+// package open a socket for every request instead, which adds up when a
+// plugin sets up an interface with several addresses and routes.
+type Batch struct {
+	sock *nl.NetlinkSocket
+	seq  uint32
+	reqs []*nl.NetlinkRequest
+}
+
+// NewBatch returns a Batch for the current network namespace
+func NewBatch() (*Batch, error) {
+	sock, err := nl.GetNetlinkSocketAt(netns.None(), netns.None(), syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open netlink socket: %v", err)
+	}
+	return &Batch{sock: sock}, nil
+}
+
+// Close releases the socket of the batch, dropping any queued requests
+func (b *Batch) Close() {
+	b.sock.Close()
+	b.reqs = nil
+}
+
+// Len returns the number of queued requests
+func (b *Batch) Len() int {
+	return len(b.reqs)
+}
+
+func (b *Batch) newRequest(proto, flags int) *nl.NetlinkRequest {
+	b.seq++
+	req := &nl.NetlinkRequest{
+		NlMsghdr: syscall.NlMsghdr{
+			Len:   uint32(syscall.SizeofNlMsghdr),
+			Type:  uint16(proto),
+			Flags: syscall.NLM_F_REQUEST | syscall.NLM_F_ACK | uint16(flags),
+			Seq:   b.seq,
+		},
+	}
+	b.reqs = append(b.reqs, req)
+	return req
+}
+
+// LinkSetUp queues setting link up
+func (b *Batch) LinkSetUp(link netlink.Link) {
+	req := b.newRequest(syscall.RTM_NEWLINK, 0)
+
+	msg := nl.NewIfInfomsg(syscall.AF_UNSPEC)
+	msg.Change = syscall.IFF_UP
+	msg.Flags = syscall.IFF_UP
+	msg.Index = int32(link.Attrs().Index)
+	req.AddData(msg)
+}
+
+// AddrAdd queues adding ipn to link
+func (b *Batch) AddrAdd(link netlink.Link, ipn *net.IPNet) {
+	req := b.newRequest(syscall.RTM_NEWADDR, syscall.NLM_F_CREATE|syscall.NLM_F_EXCL)
+
+	family := nl.GetIPFamily(ipn.IP)
+	msg := nl.NewIfAddrmsg(family)
+	msg.Index = uint32(link.Attrs().Index)
+	prefixlen, _ := ipn.Mask.Size()
+	msg.Prefixlen = uint8(prefixlen)
+	req.AddData(msg)
+
+	addr := ipn.IP.To4()
+	if family != netlink.FAMILY_V4 {
+		addr = ipn.IP.To16()
+	}
+	req.AddData(nl.NewRtAttr(syscall.IFA_LOCAL, addr))
+	req.AddData(nl.NewRtAttr(syscall.IFA_ADDRESS, addr))
+}
+
+// AddRoute queues adding a universally-scoped route to a device, like
+// the AddRoute function
+func (b *Batch) AddRoute(ipn *net.IPNet, gw net.IP, dev netlink.Link) {
+	b.addRoute(ipn, gw, dev, netlink.SCOPE_UNIVERSE)
+}
+
+// AddHostRoute queues adding a host-scoped route to a device, like the
+// AddHostRoute function
+func (b *Batch) AddHostRoute(ipn *net.IPNet, gw net.IP, dev netlink.Link) {
+	b.addRoute(ipn, gw, dev, netlink.SCOPE_HOST)
+}
+
+func (b *Batch) addRoute(ipn *net.IPNet, gw net.IP, dev netlink.Link, scope netlink.Scope) {
+	req := b.newRequest(syscall.RTM_NEWROUTE, syscall.NLM_F_CREATE|syscall.NLM_F_EXCL)
+
+	family := nl.GetIPFamily(ipn.IP)
+	ipData := func(ip net.IP) []byte {
+		if family == netlink.FAMILY_V4 {
+			return ip.To4()
+		}
+		return ip.To16()
+	}
+
+	msg := nl.NewRtMsg()
+	msg.Family = uint8(family)
+	msg.Scope = uint8(scope)
+	dstLen, _ := ipn.Mask.Size()
+	msg.Dst_len = uint8(dstLen)
+	req.AddData(msg)
+
+	req.AddData(nl.NewRtAttr(syscall.RTA_DST, ipData(ipn.IP)))
+	if gw != nil {
+		req.AddData(nl.NewRtAttr(syscall.RTA_GATEWAY, ipData(gw)))
+	}
+	req.AddData(nl.NewRtAttr(syscall.RTA_OIF, nl.Uint32Attr(uint32(dev.Attrs().Index))))
+}
+
+// Flush sends the queued requests and waits for the kernel to process
+// them. The kernel carries on after a request fails, so errs holds the
+// outcome of each request, in the order they were queued. err is set if
+// the requests could not be sent or their outcome could not be read.
+func (b *Batch) Flush() (errs []error, err error) {
+	reqs := b.reqs
+	b.reqs = nil
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+
+	msg := []byte{}
+	pending := map[uint32]int{}
+	for i, req := range reqs {
+		msg = append(msg, req.Serialize()...)
+		pending[req.Seq] = i
+	}
+	if err := syscall.Sendto(b.sock.GetFd(), msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("failed to send netlink requests: %v", err)
+	}
+
+	errs = make([]error, len(reqs))
+	for len(pending) > 0 {
+		msgs, err := b.sock.Receive()
+		if err != nil {
+			return nil, fmt.Errorf("failed to receive netlink replies: %v", err)
+		}
+		for _, m := range msgs {
+			i, ok := pending[m.Header.Seq]
+			if !ok || m.Header.Type != syscall.NLMSG_ERROR {
+				continue
+			}
+			delete(pending, m.Header.Seq)
+			if errno := int32(nl.NativeEndian().Uint32(m.Data[0:4])); errno != 0 {
+				errs[i] = syscall.Errno(-errno)
+			}
+		}
+	}
+	return errs, nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ip_test
+
+import (
+	"net"
+	"syscall"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/containernetworking/cni/pkg/ip"
+	"github.com/containernetworking/cni/pkg/ns"
+
+	"github.com/vishvananda/netlink"
+)
+
+var _ = Describe("Batch", func() {
+	var (
+		netNS ns.NetNS
+		batch *ip.Batch
+		lo    netlink.Link
+	)
+
+	ipNet := func(cidr string) *net.IPNet {
+		ip, ipn, err := net.ParseCIDR(cidr)
+		Expect(err).NotTo(HaveOccurred())
+		ipn.IP = ip
+		return ipn
+	}
+
+	BeforeEach(func() {
+		var err error
+		netNS, err = ns.NewNS()
+		Expect(err).NotTo(HaveOccurred())
+
+		err = netNS.Do(func(ns.NetNS) error {
+			var err error
+			if lo, err = netlink.LinkByName("lo"); err != nil {
+				return err
+			}
+			batch, err = ip.NewBatch()
+			return err
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		batch.Close()
+		Expect(netNS.Close()).To(Succeed())
+	})
+
+	It("applies the queued requests in the namespace it was created in", func() {
+		batch.LinkSetUp(lo)
+		batch.AddrAdd(lo, ipNet("10.1.2.3/24"))
+		batch.AddrAdd(lo, ipNet("2001:db8::3/64"))
+		batch.AddRoute(ipNet("10.9.0.0/16"), net.ParseIP("10.1.2.1"), lo)
+		batch.AddHostRoute(ipNet("10.8.0.0/16"), nil, lo)
+		Expect(batch.Len()).To(Equal(5))
+
+		errs, err := batch.Flush()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(errs).To(Equal([]error{nil, nil, nil, nil, nil}))
+		Expect(batch.Len()).To(Equal(0))
+
+		err = netNS.Do(func(ns.NetNS) error {
+			defer GinkgoRecover()
+
+			link, err := netlink.LinkByName("lo")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(link.Attrs().Flags & net.FlagUp).To(Equal(net.FlagUp))
+
+			addrs, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+			Expect(err).NotTo(HaveOccurred())
+			cidrs := []string{}
+			for _, a := range addrs {
+				cidrs = append(cidrs, a.IPNet.String())
+			}
+			Expect(cidrs).To(ContainElement("10.1.2.3/24"))
+			Expect(cidrs).To(ContainElement("2001:db8::3/64"))
+
+			routes, err := netlink.RouteList(link, netlink.FAMILY_V4)
+			Expect(err).NotTo(HaveOccurred())
+			scopes := map[string]netlink.Scope{}
+			for _, r := range routes {
+				if r.Dst != nil {
+					scopes[r.Dst.String()] = r.Scope
+				}
+			}
+			Expect(scopes).To(HaveKeyWithValue("10.9.0.0/16", netlink.SCOPE_UNIVERSE))
+			Expect(scopes).To(HaveKeyWithValue("10.8.0.0/16", netlink.SCOPE_HOST))
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("reports the outcome of each request", func() {
+		batch.LinkSetUp(lo)
+		batch.AddrAdd(lo, ipNet("10.1.2.3/24"))
+		batch.AddRoute(ipNet("10.9.0.0/16"), net.ParseIP("10.1.2.1"), lo)
+		batch.AddRoute(ipNet("10.9.0.0/16"), net.ParseIP("10.1.2.1"), lo)
+		batch.AddRoute(ipNet("10.7.0.0/16"), net.ParseIP("10.3.0.1"), lo)
+
+		errs, err := batch.Flush()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(errs).To(HaveLen(5))
+		Expect(errs[:3]).To(Equal([]error{nil, nil, nil}))
+		Expect(errs[3]).To(Equal(syscall.EEXIST))
+		Expect(errs[4]).To(Equal(syscall.ENETUNREACH))
+	})
+
+	It("does nothing without queued requests", func() {
+		errs, err := batch.Flush()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(errs).To(BeEmpty())
+	})
+})
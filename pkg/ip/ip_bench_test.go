@@ -0,0 +1,144 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ip_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/containernetworking/cni/pkg/ip"
+	"github.com/containernetworking/cni/pkg/ns"
+
+	"github.com/vishvananda/netlink"
+)
+
+func BenchmarkNextIP(b *testing.B) {
+	addr := net.ParseIP("10.0.0.1")
+	for i := 0; i < b.N; i++ {
+		addr = ip.NextIP(addr)
+	}
+}
+
+// BenchmarkSetupVeth measures creating and deleting a veth pair between
+// two namespaces, which is the bulk of an ADD and DEL of bridge or ptp
+func BenchmarkSetupVeth(b *testing.B) {
+	hostNS, err := ns.NewNS()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer hostNS.Close()
+
+	containerNS, err := ns.NewNS()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer containerNS.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := containerNS.Do(func(ns.NetNS) error {
+			if _, _, err := ip.SetupVeth("eth0", 1500, hostNS); err != nil {
+				return err
+			}
+			return ip.DelLinkByName("eth0")
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkAddRoutes compares adding the routes of an interface one
+// netlink request at a time and in a Batch
+func BenchmarkAddRoutes(b *testing.B) {
+	containerNS, err := ns.NewNS()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer containerNS.Close()
+
+	var lo netlink.Link
+	err = containerNS.Do(func(ns.NetNS) error {
+		lo, err = netlink.LinkByName("lo")
+		if err != nil {
+			return err
+		}
+		return netlink.LinkSetUp(lo)
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	routes := []*net.IPNet{}
+	for i := 0; i < 8; i++ {
+		routes = append(routes, &net.IPNet{
+			IP:   net.IPv4(10, byte(i), 0, 0),
+			Mask: net.CIDRMask(16, 32),
+		})
+	}
+	delRoutes := func() error {
+		for _, r := range routes {
+			if err := netlink.RouteDel(&netlink.Route{LinkIndex: lo.Attrs().Index, Dst: r}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	b.Run("sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			err := containerNS.Do(func(ns.NetNS) error {
+				for _, r := range routes {
+					if err := ip.AddRoute(r, nil, lo); err != nil {
+						return err
+					}
+				}
+				return delRoutes()
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("batched", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			err := containerNS.Do(func(ns.NetNS) error {
+				batch, err := ip.NewBatch()
+				if err != nil {
+					return err
+				}
+				defer batch.Close()
+
+				for _, r := range routes {
+					batch.AddRoute(r, nil, lo)
+				}
+				errs, err := batch.Flush()
+				if err != nil {
+					return err
+				}
+				for _, err := range errs {
+					if err != nil {
+						return err
+					}
+				}
+				return delRoutes()
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
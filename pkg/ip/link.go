@@ -164,6 +164,31 @@ func DelLinkByNameAddr(ifName string, family int) (*net.IPNet, error) {
 	return addrs[0].IPNet, nil
 }
 
+// DelLinkByNameAddrs removes an interface and returns its IP addresses
+// of family, which unlike for DelLinkByNameAddr may be none, e.g. for the
+// IPv4 addresses of an IPv6-only interface
+func DelLinkByNameAddrs(ifName string, family int) ([]*net.IPNet, error) {
+	iface, err := netlink.LinkByName(ifName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup %q: %v", ifName, err)
+	}
+
+	addrs, err := netlink.AddrList(iface, family)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get IP addresses for %q: %v", ifName, err)
+	}
+
+	if err = netlink.LinkDel(iface); err != nil {
+		return nil, fmt.Errorf("failed to delete %q: %v", ifName, err)
+	}
+
+	ipns := []*net.IPNet{}
+	for _, a := range addrs {
+		ipns = append(ipns, a.IPNet)
+	}
+	return ipns, nil
+}
+
 func SetHWAddrByIP(ifName string, ip4 net.IP, ip6 net.IP) error {
 	iface, err := netlink.LinkByName(ifName)
 	if err != nil {
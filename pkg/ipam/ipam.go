@@ -29,39 +29,81 @@ func ExecAdd(plugin string, netconf []byte) (*types.Result, error) {
 	return invoke.DelegateAdd(plugin, netconf)
 }
 
+// ExecAddWithPrevResult runs the IPAM plugin like ExecAdd, but hands it
+// prevResult so that it can base its allocation on an earlier result
+func ExecAddWithPrevResult(plugin string, netconf []byte, prevResult *types.Result) (*types.Result, error) {
+	netconf, err := types.InjectPrevResult(netconf, prevResult)
+	if err != nil {
+		return nil, err
+	}
+	return invoke.DelegateAdd(plugin, netconf)
+}
+
 func ExecDel(plugin string, netconf []byte) error {
 	return invoke.DelegateDel(plugin, netconf)
 }
 
 // ConfigureIface takes the result of IPAM plugin and
-// applies to the ifName interface
+// applies to the ifName interface. Results with both IPv4 and IPv6
+// configure both families.
 func ConfigureIface(ifName string, res *types.Result) error {
+	if res.IP4 == nil && res.IP6 == nil {
+		return fmt.Errorf("IPAM result for %q has neither IPv4 nor IPv6 config", ifName)
+	}
+
 	link, err := netlink.LinkByName(ifName)
 	if err != nil {
 		return fmt.Errorf("failed to lookup %q: %v", ifName, err)
 	}
 
-	if err := netlink.LinkSetUp(link); err != nil {
-		return fmt.Errorf("failed to set %q UP: %v", ifName, err)
+	batch, err := ip.NewBatch()
+	if err != nil {
+		return err
 	}
+	defer batch.Close()
 
-	// TODO(eyakubovich): IPv6
-	addr := &netlink.Addr{IPNet: &res.IP4.IP, Label: ""}
-	if err = netlink.AddrAdd(link, addr); err != nil {
-		return fmt.Errorf("failed to add IP addr to %q: %v", ifName, err)
+	// everything is sent at once, so the operation of each reply is
+	// recorded to tell which one failed
+	type op struct {
+		desc        string
+		ignoreExist bool
 	}
+	ops := []op{{desc: fmt.Sprintf("set %q UP", ifName)}}
+	batch.LinkSetUp(link)
 
-	for _, r := range res.IP4.Routes {
-		gw := r.GW
-		if gw == nil {
-			gw = res.IP4.Gateway
+	for _, ipc := range []*types.IPConfig{res.IP4, res.IP6} {
+		if ipc == nil {
+			continue
 		}
-		if err = ip.AddRoute(&r.Dst, gw, link); err != nil {
-			// we skip over duplicate routes as we assume the first one wins
-			if !os.IsExist(err) {
-				return fmt.Errorf("failed to add route '%v via %v dev %v': %v", r.Dst, gw, ifName, err)
+
+		ipn := ipc.IP
+		batch.AddrAdd(link, &ipn)
+		ops = append(ops, op{desc: fmt.Sprintf("add IP addr to %q", ifName)})
+
+		for _, r := range ipc.Routes {
+			gw := r.GW
+			if gw == nil {
+				gw = ipc.Gateway
 			}
+			dst := r.Dst
+			batch.AddRoute(&dst, gw, link)
+			// we skip over duplicate routes as we assume the first one wins
+			ops = append(ops, op{
+				desc:        fmt.Sprintf("add route '%v via %v dev %v'", r.Dst, gw, ifName),
+				ignoreExist: true,
+			})
+		}
+	}
+
+	errs, err := batch.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to configure %q: %v", ifName, err)
+	}
+	for i, err := range errs {
+		if err == nil || (ops[i].ignoreExist && os.IsExist(err)) {
+			continue
 		}
+		return fmt.Errorf("failed to %s: %v", ops[i].desc, err)
 	}
 
 	return nil
@@ -0,0 +1,135 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipam_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/containernetworking/cni/pkg/ipam"
+	"github.com/containernetworking/cni/pkg/ns"
+	"github.com/containernetworking/cni/pkg/testutils"
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/vishvananda/netlink"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ExecAddWithPrevResult", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "ipam")
+		Expect(err).NotTo(HaveOccurred())
+		// the plugin keeps its stdin and returns a fixed result
+		script := "#!/bin/sh\ncat > " + filepath.Join(dir, "stdin") + "\necho '{\"ip4\": {\"ip\": \"10.1.2.4/24\"}}'\n"
+		Expect(ioutil.WriteFile(filepath.Join(dir, "fake-ipam"), []byte(script), 0755)).To(Succeed())
+		os.Setenv("CNI_COMMAND", "ADD")
+		os.Setenv("CNI_PATH", dir)
+	})
+
+	AfterEach(func() {
+		os.Unsetenv("CNI_COMMAND")
+		os.Unsetenv("CNI_PATH")
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("hands the prevResult to the IPAM plugin", func() {
+		prevResult := &types.Result{
+			IP4: &types.IPConfig{
+				IP: net.IPNet{IP: net.ParseIP("10.1.2.3"), Mask: net.CIDRMask(24, 32)},
+			},
+		}
+
+		result, err := ipam.ExecAddWithPrevResult("fake-ipam", []byte(`{ "name": "mynet" }`), prevResult)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(testutils.HaveIP4("10.1.2.4/24"))
+
+		stdin, err := ioutil.ReadFile(filepath.Join(dir, "stdin"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stdin).To(MatchJSON(`{
+			"name": "mynet",
+			"prevResult": { "ip4": { "ip": "10.1.2.3/24" }, "dns": {} }
+		}`))
+	})
+})
+
+var _ = Describe("ConfigureIface", func() {
+	ipNet := func(cidr string) net.IPNet {
+		ip, ipn, err := net.ParseCIDR(cidr)
+		Expect(err).NotTo(HaveOccurred())
+		ipn.IP = ip
+		return *ipn
+	}
+
+	It("configures both families of a dual-stack result", func() {
+		result := &types.Result{
+			IP4: &types.IPConfig{
+				IP:      ipNet("10.1.2.3/24"),
+				Gateway: net.ParseIP("10.1.2.1"),
+				Routes:  []types.Route{{Dst: ipNet("10.9.0.0/16")}},
+			},
+			IP6: &types.IPConfig{
+				IP:     ipNet("2001:db8::3/64"),
+				Routes: []types.Route{{Dst: ipNet("2001:db8:9::/48")}},
+			},
+		}
+
+		err := testutils.WithTempNetNS(func(netns ns.NetNS) error {
+			defer GinkgoRecover()
+
+			Expect(netns.Do(func(ns.NetNS) error {
+				return ipam.ConfigureIface("lo", result)
+			})).To(Succeed())
+
+			addrs, err := testutils.LinkAddrsInNetNS(netns, "lo", netlink.FAMILY_ALL)
+			Expect(err).NotTo(HaveOccurred())
+			cidrs := []string{}
+			for _, a := range addrs {
+				cidrs = append(cidrs, a.IPNet.String())
+			}
+			Expect(cidrs).To(ContainElement("10.1.2.3/24"))
+			Expect(cidrs).To(ContainElement("2001:db8::3/64"))
+
+			return netns.Do(func(ns.NetNS) error {
+				defer GinkgoRecover()
+
+				routes, err := netlink.RouteList(nil, netlink.FAMILY_ALL)
+				Expect(err).NotTo(HaveOccurred())
+				gws := map[string]string{}
+				for _, r := range routes {
+					if r.Dst != nil {
+						gws[r.Dst.String()] = r.Gw.String()
+					}
+				}
+				// lo gets no prefix route for IPv6 addresses, so no IPv6
+				// gateway is reachable through it
+				Expect(gws).To(HaveKeyWithValue("10.9.0.0/16", "10.1.2.1"))
+				Expect(gws).To(HaveKeyWithValue("2001:db8:9::/48", "<nil>"))
+				return nil
+			})
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("rejects a result without any IPs", func() {
+		err := ipam.ConfigureIface("lo", &types.Result{})
+		Expect(err).To(MatchError(`IPAM result for "lo" has neither IPv4 nor IPv6 config`))
+	})
+})
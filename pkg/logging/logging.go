@@ -0,0 +1,267 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logging is the leveled logger shared by the plugins. It never
+// writes to stdout, which carries the result of a plugin.
+//
+// skel configures the default logger for each invocation from the "log"
+// section of the network configuration, which the CNI_LOG_LEVEL,
+// CNI_LOG_FORMAT and CNI_LOG_FILE environment variables override:
+//
+//	"log": {
+//		"level": "debug",
+//		"format": "json",
+//		"file": "/var/log/cni.log"
+//	}
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarning
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warning", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel parses the name of a level, e.g. "debug"
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+
+	// Values of Config.File other than a path
+	FileStderr = "stderr"
+	FileSyslog = "syslog"
+)
+
+// Config is the "log" section of a network configuration
+type Config struct {
+	// Level defaults to "info"
+	Level string `json:"level,omitempty"`
+	// Format is "text", the default, or "json"
+	Format string `json:"format,omitempty"`
+	// File is a path the log is appended to, "stderr", the default, or
+	// "syslog"
+	File string `json:"file,omitempty"`
+}
+
+// overrideFromEnv returns c with the settings given in CNI_LOG_*
+// environment variables replaced
+func (c Config) overrideFromEnv(getenv func(string) string) Config {
+	if v := getenv("CNI_LOG_LEVEL"); v != "" {
+		c.Level = v
+	}
+	if v := getenv("CNI_LOG_FORMAT"); v != "" {
+		c.Format = v
+	}
+	if v := getenv("CNI_LOG_FILE"); v != "" {
+		c.File = v
+	}
+	return c
+}
+
+type field struct {
+	key, value string
+}
+
+// Logger writes leveled messages, with the fields added by With, to
+// its sink
+type Logger struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	level  Level
+	json   bool
+	name   string
+	fields []field
+}
+
+// New returns a Logger writing messages of level or higher to w
+func New(w io.Writer, level Level, format string) (*Logger, error) {
+	if format == "" {
+		format = FormatText
+	}
+	if format != FormatText && format != FormatJSON {
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+	return &Logger{
+		mu:    &sync.Mutex{},
+		w:     w,
+		level: level,
+		json:  format == FormatJSON,
+		name:  filepath.Base(os.Args[0]),
+	}, nil
+}
+
+// FromConfig returns the Logger described by c and the environment.
+// stderr is the sink for "stderr".
+func FromConfig(c Config, getenv func(string) string, stderr io.Writer) (*Logger, error) {
+	c = c.overrideFromEnv(getenv)
+
+	level := LevelInfo
+	if c.Level != "" {
+		var err error
+		if level, err = ParseLevel(c.Level); err != nil {
+			return nil, err
+		}
+	}
+
+	var w io.Writer
+	switch c.File {
+	case "", FileStderr:
+		w = stderr
+	case FileSyslog:
+		var err error
+		if w, err = newSyslogWriter(); err != nil {
+			return nil, fmt.Errorf("failed to connect to syslog: %v", err)
+		}
+	default:
+		if !filepath.IsAbs(c.File) {
+			return nil, fmt.Errorf("log file %q must be an absolute path", c.File)
+		}
+		f, err := os.OpenFile(c.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %v", err)
+		}
+		w = f
+	}
+	return New(w, level, c.Format)
+}
+
+// With returns a Logger that adds key=value to every message
+func (l *Logger) With(key, value string) *Logger {
+	n := *l
+	n.fields = append(append([]field{}, l.fields...), field{key, value})
+	return &n
+}
+
+// Enabled reports whether messages of level are written
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.logf(LevelDebug, format, args...)
+}
+
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.logf(LevelInfo, format, args...)
+}
+
+func (l *Logger) Warningf(format string, args ...interface{}) {
+	l.logf(LevelWarning, format, args...)
+}
+
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.logf(LevelError, format, args...)
+}
+
+func (l *Logger) logf(level Level, format string, args ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	now := time.Now()
+	msg := fmt.Sprintf(format, args...)
+
+	var line []byte
+	if l.json {
+		m := map[string]string{
+			"time":   now.Format(time.RFC3339Nano),
+			"level":  level.String(),
+			"plugin": l.name,
+			"msg":    msg,
+		}
+		for _, f := range l.fields {
+			m[f.key] = f.value
+		}
+		line, _ = json.Marshal(m)
+	} else {
+		s := fmt.Sprintf("%s %s %s[%d]: %s", now.Format(time.RFC3339), strings.ToUpper(level.String()), l.name, os.Getpid(), msg)
+		for _, f := range l.fields {
+			s += fmt.Sprintf(" %s=%q", f.key, f.value)
+		}
+		line = []byte(s)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(append(line, '\n'))
+}
+
+var (
+	defaultMu     sync.Mutex
+	defaultLogger *Logger
+)
+
+func init() {
+	defaultLogger, _ = New(os.Stderr, LevelInfo, FormatText)
+}
+
+// Default returns the logger used by the package level functions
+func Default() *Logger {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	return defaultLogger
+}
+
+// SetDefault replaces the logger used by the package level functions
+func SetDefault(l *Logger) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultLogger = l
+}
+
+func Debugf(format string, args ...interface{}) {
+	Default().Debugf(format, args...)
+}
+
+func Infof(format string, args ...interface{}) {
+	Default().Infof(format, args...)
+}
+
+func Warningf(format string, args ...interface{}) {
+	Default().Warningf(format, args...)
+}
+
+func Errorf(format string, args ...interface{}) {
+	Default().Errorf(format, args...)
+}
@@ -0,0 +1,143 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containernetworking/cni/pkg/logging"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Logger", func() {
+	var (
+		buf *bytes.Buffer
+		env map[string]string
+	)
+
+	getenv := func(key string) string { return env[key] }
+
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+		env = map[string]string{}
+	})
+
+	lines := func() []string {
+		return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	}
+
+	It("drops messages below its level", func() {
+		l, err := logging.New(buf, logging.LevelWarning, logging.FormatText)
+		Expect(err).NotTo(HaveOccurred())
+
+		l.Debugf("debug")
+		l.Infof("info")
+		l.Warningf("warning %d", 1)
+		l.Errorf("error")
+
+		Expect(lines()).To(HaveLen(2))
+		Expect(lines()[0]).To(MatchRegexp(`^\S+ WARNING \S+\[\d+\]: warning 1$`))
+		Expect(lines()[1]).To(MatchRegexp(`^\S+ ERROR \S+\[\d+\]: error$`))
+	})
+
+	It("adds the fields of With to every message", func() {
+		l, err := logging.New(buf, logging.LevelInfo, logging.FormatText)
+		Expect(err).NotTo(HaveOccurred())
+
+		tagged := l.With("containerId", "c1")
+		tagged.Infof("tagged")
+		l.Infof("untagged")
+
+		Expect(lines()[0]).To(HaveSuffix(`: tagged containerId="c1"`))
+		Expect(lines()[1]).To(HaveSuffix(": untagged"))
+	})
+
+	It("writes JSON", func() {
+		l, err := logging.New(buf, logging.LevelInfo, logging.FormatJSON)
+		Expect(err).NotTo(HaveOccurred())
+
+		l.With("containerId", "c1").Infof("hello")
+
+		var m map[string]string
+		Expect(json.Unmarshal(buf.Bytes(), &m)).To(Succeed())
+		Expect(m).To(HaveKeyWithValue("level", "info"))
+		Expect(m).To(HaveKeyWithValue("msg", "hello"))
+		Expect(m).To(HaveKeyWithValue("containerId", "c1"))
+		Expect(m).To(HaveKey("time"))
+		Expect(m).To(HaveKey("plugin"))
+	})
+
+	Context("when configured", func() {
+		It("defaults to info messages as text on stderr", func() {
+			l, err := logging.FromConfig(logging.Config{}, getenv, buf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(l.Enabled(logging.LevelInfo)).To(BeTrue())
+			Expect(l.Enabled(logging.LevelDebug)).To(BeFalse())
+
+			l.Infof("hello")
+			Expect(buf.String()).To(ContainSubstring(" INFO "))
+		})
+
+		It("uses the configuration", func() {
+			l, err := logging.FromConfig(logging.Config{Level: "debug", Format: "json"}, getenv, buf)
+			Expect(err).NotTo(HaveOccurred())
+
+			l.Debugf("hello")
+			Expect(buf.String()).To(HavePrefix("{"))
+		})
+
+		It("lets CNI_LOG_* environment variables override it", func() {
+			env["CNI_LOG_LEVEL"] = "error"
+			l, err := logging.FromConfig(logging.Config{Level: "debug"}, getenv, buf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(l.Enabled(logging.LevelWarning)).To(BeFalse())
+		})
+
+		It("appends to a log file", func() {
+			dir, err := ioutil.TempDir("", "cni-logging")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "cni.log")
+			Expect(ioutil.WriteFile(path, []byte("earlier\n"), 0644)).To(Succeed())
+
+			env["CNI_LOG_FILE"] = path
+			l, err := logging.FromConfig(logging.Config{}, getenv, buf)
+			Expect(err).NotTo(HaveOccurred())
+			l.Infof("hello")
+
+			data, err := ioutil.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(HavePrefix("earlier\n"))
+			Expect(string(data)).To(HaveSuffix(": hello\n"))
+			Expect(buf.Len()).To(BeZero())
+		})
+
+		It("rejects invalid settings", func() {
+			_, err := logging.FromConfig(logging.Config{Level: "loud"}, getenv, buf)
+			Expect(err).To(MatchError(`unknown log level "loud"`))
+			_, err = logging.FromConfig(logging.Config{Format: "xml"}, getenv, buf)
+			Expect(err).To(MatchError(`unknown log format "xml"`))
+			_, err = logging.FromConfig(logging.Config{File: "relative.log"}, getenv, buf)
+			Expect(err).To(MatchError(`log file "relative.log" must be an absolute path`))
+		})
+	})
+})
@@ -0,0 +1,199 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics records how often and how fast plugins handle commands,
+// in one file per plugin below a shared directory, and renders them for
+// Prometheus.
+//
+// Every invocation is a separate process, so the files are updated under
+// a lock instead of being kept in memory. Nothing is recorded unless the
+// directory exists, which leaves it to operators to opt in.
+package metrics
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/containernetworking/cni/pkg/store"
+)
+
+// DefaultDir is the directory the metrics are kept in by default
+const DefaultDir = "/var/lib/cni/metrics"
+
+const fileSuffix = ".json"
+
+// Buckets are the upper bounds, in seconds, of the duration histogram
+var Buckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Series are the metrics of one command of a plugin in one network
+type Series struct {
+	Network string `json:"network"`
+	Command string `json:"command"`
+	Count   uint64 `json:"count"`
+	// Errors counts the failed invocations by error code
+	Errors map[string]uint64 `json:"errors,omitempty"`
+	// BucketCounts counts the invocations by the first bucket of
+	// Buckets their duration fits in; longer ones are only in Count
+	BucketCounts []uint64 `json:"bucketCounts"`
+	// DurationSum is the total duration in seconds
+	DurationSum float64 `json:"durationSum"`
+}
+
+// File holds the metrics of a plugin
+type File struct {
+	Plugin string    `json:"plugin"`
+	Series []*Series `json:"series"`
+}
+
+func (f *File) series(network, command string) *Series {
+	for _, s := range f.Series {
+		if s.Network == network && s.Command == command {
+			return s
+		}
+	}
+	s := &Series{
+		Network:      network,
+		Command:      command,
+		BucketCounts: make([]uint64, len(Buckets)),
+	}
+	f.Series = append(f.Series, s)
+	return s
+}
+
+// Record adds an invocation of plugin that took d to the metrics in dir.
+// code is the error code the invocation failed with, or 0. Nothing is
+// recorded if dir doesn't exist.
+func Record(dir, plugin, network, command string, d time.Duration, code uint) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+
+	path := filepath.Join(dir, plugin+fileSuffix)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := lock(f); err != nil {
+		return err
+	}
+	defer store.UnlockFile(f)
+
+	file := &File{}
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, file); err != nil {
+			// start over rather than failing every invocation
+			file = &File{}
+		}
+	}
+	file.Plugin = plugin
+
+	s := file.series(network, command)
+	s.Count++
+	s.DurationSum += d.Seconds()
+	for i, le := range Buckets {
+		if d.Seconds() <= le && i < len(s.BucketCounts) {
+			s.BucketCounts[i]++
+			break
+		}
+	}
+	if code != 0 {
+		if s.Errors == nil {
+			s.Errors = map[string]uint64{}
+		}
+		s.Errors[strconv.FormatUint(uint64(code), 10)]++
+	}
+
+	if data, err = json.Marshal(file); err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err = f.WriteAt(data, 0)
+	return err
+}
+
+// ReadDir returns the metrics of all plugins in dir, sorted by plugin
+func ReadDir(dir string) ([]*File, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []*File{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), fileSuffix) {
+			continue
+		}
+		file, err := readFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if file != nil {
+			files = append(files, file)
+		}
+	}
+	sort.Sort(byPlugin(files))
+	return files, nil
+}
+
+// lock locks f for this invocation. Without flock, concurrent invocations
+// may lose each other's updates, which only makes the metrics less
+// accurate.
+func lock(f *os.File) error {
+	if err := store.LockFile(f); err != store.ErrLockingUnsupported {
+		return err
+	}
+	return nil
+}
+
+func readFile(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := lock(f); err != nil {
+		return nil, err
+	}
+	defer store.UnlockFile(f)
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil || len(data) == 0 {
+		return nil, err
+	}
+	file := &File{}
+	if err := json.Unmarshal(data, file); err != nil {
+		// Record starts over with a corrupted file, so skip it as well
+		return nil, nil
+	}
+	return file, nil
+}
+
+type byPlugin []*File
+
+func (f byPlugin) Len() int           { return len(f) }
+func (f byPlugin) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+func (f byPlugin) Less(i, j int) bool { return f[i].Plugin < f[j].Plugin }
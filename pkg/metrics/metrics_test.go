@@ -0,0 +1,130 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/containernetworking/cni/pkg/metrics"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("metrics", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "cni-metrics")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("records invocations by network and command", func() {
+		Expect(metrics.Record(dir, "bridge", "mynet", "ADD", 3*time.Millisecond, 0)).To(Succeed())
+		Expect(metrics.Record(dir, "bridge", "mynet", "ADD", 2*time.Second, 100)).To(Succeed())
+		Expect(metrics.Record(dir, "bridge", "mynet", "DEL", time.Minute, 0)).To(Succeed())
+		Expect(metrics.Record(dir, "bridge", "other", "ADD", time.Millisecond, 0)).To(Succeed())
+		Expect(metrics.Record(dir, "host-local", "mynet", "ADD", time.Millisecond, 0)).To(Succeed())
+
+		files, err := metrics.ReadDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(2))
+		Expect(files[0].Plugin).To(Equal("bridge"))
+		Expect(files[1].Plugin).To(Equal("host-local"))
+
+		series := files[0].Series
+		Expect(series).To(HaveLen(3))
+		Expect(series[0].Network).To(Equal("mynet"))
+		Expect(series[0].Command).To(Equal("ADD"))
+		Expect(series[0].Count).To(BeEquivalentTo(2))
+		Expect(series[0].Errors).To(Equal(map[string]uint64{"100": 1}))
+		Expect(series[0].BucketCounts).To(Equal([]uint64{1, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0}))
+		Expect(series[0].DurationSum).To(BeNumerically("~", 2.003, 1e-9))
+
+		// longer than the last bucket, so only counted
+		Expect(series[1].Command).To(Equal("DEL"))
+		Expect(series[1].Count).To(BeEquivalentTo(1))
+		Expect(series[1].BucketCounts).To(Equal(make([]uint64, len(metrics.Buckets))))
+	})
+
+	It("does not record anything if the directory doesn't exist", func() {
+		missing := filepath.Join(dir, "missing")
+		Expect(metrics.Record(missing, "bridge", "mynet", "ADD", time.Millisecond, 0)).To(Succeed())
+		Expect(missing).NotTo(BeADirectory())
+	})
+
+	It("doesn't lose concurrent updates", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer GinkgoRecover()
+				Expect(metrics.Record(dir, "bridge", "mynet", "ADD", time.Millisecond, 0)).To(Succeed())
+			}()
+		}
+		wg.Wait()
+
+		files, err := metrics.ReadDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files[0].Series[0].Count).To(BeEquivalentTo(20))
+	})
+
+	It("starts over with a corrupted file", func() {
+		Expect(ioutil.WriteFile(filepath.Join(dir, "bridge.json"), []byte("{garbage"), 0644)).To(Succeed())
+
+		files, err := metrics.ReadDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(BeEmpty())
+
+		Expect(metrics.Record(dir, "bridge", "mynet", "ADD", time.Millisecond, 0)).To(Succeed())
+		files, err = metrics.ReadDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files[0].Series[0].Count).To(BeEquivalentTo(1))
+	})
+
+	It("renders the Prometheus text format", func() {
+		Expect(metrics.Record(dir, "bridge", `my"net`, "ADD", 3*time.Millisecond, 0)).To(Succeed())
+		Expect(metrics.Record(dir, "bridge", `my"net`, "ADD", 20*time.Millisecond, 7)).To(Succeed())
+		files, err := metrics.ReadDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+
+		var buf bytes.Buffer
+		Expect(metrics.WritePrometheus(&buf, files)).To(Succeed())
+		out := buf.String()
+
+		labels := `plugin="bridge",network="my\"net",command="ADD"`
+		Expect(out).To(ContainSubstring("# TYPE cni_plugin_invocations_total counter\n"))
+		Expect(out).To(ContainSubstring("cni_plugin_invocations_total{" + labels + "} 2\n"))
+		Expect(out).To(ContainSubstring("cni_plugin_errors_total{" + labels + `,code="7"} 1` + "\n"))
+		Expect(out).To(ContainSubstring("# TYPE cni_plugin_duration_seconds histogram\n"))
+		Expect(out).To(ContainSubstring("cni_plugin_duration_seconds_bucket{" + labels + `,le="0.005"} 1` + "\n"))
+		Expect(out).To(ContainSubstring("cni_plugin_duration_seconds_bucket{" + labels + `,le="0.01"} 1` + "\n"))
+		Expect(out).To(ContainSubstring("cni_plugin_duration_seconds_bucket{" + labels + `,le="0.025"} 2` + "\n"))
+		Expect(out).To(ContainSubstring("cni_plugin_duration_seconds_bucket{" + labels + `,le="+Inf"} 2` + "\n"))
+		Expect(out).To(ContainSubstring("cni_plugin_duration_seconds_sum{" + labels + "} 0.023\n"))
+		Expect(out).To(ContainSubstring("cni_plugin_duration_seconds_count{" + labels + "} 2\n"))
+	})
+})
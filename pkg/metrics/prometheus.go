@@ -0,0 +1,94 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WritePrometheus writes files in the Prometheus text exposition format
+func WritePrometheus(w io.Writer, files []*File) error {
+	ew := &errWriter{w: w}
+
+	ew.printf("# HELP cni_plugin_invocations_total Number of commands handled by CNI plugins.\n")
+	ew.printf("# TYPE cni_plugin_invocations_total counter\n")
+	forEachSeries(files, func(plugin string, s *Series) {
+		ew.printf("cni_plugin_invocations_total{%s} %d\n", labels(plugin, s), s.Count)
+	})
+
+	ew.printf("# HELP cni_plugin_errors_total Number of commands CNI plugins failed, by error code.\n")
+	ew.printf("# TYPE cni_plugin_errors_total counter\n")
+	forEachSeries(files, func(plugin string, s *Series) {
+		codes := []string{}
+		for code := range s.Errors {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		for _, code := range codes {
+			ew.printf("cni_plugin_errors_total{%s,code=%q} %d\n", labels(plugin, s), code, s.Errors[code])
+		}
+	})
+
+	ew.printf("# HELP cni_plugin_duration_seconds Time CNI plugins took to handle commands.\n")
+	ew.printf("# TYPE cni_plugin_duration_seconds histogram\n")
+	forEachSeries(files, func(plugin string, s *Series) {
+		l := labels(plugin, s)
+		var cumulative uint64
+		for i, le := range Buckets {
+			if i < len(s.BucketCounts) {
+				cumulative += s.BucketCounts[i]
+			}
+			ew.printf("cni_plugin_duration_seconds_bucket{%s,le=%q} %d\n", l, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+		ew.printf("cni_plugin_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", l, s.Count)
+		ew.printf("cni_plugin_duration_seconds_sum{%s} %s\n", l, strconv.FormatFloat(s.DurationSum, 'g', -1, 64))
+		ew.printf("cni_plugin_duration_seconds_count{%s} %d\n", l, s.Count)
+	})
+
+	return ew.err
+}
+
+func forEachSeries(files []*File, f func(plugin string, s *Series)) {
+	for _, file := range files {
+		for _, s := range file.Series {
+			f(file.Plugin, s)
+		}
+	}
+}
+
+func labels(plugin string, s *Series) string {
+	return fmt.Sprintf("plugin=%s,network=%s,command=%s", escape(plugin), escape(s.Network), escape(s.Command))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escape(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
+
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err == nil {
+		_, ew.err = fmt.Fprintf(ew.w, format, args...)
+	}
+}
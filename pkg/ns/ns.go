@@ -20,6 +20,8 @@ import (
 	"os"
 	"path"
 	"runtime"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 
@@ -107,9 +109,32 @@ func IsNSorErr(nspath string) error {
 	}
 }
 
-// Returns an object representing the namespace referred to by @path
+// ResolvePath returns the path of the network namespace referred to by
+// nsref. Besides a path, this may be the PID of a process in the
+// namespace, either bare or as "pid:<pid>", for runtimes and tools which
+// only know the PID of the sandbox.
+func ResolvePath(nsref string) (string, error) {
+	pid := strings.TrimPrefix(nsref, "pid:")
+	if pid == nsref && (nsref == "" || strings.Trim(nsref, "0123456789") != "") {
+		return nsref, nil
+	}
+
+	n, err := strconv.Atoi(pid)
+	if err != nil || n <= 0 {
+		return "", fmt.Errorf("invalid PID in netns reference %q", nsref)
+	}
+	return fmt.Sprintf("/proc/%d/ns/net", n), nil
+}
+
+// Returns an object representing the namespace referred to by @path,
+// which may also be a PID as accepted by ResolvePath
 func GetNS(nspath string) (NetNS, error) {
-	err := IsNSorErr(nspath)
+	nspath, err := ResolvePath(nspath)
+	if err != nil {
+		return nil, err
+	}
+
+	err = IsNSorErr(nspath)
 	if err != nil {
 		return nil, err
 	}
@@ -122,26 +147,80 @@ func GetNS(nspath string) (NetNS, error) {
 	return &netNS{file: fd}, nil
 }
 
+const nsRunDir = "/var/run/netns"
+
 // Creates a new persistent network namespace and returns an object
 // representing that namespace, without switching to it
 func NewNS() (NetNS, error) {
-	const nsRunDir = "/var/run/netns"
-
 	b := make([]byte, 16)
 	_, err := rand.Reader.Read(b)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate random netns name: %v", err)
 	}
 
-	err = os.MkdirAll(nsRunDir, 0755)
+	nsName := fmt.Sprintf("cni-%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	fd, err := mountNewNS(path.Join(nsRunDir, nsName))
+	if err != nil {
+		return nil, err
+	}
+
+	return &netNS{file: fd, mounted: true}, nil
+}
+
+// Creates a new network namespace named @name in /var/run/netns, where
+// `ip netns` finds it as well. Unlike with NewNS, closing the returned
+// object leaves the namespace in place; it lives on until it is removed
+// with DeleteNamedNS.
+func NewNamedNS(name string) (NetNS, error) {
+	if err := checkNSName(name); err != nil {
+		return nil, err
+	}
+
+	fd, err := mountNewNS(path.Join(nsRunDir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	return &netNS{file: fd}, nil
+}
+
+// Removes the network namespace named @name from /var/run/netns. The
+// namespace is destroyed once no process uses it anymore.
+func DeleteNamedNS(name string) error {
+	if err := checkNSName(name); err != nil {
+		return err
+	}
+
+	nsPath := path.Join(nsRunDir, name)
+	if err := IsNSorErr(nsPath); err != nil {
+		return err
+	}
+	if err := unix.Unmount(nsPath, unix.MNT_DETACH); err != nil {
+		return fmt.Errorf("Failed to unmount namespace %s: %v", nsPath, err)
+	}
+	if err := os.Remove(nsPath); err != nil {
+		return fmt.Errorf("Failed to clean up namespace %s: %v", nsPath, err)
+	}
+	return nil
+}
+
+func checkNSName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid netns name %q", name)
+	}
+	return nil
+}
+
+// mountNewNS bind mounts a new network namespace onto nsPath, which
+// must not exist yet, and returns it opened
+func mountNewNS(nsPath string) (*os.File, error) {
+	err := os.MkdirAll(nsRunDir, 0755)
 	if err != nil {
 		return nil, err
 	}
 
 	// create an empty file at the mount point
-	nsName := fmt.Sprintf("cni-%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
-	nsPath := path.Join(nsRunDir, nsName)
-	mountPointFd, err := os.Create(nsPath)
+	mountPointFd, err := os.OpenFile(nsPath, os.O_RDONLY|os.O_CREATE|os.O_EXCL, 0444)
 	if err != nil {
 		return nil, err
 	}
@@ -195,7 +274,7 @@ func NewNS() (NetNS, error) {
 		return nil, fmt.Errorf("failed to create namespace: %v", err)
 	}
 
-	return &netNS{file: fd, mounted: true}, nil
+	return fd, nil
 }
 
 func (ns *netNS) Path() string {
@@ -214,6 +214,101 @@ var _ = Describe("Linux namespace operations", func() {
 		})
 	})
 
+	Describe("named network namespaces", func() {
+		const name = "cni-test-named-ns"
+
+		AfterEach(func() {
+			ns.DeleteNamedNS(name)
+		})
+
+		It("persists until it is deleted", func() {
+			createdNetNS, err := ns.NewNamedNS(name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(createdNetNS.Path()).To(Equal("/var/run/netns/" + name))
+			Expect(createdNetNS.Close()).To(Succeed())
+
+			Expect(ns.IsNSorErr("/var/run/netns/" + name)).To(Succeed())
+
+			Expect(ns.DeleteNamedNS(name)).To(Succeed())
+			Expect("/var/run/netns/" + name).NotTo(BeAnExistingFile())
+		})
+
+		It("refuses to replace an existing namespace", func() {
+			createdNetNS, err := ns.NewNamedNS(name)
+			Expect(err).NotTo(HaveOccurred())
+			defer createdNetNS.Close()
+
+			_, err = ns.NewNamedNS(name)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects names that are not a single path element", func() {
+			_, err := ns.NewNamedNS("../etc")
+			Expect(err).To(MatchError(`invalid netns name "../etc"`))
+		})
+	})
+
+	Describe("PID references", func() {
+		It("resolves a bare PID or pid:<pid> to the namespace path", func() {
+			Expect(ns.ResolvePath("1234")).To(Equal("/proc/1234/ns/net"))
+			Expect(ns.ResolvePath("pid:1234")).To(Equal("/proc/1234/ns/net"))
+		})
+
+		It("leaves paths alone", func() {
+			Expect(ns.ResolvePath("/var/run/netns/test")).To(Equal("/var/run/netns/test"))
+			Expect(ns.ResolvePath("netns/1234")).To(Equal("netns/1234"))
+			Expect(ns.ResolvePath("")).To(Equal(""))
+		})
+
+		It("rejects invalid PIDs", func() {
+			for _, ref := range []string{"0", "pid:", "pid:0", "pid:-1", "pid:abc", "99999999999999999999"} {
+				_, err := ns.ResolvePath(ref)
+				Expect(err).To(MatchError(fmt.Sprintf("invalid PID in netns reference %q", ref)))
+			}
+		})
+
+		It("opens the namespace of the process", func() {
+			targetNetNS, err := ns.NewNS()
+			Expect(err).NotTo(HaveOccurred())
+			defer targetNetNS.Close()
+
+			// a thread of this process in the namespace stands in for a
+			// sandbox process
+			tids := make(chan int)
+			release := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				err := targetNetNS.Do(func(ns.NetNS) error {
+					tids <- unix.Gettid()
+					<-release
+					return nil
+				})
+				Expect(err).NotTo(HaveOccurred())
+			}()
+			tid := <-tids
+			defer close(release)
+
+			expectedInode, err := getInodeNS(targetNetNS)
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, ref := range []string{fmt.Sprintf("%d", tid), fmt.Sprintf("pid:%d", tid)} {
+				netns, err := ns.GetNS(ref)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(netns.Path()).To(Equal(fmt.Sprintf("/proc/%d/ns/net", tid)))
+				inode, err := getInodeNS(netns)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(inode).To(Equal(expectedInode))
+				Expect(netns.Close()).To(Succeed())
+			}
+		})
+
+		It("reports a process that is gone as a missing namespace", func() {
+			// larger than any pid_max
+			_, err := ns.GetNS("pid:1073741824")
+			Expect(err).To(BeAssignableToTypeOf(ns.NSPathNotExistErr{}))
+		})
+	})
+
 	Describe("IsNSorErr", func() {
 		It("should detect a namespace", func() {
 			createdNetNS, err := ns.NewNS()
@@ -0,0 +1,147 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package retry retries operations that can fail transiently, with
+// jittered exponential backoff between the attempts.
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"syscall"
+	"time"
+)
+
+// Backoff describes how often an operation is attempted and how long to
+// wait between the attempts
+type Backoff struct {
+	// Steps is the maximum number of attempts. Zero means to keep
+	// trying until the context is done.
+	Steps int
+	// Initial is the delay after the first failed attempt. Each further
+	// delay is Factor times the previous one, up to Max.
+	Initial time.Duration
+	Factor  float64
+	Max     time.Duration
+	// Jitter is the maximum random delay added to or subtracted from
+	// each delay
+	Jitter time.Duration
+}
+
+// DefaultNetlink is the backoff for netlink requests racing other
+// changes of the same links, which settle within milliseconds
+var DefaultNetlink = Backoff{
+	Steps:   5,
+	Initial: 10 * time.Millisecond,
+	Factor:  2,
+	Max:     100 * time.Millisecond,
+	Jitter:  5 * time.Millisecond,
+}
+
+// delay returns the delay after the given number of failed attempts
+func (b Backoff) delay(failed int) time.Duration {
+	factor := b.Factor
+	if factor < 1 {
+		factor = 1
+	}
+
+	d := b.Initial
+	for i := 1; i < failed; i++ {
+		d = time.Duration(float64(d) * factor)
+		if b.Max > 0 && d >= b.Max {
+			d = b.Max
+			break
+		}
+	}
+	if b.Max > 0 && d > b.Max {
+		d = b.Max
+	}
+	if b.Jitter > 0 {
+		d += time.Duration(float64(b.Jitter) * (2.0*rand.Float64() - 1.0))
+	}
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Permanent marks err as one that another attempt can't fix, which stops
+// Do from retrying
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err}
+}
+
+// Do calls f until it succeeds, returns a Permanent error, runs out of
+// attempts or ctx is done. It returns the error of the last attempt, or
+// the error of ctx if it is done before f succeeded once.
+func Do(ctx context.Context, b Backoff, f func() error) error {
+	var err error
+	for failed := 0; b.Steps == 0 || failed < b.Steps; {
+		if err = f(); err == nil {
+			return nil
+		}
+		if p, ok := err.(*permanentError); ok {
+			return p.err
+		}
+
+		failed++
+		if failed == b.Steps {
+			// no point in waiting after the last try
+			break
+		}
+
+		t := time.NewTimer(b.delay(failed))
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// OnTransient retries f with the DefaultNetlink backoff as long as it
+// fails with an error IsTransient reports
+func OnTransient(f func() error) error {
+	return Do(context.Background(), DefaultNetlink, func() error {
+		err := f()
+		if err != nil && !IsTransient(err) {
+			return Permanent(err)
+		}
+		return err
+	})
+}
+
+// IsTransient reports whether err is an errno that the kernel returns
+// for requests racing other changes, like EBUSY while a link is being
+// enslaved elsewhere
+func IsTransient(err error) bool {
+	switch err {
+	case syscall.EBUSY, syscall.EAGAIN, syscall.EINTR:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"time"
+
+	"github.com/containernetworking/cni/pkg/retry"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Do", func() {
+	backoff := retry.Backoff{
+		Steps:   4,
+		Initial: time.Millisecond,
+		Factor:  2,
+		Max:     4 * time.Millisecond,
+	}
+
+	failing := func(calls *int, failures int) func() error {
+		return func() error {
+			*calls++
+			if *calls <= failures {
+				return errors.New("try again")
+			}
+			return nil
+		}
+	}
+
+	It("retries until the operation succeeds", func() {
+		calls := 0
+		Expect(retry.Do(context.Background(), backoff, failing(&calls, 2))).To(Succeed())
+		Expect(calls).To(Equal(3))
+	})
+
+	It("returns the last error once it runs out of attempts", func() {
+		calls := 0
+		err := retry.Do(context.Background(), backoff, failing(&calls, 10))
+		Expect(err).To(MatchError("try again"))
+		Expect(calls).To(Equal(4))
+	})
+
+	It("stops on a permanent error", func() {
+		calls := 0
+		err := retry.Do(context.Background(), backoff, func() error {
+			calls++
+			return retry.Permanent(errors.New("broken"))
+		})
+		Expect(err).To(MatchError("broken"))
+		Expect(calls).To(Equal(1))
+	})
+
+	It("stops once the context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		err := retry.Do(ctx, retry.Backoff{Initial: time.Hour}, func() error {
+			calls++
+			cancel()
+			return errors.New("try again")
+		})
+		Expect(err).To(Equal(context.Canceled))
+		Expect(calls).To(Equal(1))
+	})
+
+	It("backs off exponentially up to the maximum", func() {
+		backoff := retry.Backoff{Steps: 5, Initial: 20 * time.Millisecond, Factor: 2, Max: 40 * time.Millisecond}
+		calls := 0
+		start := time.Now()
+		Expect(retry.Do(context.Background(), backoff, failing(&calls, 4))).To(Succeed())
+		// 20ms + 40ms + 40ms + 40ms
+		Expect(time.Since(start)).To(BeNumerically(">=", 140*time.Millisecond))
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+	})
+})
+
+var _ = Describe("OnTransient", func() {
+	It("retries transient errors", func() {
+		calls := 0
+		err := retry.OnTransient(func() error {
+			calls++
+			if calls == 1 {
+				return syscall.EBUSY
+			}
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(calls).To(Equal(2))
+	})
+
+	It("doesn't retry other errors", func() {
+		calls := 0
+		err := retry.OnTransient(func() error {
+			calls++
+			return syscall.EPERM
+		})
+		Expect(err).To(Equal(syscall.EPERM))
+		Expect(calls).To(Equal(1))
+	})
+})
@@ -0,0 +1,97 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scaffold generates the skeleton of a new plugin, wired up the
+// way the plugins of this repository are.
+package scaffold
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+type params struct {
+	Name string
+	// Year for the license header
+	Year int
+}
+
+// Files returns the sources of a plugin called name, keyed by file name
+func Files(name string, year int) (map[string][]byte, error) {
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("invalid plugin name %q: must consist of lower case letters, digits, '.', '-' and '_'", name)
+	}
+
+	p := params{Name: name, Year: year}
+	files := map[string][]byte{}
+	for file, text := range templates {
+		var buf bytes.Buffer
+		if err := template.Must(template.New(file).Parse(text)).Execute(&buf, p); err != nil {
+			return nil, err
+		}
+		src, err := format.Source(buf.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("generated invalid source for %s: %v", file, err)
+		}
+		files[strings.Replace(file, "NAME", fileName(name), 1)] = src
+	}
+	return files, nil
+}
+
+// Generate writes the sources of a plugin called name to dir, which
+// must not exist yet or be empty. It returns the names of the written
+// files.
+func Generate(dir, name string, year int) ([]string, error) {
+	files, err := Files(name, year)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	existing, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) != 0 {
+		return nil, fmt.Errorf("%s is not empty", dir)
+	}
+
+	names := []string{}
+	for file, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, file), src, 0644); err != nil {
+			return nil, err
+		}
+		names = append(names, file)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// fileName turns the plugin name into something usable in file names of
+// a Go package, where e.g. a ".test" suffix has a meaning of its own
+func fileName(name string) string {
+	return strings.NewReplacer(".", "_", "-", "_").Replace(name)
+}
@@ -0,0 +1,74 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaffold_test
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/containernetworking/cni/pkg/scaffold"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Generate", func() {
+	var tmpDir string
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "cni-skel")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	It("writes the sources of a package main", func() {
+		dir := filepath.Join(tmpDir, "my-plugin")
+		files, err := scaffold.Generate(dir, "my-plugin", 2016)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(Equal([]string{"main.go", "my_plugin_suite_test.go", "my_plugin_test.go"}))
+
+		fset := token.NewFileSet()
+		pkgs, err := parser.ParseDir(fset, dir, nil, parser.ImportsOnly)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pkgs).To(HaveLen(1))
+		Expect(pkgs).To(HaveKey("main"))
+
+		main, err := ioutil.ReadFile(filepath.Join(dir, "main.go"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(main)).To(HavePrefix("// Copyright 2016 CNI authors\n"))
+		Expect(string(main)).To(ContainSubstring("skel.PluginMainWithSchema(cmdAdd, cmdDel, schema.ForNetConf(&NetConf{}))"))
+	})
+
+	It("refuses to overwrite existing files", func() {
+		Expect(ioutil.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n"), 0644)).To(Succeed())
+
+		_, err := scaffold.Generate(tmpDir, "my-plugin", 2016)
+		Expect(err).To(MatchError(tmpDir + " is not empty"))
+	})
+
+	It("rejects names that aren't usable as binary names", func() {
+		_, err := scaffold.Files("../evil", 2016)
+		Expect(err).To(HaveOccurred())
+		_, err = scaffold.Files("Plugin", 2016)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,186 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaffold
+
+const license = `// Copyright {{.Year}} CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+`
+
+// templates of the generated files, keyed by file name. NAME is replaced
+// by the plugin name.
+var templates = map[string]string{
+	"main.go": license + `// {{.Name}} is a CNI plugin.
+// TODO: describe what it does.
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/containernetworking/cni/pkg/ipam"
+	"github.com/containernetworking/cni/pkg/schema"
+	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/version"
+)
+
+// NetConf is the network configuration of the plugin
+type NetConf struct {
+	types.NetConf
+
+	// TODO: add the settings of the plugin
+	Example string ` + "`json:\"example\"`" + `
+}
+
+func loadNetConf(bytes []byte) (*NetConf, error) {
+	n := &NetConf{}
+	if err := json.Unmarshal(bytes, n); err != nil {
+		return nil, types.NewError(types.ErrDecodingFailure, "failed to load netconf", err.Error())
+	}
+	return n, nil
+}
+
+func cmdAdd(args *skel.CmdArgs) error {
+	n, err := loadNetConf(args.StdinData)
+	if err != nil {
+		return err
+	}
+
+	result := &types.Result{}
+	if n.IPAM.Type != "" {
+		result, err = ipam.ExecAdd(n.IPAM.Type, args.StdinData)
+		if err != nil {
+			return err
+		}
+	}
+
+	// TODO: set up args.IfName in the namespace at args.Netns, e.g.
+	// with ns.WithNetNSPath and ipam.ConfigureIface(args.IfName, result)
+
+	result.DNS = n.DNS
+	// skel made sure the result can be printed in n.CNIVersion
+	return version.PrintResult(result, n.CNIVersion)
+}
+
+func cmdDel(args *skel.CmdArgs) error {
+	n, err := loadNetConf(args.StdinData)
+	if err != nil {
+		return err
+	}
+
+	// TODO: tear down what cmdAdd set up. DEL may be called more than
+	// once and for attachments whose ADD failed, so don't fail if there
+	// is nothing left to remove.
+
+	if n.IPAM.Type != "" {
+		return ipam.ExecDel(n.IPAM.Type, args.StdinData)
+	}
+	return nil
+}
+
+func main() {
+	skel.PluginMainWithSchema(cmdAdd, cmdDel, schema.ForNetConf(&NetConf{}))
+}
+`,
+
+	"NAME_suite_test.go": license + `package main
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestPlugin(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "{{.Name}} Suite")
+}
+`,
+
+	"NAME_test.go": license + `package main
+
+import (
+	"github.com/containernetworking/cni/pkg/ns"
+	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/cni/pkg/testutils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("{{.Name}} Operations", func() {
+	const IFNAME = "eth0"
+
+	var (
+		targetNs ns.NetNS
+		args     *skel.CmdArgs
+	)
+
+	BeforeEach(func() {
+		var err error
+		targetNs, err = ns.NewNS()
+		Expect(err).NotTo(HaveOccurred())
+
+		args = &skel.CmdArgs{
+			ContainerID: "dummy",
+			Netns:       targetNs.Path(),
+			IfName:      IFNAME,
+			StdinData: []byte(` + "`" + `{
+    "cniVersion": "0.2.0",
+    "name": "mynet",
+    "type": "{{.Name}}",
+    "example": "value"
+}` + "`" + `),
+		}
+	})
+
+	AfterEach(func() {
+		Expect(targetNs.Close()).To(Succeed())
+	})
+
+	It("handles ADD and DEL", func() {
+		result, err := testutils.CmdAddWithResult(targetNs.Path(), IFNAME, func() error {
+			return cmdAdd(args)
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.IP4).To(BeNil())
+
+		// TODO: check what cmdAdd set up in targetNs
+
+		err = testutils.CmdDelWithResult(targetNs.Path(), IFNAME, func() error {
+			return cmdDel(args)
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("rejects malformed configurations", func() {
+		_, err := loadNetConf([]byte(` + "`" + `{"name": "mynet", "type": "{{.Name}}", "example": 42}` + "`" + `))
+		Expect(err).To(HaveOccurred())
+	})
+})
+`,
+}
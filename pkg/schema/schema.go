@@ -0,0 +1,221 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package schema describes the network configuration a plugin accepts,
+// in a subset of JSON Schema, and checks configurations against it.
+package schema
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Schema is the subset of JSON Schema needed to describe configurations
+// decoded with encoding/json. An empty Type accepts any value.
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+}
+
+var (
+	jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// FromType returns the schema of the JSON that encoding/json decodes
+// into v, typically a pointer to a plugin's NetConf struct. Objects
+// only accept the fields of the struct, which is what catches typos.
+func FromType(v interface{}) *Schema {
+	return fromType(reflect.TypeOf(v))
+}
+
+// ForNetConf is FromType for a plugin's network configuration. It also
+// accepts the well-known fields of the spec that the plugin itself may
+// not decode, like cniVersion and args.
+func ForNetConf(netconf interface{}) *Schema {
+	s := FromType(netconf)
+	if s.Properties == nil {
+		return s
+	}
+	if _, ok := s.Properties["cniVersion"]; !ok {
+		s.Properties["cniVersion"] = &Schema{Type: "string"}
+	}
+	if _, ok := s.Properties["args"]; !ok {
+		s.Properties["args"] = &Schema{Type: "object", AdditionalProperties: &Schema{}}
+	}
+	return s
+}
+
+func fromType(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// types decoding themselves can't be described further
+	if reflect.PtrTo(t).Implements(jsonUnmarshaler) || reflect.PtrTo(t).Implements(textUnmarshaler) {
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		addFields(s, t)
+		return s
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: fromType(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: fromType(t.Elem())}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	default:
+		return &Schema{}
+	}
+}
+
+func addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Name
+		if tag := f.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			if n := strings.Split(tag, ",")[0]; n != "" {
+				name = n
+			}
+		}
+
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && f.Tag.Get("json") == "" && ft.Kind() == reflect.Struct {
+			// encoding/json promotes the fields of embedded structs
+			addFields(s, ft)
+			continue
+		}
+		if f.PkgPath != "" {
+			// unexported
+			continue
+		}
+		s.Properties[name] = fromType(f.Type)
+	}
+}
+
+// Validate checks the configuration in data against s and returns
+// a description of every problem found, in a stable order
+func (s *Schema) Validate(data []byte) ([]string, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	problems := s.ValidateValue(v, "")
+	sort.Strings(problems)
+	return problems, nil
+}
+
+// ValidateValue checks a value decoded by encoding/json into an
+// interface{}. path prefixes the locations in the returned problems.
+func (s *Schema) ValidateValue(v interface{}, path string) []string {
+	problems := []string{}
+	at := path
+	if at == "" {
+		at = "top level"
+	}
+
+	if v == nil {
+		// encoding/json accepts null for every type
+		return problems
+	}
+
+	switch s.Type {
+	case "":
+		return problems
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return append(problems, fmt.Sprintf("%s: expected an object", at))
+		}
+		for key, value := range obj {
+			prop := s.property(key)
+			if prop == nil {
+				problems = append(problems, fmt.Sprintf("%s: unknown field %q", at, key))
+				continue
+			}
+			problems = append(problems, prop.ValidateValue(value, join(path, key))...)
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return append(problems, fmt.Sprintf("%s: expected an array", at))
+		}
+		for i, value := range arr {
+			problems = append(problems, s.Items.ValidateValue(value, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			problems = append(problems, fmt.Sprintf("%s: expected a string", at))
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			problems = append(problems, fmt.Sprintf("%s: expected a boolean", at))
+		}
+	case "integer":
+		if n, ok := v.(float64); !ok || n != float64(int64(n)) {
+			problems = append(problems, fmt.Sprintf("%s: expected an integer", at))
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			problems = append(problems, fmt.Sprintf("%s: expected a number", at))
+		}
+	}
+	return problems
+}
+
+// property looks up the schema of key the way encoding/json matches
+// keys to fields: exactly if possible, otherwise case-insensitively
+func (s *Schema) property(key string) *Schema {
+	if s.AdditionalProperties != nil {
+		return s.AdditionalProperties
+	}
+	if p, ok := s.Properties[key]; ok {
+		return p
+	}
+	for name, p := range s.Properties {
+		if strings.EqualFold(name, key) {
+			return p
+		}
+	}
+	return nil
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
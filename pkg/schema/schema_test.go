@@ -0,0 +1,121 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema_test
+
+import (
+	"encoding/json"
+	"net"
+
+	"github.com/containernetworking/cni/pkg/schema"
+	"github.com/containernetworking/cni/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type testConf struct {
+	types.NetConf
+	Bridge string            `json:"bridge"`
+	MTU    int               `json:"mtu"`
+	IsGW   bool              `json:"isGateway"`
+	Subnet types.IPNet       `json:"subnet"`
+	Hosts  []net.IP          `json:"hosts"`
+	Labels map[string]string `json:"labels"`
+	Ignore string            `json:"-"`
+	Legacy string
+	hidden string
+}
+
+var _ = Describe("Schema", func() {
+	var s *schema.Schema
+
+	BeforeEach(func() {
+		s = schema.ForNetConf(&testConf{})
+	})
+
+	It("describes the fields of the struct", func() {
+		Expect(s.Type).To(Equal("object"))
+		Expect(s.Properties).To(HaveKey("name"))
+		Expect(s.Properties).To(HaveKey("ipam"))
+		Expect(s.Properties).To(HaveKey("Legacy"))
+		Expect(s.Properties).NotTo(HaveKey("Ignore"))
+		Expect(s.Properties).NotTo(HaveKey("-"))
+		Expect(s.Properties).NotTo(HaveKey("hidden"))
+		Expect(s.Properties["mtu"].Type).To(Equal("integer"))
+		Expect(s.Properties["isGateway"].Type).To(Equal("boolean"))
+		Expect(s.Properties["hosts"].Type).To(Equal("array"))
+		Expect(s.Properties["labels"].AdditionalProperties.Type).To(Equal("string"))
+
+		// decodes itself from a string
+		Expect(s.Properties["subnet"].Type).To(BeEmpty())
+	})
+
+	It("accepts the well-known fields of the spec", func() {
+		problems, err := s.Validate([]byte(`{"cniVersion": "0.2.0", "name": "n", "args": {"labels": {"a": "b"}}}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(problems).To(BeEmpty())
+	})
+
+	It("accepts a valid configuration", func() {
+		problems, err := s.Validate([]byte(`{
+			"name": "n",
+			"type": "bridge",
+			"bridge": "cni0",
+			"MTU": 1400,
+			"isGateway": true,
+			"subnet": "10.0.0.0/8",
+			"hosts": ["10.0.0.1"],
+			"labels": {"any": "thing"},
+			"ipam": {"type": "host-local"},
+			"dns": {"nameservers": ["10.0.0.1"]},
+			"legacy": null
+		}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(problems).To(BeEmpty())
+	})
+
+	It("reports unknown fields and wrong types", func() {
+		problems, err := s.Validate([]byte(`{
+			"name": "n",
+			"isGatway": true,
+			"mtu": 1400.5,
+			"bridge": 7,
+			"labels": {"a": 1},
+			"dns": {"nameserver": []}
+		}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(problems).To(Equal([]string{
+			"bridge: expected a string",
+			`dns: unknown field "nameserver"`,
+			"labels.a: expected a string",
+			"mtu: expected an integer",
+			`top level: unknown field "isGatway"`,
+		}))
+	})
+
+	It("fails on malformed JSON", func() {
+		_, err := s.Validate([]byte(`{"name": `))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("round-trips through JSON", func() {
+		data, err := json.Marshal(s)
+		Expect(err).NotTo(HaveOccurred())
+
+		decoded := &schema.Schema{}
+		Expect(json.Unmarshal(data, decoded)).To(Succeed())
+		Expect(decoded).To(Equal(s))
+	})
+})
@@ -17,12 +17,26 @@
 package skel
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
+	"net/url"
 	"os"
+	"path/filepath"
+	"runtime"
+	"time"
 
+	"github.com/containernetworking/cni/pkg/caps"
+	"github.com/containernetworking/cni/pkg/events"
+	"github.com/containernetworking/cni/pkg/hooks"
+	"github.com/containernetworking/cni/pkg/invoke"
+	"github.com/containernetworking/cni/pkg/logging"
+	"github.com/containernetworking/cni/pkg/metrics"
+	"github.com/containernetworking/cni/pkg/retry"
+	"github.com/containernetworking/cni/pkg/schema"
 	"github.com/containernetworking/cni/pkg/types"
 	"github.com/containernetworking/cni/pkg/version"
 )
@@ -36,14 +50,66 @@ type CmdArgs struct {
 	Args        string
 	Path        string
 	StdinData   []byte
+
+	dropCaps bool
+	// deferDrop makes DropCapabilities only record the capabilities to
+	// keep in keepCaps, for skel to drop the others once the post hooks,
+	// which need them, are done
+	deferDrop bool
+	dropped   bool
+	keepCaps  []caps.Cap
+}
+
+// DropCapabilities drops all capabilities of the plugin but keep. Plugins
+// call it once they are done with the namespace and netlink work which
+// needs them, and before anything that doesn't, like writing state or
+// printing the result. Programs executed afterwards don't get them back,
+// so delegation must happen before too.
+//
+// It does nothing unless the plugin runs from PluginMain, so that tests
+// calling cmdAdd directly keep theirs. If hooks are configured, the
+// capabilities are dropped after the post hooks instead.
+func (args *CmdArgs) DropCapabilities(keep ...caps.Cap) {
+	if !args.dropCaps {
+		return
+	}
+	if args.deferDrop {
+		args.dropped = true
+		args.keepCaps = keep
+		return
+	}
+	if err := caps.Drop(keep...); err != nil {
+		logging.Debugf("failed to drop capabilities: %v", err)
+	}
+}
+
+// dropDeferred drops the capabilities whose drop was deferred, if the
+// plugin asked for it
+func (args *CmdArgs) dropDeferred() {
+	if !args.dropped {
+		return
+	}
+	args.deferDrop = false
+	args.DropCapabilities(args.keepCaps...)
 }
 
 type dispatcher struct {
 	Getenv    func(string) string
+	Setenv    func(string, string) error
 	Stdin     io.Reader
 	Stdout    io.Writer
 	Stderr    io.Writer
 	Versioner version.PluginVersioner
+	Schema    *schema.Schema
+	// NetNSOptional makes CNI_NETNS optional for ADD too. Windows
+	// runtimes attach containers to HNS networks by container ID, and
+	// the namespace given, if any, is an ID rather than a path.
+	NetNSOptional bool
+	// MetricsDir is where the metrics of ADD and DEL are recorded, if
+	// not empty
+	MetricsDir string
+	// DropCapabilities lets CmdArgs.DropCapabilities drop capabilities
+	DropCapabilities bool
 }
 
 type reqForCmdEntry map[string]bool
@@ -110,6 +176,9 @@ func (t *dispatcher) getCmdArgsFromEnv() (string, *CmdArgs, error) {
 	for _, v := range vars {
 		*v.val = t.Getenv(v.name)
 		if *v.val == "" {
+			if v.name == "CNI_NETNS" && t.NetNSOptional {
+				continue
+			}
 			if v.reqForCmd[cmd] || v.name == "CNI_COMMAND" {
 				fmt.Fprintf(t.Stderr, "%v env variable missing\n", v.name)
 				argsMissing = true
@@ -118,12 +187,12 @@ func (t *dispatcher) getCmdArgsFromEnv() (string, *CmdArgs, error) {
 	}
 
 	if argsMissing {
-		return "", nil, fmt.Errorf("required env variables missing")
+		return "", nil, types.NewError(types.ErrInvalidEnvironmentVariables, "required env variables missing", "")
 	}
 
 	stdinData, err := ioutil.ReadAll(t.Stdin)
 	if err != nil {
-		return "", nil, fmt.Errorf("error reading from stdin: %v", err)
+		return "", nil, types.NewError(types.ErrIOFailure, "error reading from stdin", err.Error())
 	}
 
 	cmdArgs := &CmdArgs{
@@ -133,56 +202,471 @@ func (t *dispatcher) getCmdArgsFromEnv() (string, *CmdArgs, error) {
 		Args:        args,
 		Path:        path,
 		StdinData:   stdinData,
+		dropCaps:    t.DropCapabilities,
 	}
 	return cmd, cmdArgs, nil
 }
 
+// checkNetConf makes sure the plugin can reply in the cniVersion of the
+// network configuration and that a prevResult handed in by the caller can
+// be parsed, so that plugins don't have to deal with a half-valid chain
+func checkNetConf(stdinData []byte) error {
+	conf := struct {
+		CNIVersion string           `json:"cniVersion"`
+		PrevResult *json.RawMessage `json:"prevResult"`
+	}{}
+	if err := json.Unmarshal(stdinData, &conf); err != nil {
+		// plugins report malformed netconfs themselves
+		return nil
+	}
+	if err := version.Check(conf.CNIVersion); err != nil {
+		return err
+	}
+	if conf.PrevResult == nil {
+		return nil
+	}
+
+	result := types.Result{}
+	if err := json.Unmarshal(*conf.PrevResult, &result); err != nil {
+		return types.NewError(types.ErrDecodingFailure, "failed to parse prevResult", err.Error())
+	}
+	return nil
+}
+
+// extensions are the sections of the network configuration that skel
+// handles for every plugin, on top of the spec
+type extensions struct {
+	// Log configures the logging of the plugin
+	Log logging.Config `json:"log,omitempty"`
+	// Hooks configures the hooks run around ADD and DEL
+	Hooks hooks.Config `json:"hooks,omitempty"`
+	// Events configures where attach and detach events are emitted
+	Events events.Config `json:"events,omitempty"`
+	// LockDir is where a lock is taken per container and interface, so
+	// that the ADDs and DELs of an interface run one at a time
+	LockDir string `json:"lockDir,omitempty"`
+}
+
+// parseExtensions returns the extensions of the network configuration
+// stdinData. A malformed configuration is reported by the plugin itself,
+// so it just has none.
+func parseExtensions(stdinData []byte) extensions {
+	ext := extensions{}
+	json.Unmarshal(stdinData, &ext)
+	return ext
+}
+
+// withExtensions returns s accepting the extensions too, since plugins
+// describe only the configuration they decode themselves
+func withExtensions(s *schema.Schema) *schema.Schema {
+	if s.Properties == nil {
+		return s
+	}
+	n := *s
+	n.Properties = map[string]*schema.Schema{}
+	for key, p := range schema.FromType(&extensions{}).Properties {
+		n.Properties[key] = p
+	}
+	for key, p := range s.Properties {
+		n.Properties[key] = p
+	}
+	return &n
+}
+
+// runCmd runs the callback of an ADD or DEL, with logging set up and the
+// outcome recorded in the metrics
+func (t *dispatcher) runCmd(cmd string, cmdArgs *CmdArgs, f func(*CmdArgs) error) error {
+	start := time.Now()
+	ext := parseExtensions(cmdArgs.StdinData)
+	traceID, err := t.traceID()
+	if err == nil {
+		err = t.setupLogging(cmd, cmdArgs, ext.Log, traceID)
+	}
+	if err == nil {
+		err = checkNetConf(cmdArgs.StdinData)
+	}
+	sink := t.eventSink(ext.Events)
+	var result []byte
+	if err == nil {
+		var unlock func(bool)
+		if unlock, err = t.lockContainer(cmdArgs, ext.LockDir); err == nil {
+			result, err = t.runWithHooks(cmd, cmdArgs, ext.Hooks, t.markDelegates(f), sink != "")
+			// nothing is left to serialize once the interface is gone
+			unlock(cmd == "DEL" && err == nil)
+		}
+	}
+	d := time.Since(start)
+	t.recordMetrics(cmd, cmdArgs, d, err)
+	if sink != "" {
+		t.emitEvent(sink, cmd, cmdArgs, traceID, d, result, err)
+	}
+	return err
+}
+
+// lockContainer waits for other invocations for the container and
+// interface of cmdArgs to finish and locks them out until the returned
+// func is called, if dir, the lock dir of the network configuration, or
+// CNI_LOCK_DIR is set. The lock is released on exit as well, so plugins killed by
+// the runtime don't keep it. Passing true to the returned func removes
+// the lock file before releasing the lock.
+func (t *dispatcher) lockContainer(cmdArgs *CmdArgs, dir string) (func(bool), error) {
+	if env := t.Getenv("CNI_LOCK_DIR"); env != "" {
+		dir = env
+	}
+	if dir == "" || cmdArgs.ContainerID == "" {
+		return func(bool) {}, nil
+	}
+
+	// interface names can't contain a slash, so this is unambiguous
+	path := filepath.Join(dir, url.QueryEscape(cmdArgs.ContainerID+"/"+cmdArgs.IfName))
+	// plugins this one delegates to run while it holds the lock
+	if t.Getenv("CNI_LOCK_HELD") == path {
+		return func(bool) {}, nil
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, types.NewError(types.ErrIOFailure, "failed to create lock dir", err.Error())
+	}
+	f, err := lockFile(path, func() {
+		logging.Debugf("waiting for another operation on %s/%s", cmdArgs.ContainerID, cmdArgs.IfName)
+	})
+	if err != nil {
+		return nil, types.NewError(types.ErrIOFailure, "failed to lock container", err.Error())
+	}
+	if t.Setenv != nil {
+		if err := t.Setenv("CNI_LOCK_HELD", path); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to set CNI_LOCK_HELD: %v", err)
+		}
+	}
+
+	return func(remove bool) {
+		if t.Setenv != nil {
+			t.Setenv("CNI_LOCK_HELD", "")
+		}
+		if remove {
+			// lockFile makes those waiting for it retry on a new file
+			os.Remove(path)
+		}
+		f.Close()
+	}, nil
+}
+
+// markDelegates makes f run with CNI_DELEGATED_BY set to the name of the
+// plugin, so that the plugins it delegates to know they are delegates
+func (t *dispatcher) markDelegates(f func(*CmdArgs) error) func(*CmdArgs) error {
+	if t.Setenv == nil {
+		return f
+	}
+	return func(cmdArgs *CmdArgs) error {
+		if err := t.Setenv("CNI_DELEGATED_BY", filepath.Base(os.Args[0])); err != nil {
+			return fmt.Errorf("failed to set CNI_DELEGATED_BY: %v", err)
+		}
+		defer t.Setenv("CNI_DELEGATED_BY", "")
+		return f(cmdArgs)
+	}
+}
+
+// runWithHooks runs f between the pre and post hooks configured by conf
+// or CNI_HOOKS_DIR, if there are any. A failing pre hook fails the command.
+// Post hooks only run if f succeeded, and their failures are only logged
+// since f is done by then.
+//
+// The result printed by an ADD is returned if the post hooks need it or
+// wantResult is set.
+func (t *dispatcher) runWithHooks(cmd string, cmdArgs *CmdArgs, conf hooks.Config, f func(*CmdArgs) error, wantResult bool) ([]byte, error) {
+	dir := hooks.DirFromConfig(conf, t.Getenv)
+	// delegates get the configuration of their caller, hooks included,
+	// but the hooks are for the caller to run
+	if t.Getenv("CNI_DELEGATED_BY") != "" {
+		dir = ""
+	}
+
+	var result []byte
+	if cmd == "ADD" && (dir != "" || wantResult) {
+		run := f
+		f = func(cmdArgs *CmdArgs) error {
+			out, err := captureStdout(func() error { return run(cmdArgs) })
+			result = out
+			if _, werr := t.Stdout.Write(out); werr != nil && err == nil {
+				err = werr
+			}
+			return err
+		}
+	}
+	if dir == "" {
+		return result, f(cmdArgs)
+	}
+
+	// post hooks run after f and may need the capabilities f drops, e.g.
+	// to set up firewalling
+	cmdArgs.deferDrop = true
+	defer cmdArgs.dropDeferred()
+
+	env := (&invoke.Args{
+		Command:       cmd,
+		ContainerID:   cmdArgs.ContainerID,
+		NetNS:         cmdArgs.Netns,
+		PluginArgsStr: cmdArgs.Args,
+		IfName:        cmdArgs.IfName,
+		Path:          cmdArgs.Path,
+	}).AsEnv()
+	if err := hooks.Run(dir, hooks.StagePre, env, cmdArgs.StdinData); err != nil {
+		return nil, err
+	}
+	if err := f(cmdArgs); err != nil {
+		return result, err
+	}
+
+	// post hooks get the result as the prevResult of the configuration
+	stdin, err := withPrevResult(cmdArgs.StdinData, result)
+	if err != nil {
+		logging.Warningf("failed to pass the result to post hooks: %v", err)
+		stdin = cmdArgs.StdinData
+	}
+	if err := hooks.Run(dir, hooks.StagePost, env, stdin); err != nil {
+		logging.Warningf("%v", err)
+	}
+	return result, nil
+}
+
+// captureStdout runs f with os.Stdout redirected to a temporary file and
+// returns what f wrote to it
+func captureStdout(f func() error) ([]byte, error) {
+	tmp, err := ioutil.TempFile("", "cni-result")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create result file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	stdout := os.Stdout
+	os.Stdout = tmp
+	err = f()
+	os.Stdout = stdout
+
+	out, rerr := ioutil.ReadFile(tmp.Name())
+	if rerr != nil && err == nil {
+		err = fmt.Errorf("failed to read result file: %v", rerr)
+	}
+	return out, err
+}
+
+// withPrevResult returns netconf with its "prevResult" set to result,
+// or netconf unchanged if the plugin printed no result
+func withPrevResult(netconf, result []byte) ([]byte, error) {
+	if len(result) == 0 {
+		return netconf, nil
+	}
+
+	r := &types.Result{}
+	if err := json.Unmarshal(result, r); err != nil {
+		return nil, fmt.Errorf("failed to parse result: %v", err)
+	}
+	return types.InjectPrevResult(netconf, r)
+}
+
+func (t *dispatcher) recordMetrics(cmd string, cmdArgs *CmdArgs, d time.Duration, err error) {
+	if t.MetricsDir == "" {
+		return
+	}
+
+	var code uint
+	if err != nil {
+		code = types.ErrPlugin
+		if e, ok := err.(*types.Error); ok {
+			code = e.Code
+		}
+	}
+	conf := struct {
+		Name string `json:"name"`
+	}{}
+	json.Unmarshal(cmdArgs.StdinData, &conf)
+
+	if err := metrics.Record(t.MetricsDir, filepath.Base(os.Args[0]), conf.Name, cmd, d, code); err != nil {
+		logging.Debugf("failed to record metrics: %v", err)
+	}
+}
+
+// eventSink returns the sink events are emitted to, if conf or
+// CNI_EVENTS_SINK sets one. Delegates emit none, since
+// their caller emits the event of the whole operation.
+func (t *dispatcher) eventSink(conf events.Config) string {
+	if t.Getenv("CNI_DELEGATED_BY") != "" {
+		return ""
+	}
+	return events.SinkFromConfig(conf, t.Getenv)
+}
+
+// emitEvent emits the attach or detach event of an ADD or DEL, with the
+// IPs of the result printed by an ADD
+func (t *dispatcher) emitEvent(sink, cmd string, cmdArgs *CmdArgs, traceID string, d time.Duration, result []byte, err error) {
+	conf := struct {
+		Name string `json:"name"`
+	}{}
+	json.Unmarshal(cmdArgs.StdinData, &conf)
+
+	e := &events.Event{
+		Time:            time.Now().UTC(),
+		Type:            events.TypeAttach,
+		Plugin:          filepath.Base(os.Args[0]),
+		Network:         conf.Name,
+		ContainerID:     cmdArgs.ContainerID,
+		Netns:           cmdArgs.Netns,
+		IfName:          cmdArgs.IfName,
+		DurationSeconds: d.Seconds(),
+		TraceID:         traceID,
+	}
+	if cmd == "DEL" {
+		e.Type = events.TypeDetach
+	}
+	if err != nil {
+		e.Error = err.Error()
+		e.Code = types.ErrPlugin
+		if te, ok := err.(*types.Error); ok {
+			e.Error = te.Msg
+			e.Code = te.Code
+		}
+	}
+	r := types.Result{}
+	if len(result) > 0 && json.Unmarshal(result, &r) == nil {
+		for _, ipc := range []*types.IPConfig{r.IP4, r.IP6} {
+			if ipc != nil {
+				e.IPs = append(e.IPs, ipc.IP.String())
+			}
+		}
+	}
+
+	if err := events.Emit(sink, e); err != nil {
+		logging.Debugf("failed to emit event: %v", err)
+	}
+}
+
+// traceID returns the CNI_TRACE_ID of this invocation. If the caller
+// didn't pass one, a new ID is set in the environment, so that delegates
+// invoked from here share it.
+func (t *dispatcher) traceID() (string, error) {
+	if id := t.Getenv("CNI_TRACE_ID"); id != "" {
+		return id, nil
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate trace ID: %v", err)
+	}
+	id := hex.EncodeToString(b)
+	if t.Setenv != nil {
+		if err := t.Setenv("CNI_TRACE_ID", id); err != nil {
+			return "", fmt.Errorf("failed to set CNI_TRACE_ID: %v", err)
+		}
+	}
+	return id, nil
+}
+
+// setupLogging makes the logging package log as configured by conf and
+// the environment, tagged with the container and
+// trace IDs
+func (t *dispatcher) setupLogging(cmd string, cmdArgs *CmdArgs, conf logging.Config, traceID string) error {
+	logger, err := logging.FromConfig(conf, t.Getenv, t.Stderr)
+	if err != nil {
+		return err
+	}
+	logger = logger.With("containerId", cmdArgs.ContainerID).With("traceId", traceID)
+	logging.SetDefault(logger)
+
+	logger.Debugf("%s netns=%s ifName=%s args=%s", cmd, cmdArgs.Netns, cmdArgs.IfName, cmdArgs.Args)
+	return nil
+}
+
 func createTypedError(f string, args ...interface{}) *types.Error {
 	return &types.Error{
-		Code: 100,
+		Code: types.ErrPlugin,
 		Msg:  fmt.Sprintf(f, args...),
 	}
 }
 
+// asTypedError returns err as an Error. Errors which aren't one already
+// get the generic plugin code, or ErrTryAgainLater if they are errnos
+// that another attempt may not get.
+func asTypedError(err error) *types.Error {
+	if e, ok := err.(*types.Error); ok {
+		// don't wrap Error in Error
+		return e
+	}
+	if retry.IsTransient(err) {
+		return types.NewError(types.ErrTryAgainLater, err.Error(), "")
+	}
+	return createTypedError("%v", err)
+}
+
+func unknownCommand(cmd string) *types.Error {
+	return types.NewError(types.ErrInvalidEnvironmentVariables, fmt.Sprintf("unknown CNI_COMMAND: %v", cmd), "")
+}
+
 func (t *dispatcher) pluginMain(cmdAdd, cmdDel func(_ *CmdArgs) error) *types.Error {
 	cmd, cmdArgs, err := t.getCmdArgsFromEnv()
 	if err != nil {
-		return createTypedError(err.Error())
+		return asTypedError(err)
 	}
 
 	switch cmd {
 	case "ADD":
-		err = cmdAdd(cmdArgs)
+		err = t.runCmd(cmd, cmdArgs, cmdAdd)
 
 	case "DEL":
-		err = cmdDel(cmdArgs)
+		err = t.runCmd(cmd, cmdArgs, cmdDel)
 
 	case "VERSION":
 		err = t.Versioner.Encode(t.Stdout)
 
+	case "SCHEMA":
+		if t.Schema == nil {
+			return unknownCommand(cmd)
+		}
+		err = json.NewEncoder(t.Stdout).Encode(withExtensions(t.Schema))
+
 	default:
-		return createTypedError("unknown CNI_COMMAND: %v", cmd)
+		return unknownCommand(cmd)
 	}
 
 	if err != nil {
-		if e, ok := err.(*types.Error); ok {
-			// don't wrap Error in Error
-			return e
-		}
-		return createTypedError(err.Error())
+		logging.Errorf("%s failed: %v", cmd, err)
+		return asTypedError(err)
 	}
 	return nil
 }
 
+// metricsDir returns the directory of CNI_METRICS_DIR, or the default
+// one. Metrics are only recorded if it exists.
+func metricsDir() string {
+	if dir := os.Getenv("CNI_METRICS_DIR"); dir != "" {
+		return dir
+	}
+	return metrics.DefaultDir
+}
+
 // PluginMain is the "main" for a plugin. It accepts
 // two callback functions for add and del commands.
 func PluginMain(cmdAdd, cmdDel func(_ *CmdArgs) error) {
+	PluginMainWithSchema(cmdAdd, cmdDel, nil)
+}
+
+// PluginMainWithSchema is PluginMain for plugins which describe the
+// network configuration they accept. The schema is printed when the
+// plugin is called with CNI_COMMAND=SCHEMA, an extension to the spec
+// used by tools like "cnitool validate".
+func PluginMainWithSchema(cmdAdd, cmdDel func(_ *CmdArgs) error, s *schema.Schema) {
 	caller := dispatcher{
-		Getenv:    os.Getenv,
-		Stdin:     os.Stdin,
-		Stdout:    os.Stdout,
-		Stderr:    os.Stderr,
-		Versioner: version.DefaultPluginVersioner,
+		Getenv:           os.Getenv,
+		Setenv:           os.Setenv,
+		Stdin:            os.Stdin,
+		Stdout:           os.Stdout,
+		Stderr:           os.Stderr,
+		Versioner:        version.DefaultPluginVersioner,
+		Schema:           s,
+		NetNSOptional:    runtime.GOOS == "windows",
+		MetricsDir:       metricsDir(),
+		DropCapabilities: true,
 	}
 
 	err := caller.pluginMain(cmdAdd, cmdDel)
@@ -193,7 +677,7 @@ func PluginMain(cmdAdd, cmdDel func(_ *CmdArgs) error) {
 
 func dieErr(e *types.Error) {
 	if err := e.Print(); err != nil {
-		log.Print("Error writing error JSON to stdout: ", err)
+		logging.Errorf("Error writing error JSON to stdout: %v", err)
 	}
 	os.Exit(1)
 }
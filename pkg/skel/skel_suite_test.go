@@ -15,12 +15,31 @@
 package skel
 
 import (
+	"os"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
 
 	"testing"
 )
 
+var pathToDropCaps string
+
+var _ = BeforeSuite(func() {
+	// capabilities can only be dropped from all threads without cgo
+	os.Setenv("CGO_ENABLED", "0")
+	defer os.Unsetenv("CGO_ENABLED")
+
+	var err error
+	pathToDropCaps, err = gexec.Build("github.com/containernetworking/cni/pkg/skel/testdata/dropcaps")
+	Expect(err).NotTo(HaveOccurred())
+})
+
+var _ = AfterSuite(func() {
+	gexec.CleanupBuildArtifacts()
+})
+
 func TestSkel(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Skel Suite")
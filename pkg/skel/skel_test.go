@@ -16,10 +16,20 @@ package skel
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
+	"syscall"
 
+	"github.com/containernetworking/cni/pkg/events"
+	"github.com/containernetworking/cni/pkg/metrics"
+	"github.com/containernetworking/cni/pkg/schema"
 	"github.com/containernetworking/cni/pkg/types"
 	"github.com/containernetworking/cni/pkg/version"
 
@@ -93,7 +103,7 @@ var _ = Describe("dispatching to the correct callback", func() {
 		err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
 		if isRequired {
 			Expect(err).To(Equal(&types.Error{
-				Code: 100,
+				Code: types.ErrInvalidEnvironmentVariables,
 				Msg:  "required env variables missing",
 			}))
 			Expect(stderr.String()).To(ContainSubstring(envVar + " env variable missing\n"))
@@ -112,6 +122,18 @@ var _ = Describe("dispatching to the correct callback", func() {
 			Expect(cmdAdd.Received.CmdArgs).To(Equal(expectedCmdArgs))
 		})
 
+		It("lets cmdAdd drop capabilities only if enabled", func() {
+			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cmdAdd.Received.CmdArgs.dropCaps).To(BeFalse())
+
+			dispatch.DropCapabilities = true
+			dispatch.Stdin = strings.NewReader(`{ "some": "config" }`)
+			err = dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cmdAdd.Received.CmdArgs.dropCaps).To(BeTrue())
+		})
+
 		It("does not call cmdDel", func() {
 			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
 
@@ -146,6 +168,529 @@ var _ = Describe("dispatching to the correct callback", func() {
 		})
 	})
 
+	Context("when CNI_NETNS is optional", func() {
+		BeforeEach(func() {
+			dispatch.NetNSOptional = true
+			delete(environment, "CNI_NETNS")
+			expectedCmdArgs.Netns = ""
+		})
+
+		It("calls cmdAdd without it", func() {
+			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cmdAdd.CallCount).To(Equal(1))
+			Expect(cmdAdd.Received.CmdArgs).To(Equal(expectedCmdArgs))
+		})
+	})
+
+	Context("when logging is configured", func() {
+		BeforeEach(func() {
+			environment["CNI_LOG_LEVEL"] = "debug"
+		})
+
+		It("logs the command to stderr", func() {
+			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stderr.String()).To(ContainSubstring(`: ADD netns=/some/netns/path ifName=eth0 args=some;extra;args containerId="some-container-id"`))
+		})
+
+		It("rejects an invalid configuration", func() {
+			environment["CNI_LOG_LEVEL"] = "loud"
+
+			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+
+			Expect(err).To(Equal(&types.Error{Code: 100, Msg: `unknown log level "loud"`}))
+			Expect(cmdAdd.CallCount).To(Equal(0))
+		})
+	})
+
+	Context("when tracing", func() {
+		BeforeEach(func() {
+			environment["CNI_LOG_LEVEL"] = "debug"
+			dispatch.Setenv = func(key, value string) error {
+				environment[key] = value
+				return nil
+			}
+		})
+
+		It("logs the trace ID passed in", func() {
+			environment["CNI_TRACE_ID"] = "some-trace-id"
+
+			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stderr.String()).To(ContainSubstring(`containerId="some-container-id" traceId="some-trace-id"`))
+			Expect(environment["CNI_TRACE_ID"]).To(Equal("some-trace-id"))
+		})
+
+		It("generates a trace ID for the delegates if there is none", func() {
+			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(environment["CNI_TRACE_ID"]).To(MatchRegexp("^[0-9a-f]{16}$"))
+			Expect(stderr.String()).To(ContainSubstring(`traceId="` + environment["CNI_TRACE_ID"] + `"`))
+		})
+	})
+
+	Context("when metrics are recorded", func() {
+		var metricsDir string
+
+		BeforeEach(func() {
+			var err error
+			metricsDir, err = ioutil.TempDir("", "skel-metrics")
+			Expect(err).NotTo(HaveOccurred())
+			dispatch.MetricsDir = metricsDir
+			stdin = strings.NewReader(`{ "name": "mynet" }`)
+			dispatch.Stdin = stdin
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(metricsDir)).To(Succeed())
+		})
+
+		It("records the outcome of each command", func() {
+			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+			Expect(err).NotTo(HaveOccurred())
+
+			cmdAdd.Returns.Error = &types.Error{Code: 123}
+			dispatch.Stdin = strings.NewReader(`{ "name": "mynet" }`)
+			dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+
+			files, readErr := metrics.ReadDir(metricsDir)
+			Expect(readErr).NotTo(HaveOccurred())
+			Expect(files).To(HaveLen(1))
+			Expect(files[0].Plugin).To(Equal(filepath.Base(os.Args[0])))
+			Expect(files[0].Series).To(HaveLen(1))
+			Expect(files[0].Series[0].Network).To(Equal("mynet"))
+			Expect(files[0].Series[0].Command).To(Equal("ADD"))
+			Expect(files[0].Series[0].Count).To(BeEquivalentTo(2))
+			Expect(files[0].Series[0].Errors).To(Equal(map[string]uint64{"123": 1}))
+		})
+	})
+
+	Context("when hooks are configured", func() {
+		var hooksDir, logFile string
+
+		writeHook := func(name, script string) {
+			Expect(ioutil.WriteFile(filepath.Join(hooksDir, name), []byte("#!/bin/sh\n"+script), 0755)).To(Succeed())
+		}
+
+		hookLog := func() string {
+			log, err := ioutil.ReadFile(logFile)
+			Expect(err).NotTo(HaveOccurred())
+			return string(log)
+		}
+
+		BeforeEach(func() {
+			var err error
+			hooksDir, err = ioutil.TempDir("", "skel-hooks")
+			Expect(err).NotTo(HaveOccurred())
+			logFile = filepath.Join(hooksDir, "log")
+			writeHook("10-log", `echo "$CNI_HOOK $CNI_COMMAND $CNI_CONTAINERID" >> `+logFile+`
+cat >> `+logFile+`
+echo >> `+logFile+`
+`)
+			environment["CNI_HOOKS_DIR"] = hooksDir
+			dispatch.Setenv = func(key, value string) error {
+				environment[key] = value
+				return nil
+			}
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(hooksDir)).To(Succeed())
+		})
+
+		It("runs them around cmdAdd and passes its result to the post hooks", func() {
+			printResult := func(args *CmdArgs) error {
+				_, err := os.Stdout.WriteString(`{ "ip4": { "ip": "10.1.2.3/24" } }`)
+				return err
+			}
+
+			err := dispatch.pluginMain(printResult, cmdDel.Func)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stdout.String()).To(Equal(`{ "ip4": { "ip": "10.1.2.3/24" } }`))
+			Expect(hookLog()).To(Equal(`pre ADD some-container-id
+{ "some": "config" }
+post ADD some-container-id
+{"prevResult":{"ip4":{"ip":"10.1.2.3/24"},"dns":{}},"some":"config"}
+`))
+		})
+
+		It("runs them around cmdDel", func() {
+			environment["CNI_COMMAND"] = "DEL"
+
+			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cmdDel.CallCount).To(Equal(1))
+			Expect(hookLog()).To(Equal(`pre DEL some-container-id
+{ "some": "config" }
+post DEL some-container-id
+{ "some": "config" }
+`))
+		})
+
+		It("passes the CNI variables of the command only once", func() {
+			os.Setenv("CNI_COMMAND", "DEL")
+			defer os.Unsetenv("CNI_COMMAND")
+			writeHook("00-env", `env | grep "^CNI_COMMAND=" >> `+logFile+"\n")
+
+			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hookLog()).To(HavePrefix("CNI_COMMAND=ADD\npre ADD"))
+		})
+
+		It("does not call cmdAdd if a pre hook fails", func() {
+			writeHook("00-deny", "echo denied\nexit 1\n")
+
+			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Msg).To(Equal("pre hook 00-deny failed: exit status 1: denied"))
+			Expect(cmdAdd.CallCount).To(Equal(0))
+		})
+
+		It("does not run the post hooks if cmdAdd fails", func() {
+			cmdAdd.Returns.Error = errors.New("boom")
+
+			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+
+			Expect(err).To(HaveOccurred())
+			Expect(hookLog()).NotTo(ContainSubstring("post"))
+		})
+
+		It("drops the capabilities of a plugin only after the post hooks", func() {
+			writeHook("20-caps", `[ "$CNI_HOOK" = post ] || exit 0
+grep -E "^Cap(Eff|Bnd)" /proc/$PPID/status >> `+logFile+`
+grep "^CapEff" /proc/self/status >> `+logFile+`
+`)
+			plugin := exec.Command(pathToDropCaps)
+			plugin.Env = []string{
+				"CNI_COMMAND=ADD",
+				"CNI_CONTAINERID=some-container-id",
+				"CNI_NETNS=/some/netns/path",
+				"CNI_IFNAME=eth0",
+				"CNI_PATH=/some/cni/path",
+				"CNI_HOOKS_DIR=" + hooksDir,
+				"CNI_METRICS_DIR=" + filepath.Join(hooksDir, "metrics"),
+				"PATH=" + os.Getenv("PATH"),
+			}
+			plugin.Stdin = strings.NewReader(`{ "some": "config" }`)
+			stderr := &bytes.Buffer{}
+			plugin.Stderr = stderr
+			out, err := plugin.Output()
+			Expect(err).NotTo(HaveOccurred(), stderr.String())
+			Expect(string(out)).To(Equal(`{ "ip4": { "ip": "10.1.2.3/24" } }`))
+
+			caps := []string{}
+			for _, line := range strings.Split(hookLog(), "\n") {
+				if strings.HasPrefix(line, "Cap") {
+					caps = append(caps, line)
+				}
+			}
+			Expect(caps).To(HaveLen(3))
+			for _, line := range caps {
+				Expect(line).NotTo(HaveSuffix("0000000000000000"))
+			}
+			Expect(stderr.String()).To(Equal("CapEff:\t0000000000000000\n"))
+		})
+
+		It("keeps delegates from running them again", func() {
+			delete(environment, "CNI_HOOKS_DIR")
+			conf := `{ "hooks": { "dir": "` + hooksDir + `" } }`
+			dispatch.Stdin = strings.NewReader(conf)
+			delegatingCmd := func(*CmdArgs) error {
+				delegate := *dispatch
+				delegate.Stdin = strings.NewReader(conf)
+				if err := delegate.pluginMain(cmdAdd.Func, cmdDel.Func); err != nil {
+					return err
+				}
+				return nil
+			}
+
+			err := dispatch.pluginMain(delegatingCmd, cmdDel.Func)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cmdAdd.CallCount).To(Equal(1))
+			Expect(strings.Count(hookLog(), "pre ADD")).To(Equal(1))
+			Expect(strings.Count(hookLog(), "post ADD")).To(Equal(1))
+			Expect(environment["CNI_DELEGATED_BY"]).To(BeEmpty())
+		})
+	})
+
+	Context("when events are emitted", func() {
+		var (
+			eventsDir string
+			listener  net.Listener
+			received  chan events.Event
+		)
+
+		BeforeEach(func() {
+			var err error
+			eventsDir, err = ioutil.TempDir("", "skel-events")
+			Expect(err).NotTo(HaveOccurred())
+			sock := filepath.Join(eventsDir, "events.sock")
+			listener, err = net.Listen("unix", sock)
+			Expect(err).NotTo(HaveOccurred())
+
+			received = make(chan events.Event, 10)
+			go func() {
+				for {
+					conn, err := listener.Accept()
+					if err != nil {
+						return
+					}
+					e := events.Event{}
+					if json.NewDecoder(conn).Decode(&e) == nil {
+						received <- e
+					}
+					conn.Close()
+				}
+			}()
+
+			environment["CNI_EVENTS_SINK"] = sock
+			dispatch.Stdin = strings.NewReader(`{ "name": "mynet" }`)
+		})
+
+		AfterEach(func() {
+			listener.Close()
+			Expect(os.RemoveAll(eventsDir)).To(Succeed())
+		})
+
+		It("emits an attach event with the IPs of the result", func() {
+			printResult := func(args *CmdArgs) error {
+				_, err := os.Stdout.WriteString(`{ "ip4": { "ip": "10.1.2.3/24" } }`)
+				return err
+			}
+
+			err := dispatch.pluginMain(printResult, cmdDel.Func)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stdout.String()).To(Equal(`{ "ip4": { "ip": "10.1.2.3/24" } }`))
+
+			var e events.Event
+			Eventually(received).Should(Receive(&e))
+			Expect(e.Type).To(Equal(events.TypeAttach))
+			Expect(e.Network).To(Equal("mynet"))
+			Expect(e.ContainerID).To(Equal("some-container-id"))
+			Expect(e.IfName).To(Equal("eth0"))
+			Expect(e.IPs).To(Equal([]string{"10.1.2.3/24"}))
+			Expect(e.Error).To(BeEmpty())
+		})
+
+		It("emits a detach event with the error DEL failed with", func() {
+			environment["CNI_COMMAND"] = "DEL"
+			cmdDel.Returns.Error = &types.Error{Code: 123, Msg: "boom"}
+
+			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+			Expect(err).To(HaveOccurred())
+
+			var e events.Event
+			Eventually(received).Should(Receive(&e))
+			Expect(e.Type).To(Equal(events.TypeDetach))
+			Expect(e.Error).To(Equal("boom"))
+			Expect(e.Code).To(BeEquivalentTo(123))
+		})
+
+		It("emits no events for delegates", func() {
+			environment["CNI_DELEGATED_BY"] = "bridge"
+
+			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+			Expect(err).NotTo(HaveOccurred())
+			Consistently(received, "100ms").ShouldNot(Receive())
+		})
+	})
+
+	Context("when a lock dir is set", func() {
+		var lockDir string
+
+		newDispatcher := func() *dispatcher {
+			d := *dispatch
+			d.Stdin = strings.NewReader(`{ "some": "config" }`)
+			return &d
+		}
+
+		BeforeEach(func() {
+			var err error
+			lockDir, err = ioutil.TempDir("", "skel-lock")
+			Expect(err).NotTo(HaveOccurred())
+			environment["CNI_LOCK_DIR"] = filepath.Join(lockDir, "locks")
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(lockDir)).To(Succeed())
+		})
+
+		It("runs the operations of an interface one at a time", func() {
+			release := make(chan struct{})
+			started := make(chan string, 2)
+			blockingCmd := func(name string) func(*CmdArgs) error {
+				return func(*CmdArgs) error {
+					started <- name
+					<-release
+					return nil
+				}
+			}
+
+			done := make(chan *types.Error, 2)
+			go func() { done <- newDispatcher().pluginMain(blockingCmd("first"), cmdDel.Func) }()
+			Eventually(started).Should(Receive(Equal("first")))
+
+			go func() { done <- newDispatcher().pluginMain(blockingCmd("second"), cmdDel.Func) }()
+			Consistently(started, "200ms").ShouldNot(Receive())
+
+			release <- struct{}{}
+			Eventually(started).Should(Receive(Equal("second")))
+			release <- struct{}{}
+			Eventually(done).Should(Receive(BeNil()))
+			Eventually(done).Should(Receive(BeNil()))
+		})
+
+		It("does not make other interfaces wait", func() {
+			release := make(chan struct{})
+			done := make(chan *types.Error, 1)
+			go func() {
+				done <- newDispatcher().pluginMain(func(*CmdArgs) error {
+					<-release
+					return nil
+				}, cmdDel.Func)
+			}()
+			defer func() {
+				close(release)
+				Eventually(done).Should(Receive(BeNil()))
+			}()
+			Eventually(func() ([]os.FileInfo, error) {
+				return ioutil.ReadDir(filepath.Join(lockDir, "locks"))
+			}).Should(HaveLen(1))
+
+			other := newDispatcher()
+			other.Getenv = func(key string) string {
+				if key == "CNI_IFNAME" {
+					return "eth1"
+				}
+				return environment[key]
+			}
+			Expect(other.pluginMain(cmdAdd.Func, cmdDel.Func)).To(BeNil())
+			Expect(cmdAdd.CallCount).To(Equal(1))
+		})
+
+		It("lets the plugins it delegates to run with the lock held", func() {
+			dispatch.Setenv = func(key, value string) error {
+				environment[key] = value
+				return nil
+			}
+			delegatingCmd := func(*CmdArgs) error {
+				if err := newDispatcher().pluginMain(cmdAdd.Func, cmdDel.Func); err != nil {
+					return err
+				}
+				return nil
+			}
+
+			err := dispatch.pluginMain(delegatingCmd, cmdDel.Func)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cmdAdd.CallCount).To(Equal(1))
+			Expect(environment["CNI_LOCK_HELD"]).To(BeEmpty())
+		})
+
+		It("removes the lock file after a successful DEL only", func() {
+			lockFile := filepath.Join(lockDir, "locks", "some-container-id%2Feth0")
+			Expect(newDispatcher().pluginMain(cmdAdd.Func, cmdDel.Func)).To(BeNil())
+			Expect(lockFile).To(BeAnExistingFile())
+
+			environment["CNI_COMMAND"] = "DEL"
+			cmdDel.Returns.Error = &types.Error{Code: 123, Msg: "boom"}
+			Expect(newDispatcher().pluginMain(cmdAdd.Func, cmdDel.Func)).NotTo(BeNil())
+			Expect(lockFile).To(BeAnExistingFile())
+
+			cmdDel.Returns.Error = nil
+			Expect(newDispatcher().pluginMain(cmdAdd.Func, cmdDel.Func)).To(BeNil())
+			Expect(lockFile).NotTo(BeAnExistingFile())
+		})
+
+		It("locks the new lock file if the one waited for was removed", func() {
+			lockFile := filepath.Join(lockDir, "locks", "some-container-id%2Feth0")
+			release := make(chan struct{})
+			started := make(chan string, 2)
+			blockingCmd := func(name string) func(*CmdArgs) error {
+				return func(*CmdArgs) error {
+					started <- name
+					<-release
+					return nil
+				}
+			}
+
+			done := make(chan *types.Error, 2)
+			environment["CNI_COMMAND"] = "DEL"
+			first := newDispatcher()
+			go func() { done <- first.pluginMain(cmdAdd.Func, blockingCmd("DEL")) }()
+			Eventually(started).Should(Receive(Equal("DEL")))
+
+			second := newDispatcher()
+			second.Getenv = func(key string) string {
+				if key == "CNI_COMMAND" {
+					return "ADD"
+				}
+				return environment[key]
+			}
+			go func() { done <- second.pluginMain(blockingCmd("ADD"), cmdDel.Func) }()
+			Consistently(started, "200ms").ShouldNot(Receive())
+
+			release <- struct{}{}
+			Eventually(started).Should(Receive(Equal("ADD")))
+			Expect(lockFile).To(BeAnExistingFile())
+			release <- struct{}{}
+			Eventually(done).Should(Receive(BeNil()))
+			Eventually(done).Should(Receive(BeNil()))
+		})
+
+		It("reads the lock dir from the network configuration", func() {
+			delete(environment, "CNI_LOCK_DIR")
+			dispatch.Stdin = strings.NewReader(`{ "lockDir": "` + lockDir + `" }`)
+
+			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(filepath.Join(lockDir, "some-container-id%2Feth0")).To(BeAnExistingFile())
+		})
+	})
+
+	Context("when stdin carries a prevResult", func() {
+		It("passes a valid prevResult on to cmdAdd", func() {
+			dispatch.Stdin = strings.NewReader(`{ "prevResult": { "ip4": { "ip": "10.1.2.3/24" } } }`)
+
+			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cmdAdd.CallCount).To(Equal(1))
+		})
+
+		It("rejects a cniVersion it can't reply in without calling cmdAdd", func() {
+			dispatch.Stdin = strings.NewReader(`{ "cniVersion": "9.8.7" }`)
+
+			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Code).To(Equal(types.ErrIncompatibleCNIVersion))
+			Expect(cmdAdd.CallCount).To(Equal(0))
+		})
+
+		It("rejects a malformed prevResult without calling cmdAdd", func() {
+			dispatch.Stdin = strings.NewReader(`{ "prevResult": { "ip4": { "ip": "banana" } } }`)
+
+			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Code).To(Equal(types.ErrDecodingFailure))
+			Expect(err.Msg).To(Equal("failed to parse prevResult"))
+			Expect(cmdAdd.CallCount).To(Equal(0))
+		})
+	})
+
 	Context("when the CNI_COMMAND is DEL", func() {
 		BeforeEach(func() {
 			environment["CNI_COMMAND"] = "DEL"
@@ -206,6 +751,52 @@ var _ = Describe("dispatching to the correct callback", func() {
 		)
 	})
 
+	Context("when the CNI_COMMAND is SCHEMA", func() {
+		BeforeEach(func() {
+			environment["CNI_COMMAND"] = "SCHEMA"
+		})
+
+		It("prints the schema to stdout", func() {
+			dispatch.Schema = &schema.Schema{Type: "object"}
+			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stdout).To(MatchJSON(`{ "type": "object" }`))
+			Expect(cmdAdd.CallCount).To(Equal(0))
+			Expect(cmdDel.CallCount).To(Equal(0))
+		})
+
+		It("accepts the sections handled by skel", func() {
+			dispatch.Schema = &schema.Schema{Type: "object", Properties: map[string]*schema.Schema{
+				"name": {Type: "string"},
+			}}
+			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+			Expect(err).NotTo(HaveOccurred())
+
+			s := &schema.Schema{}
+			Expect(json.Unmarshal(stdout.Bytes(), s)).To(Succeed())
+			problems, verr := s.Validate([]byte(`{
+				"name": "mynet",
+				"log": { "level": "debug" },
+				"hooks": { "dir": "/etc/cni/hooks.d" },
+				"events": { "sink": "journald" },
+				"lockDir": "/run/cni/lock"
+			}`))
+			Expect(verr).NotTo(HaveOccurred())
+			Expect(problems).To(BeEmpty())
+			Expect(dispatch.Schema.Properties).To(HaveLen(1))
+		})
+
+		It("is unknown to plugins without a schema", func() {
+			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+
+			Expect(err).To(Equal(&types.Error{
+				Code: types.ErrInvalidEnvironmentVariables,
+				Msg:  "unknown CNI_COMMAND: SCHEMA",
+			}))
+		})
+	})
+
 	Context("when the CNI_COMMAND is unrecognized", func() {
 		BeforeEach(func() {
 			environment["CNI_COMMAND"] = "NOPE"
@@ -222,7 +813,7 @@ var _ = Describe("dispatching to the correct callback", func() {
 			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
 
 			Expect(err).To(Equal(&types.Error{
-				Code: 100,
+				Code: types.ErrInvalidEnvironmentVariables,
 				Msg:  "unknown CNI_COMMAND: NOPE",
 			}))
 		})
@@ -244,8 +835,9 @@ var _ = Describe("dispatching to the correct callback", func() {
 			err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
 
 			Expect(err).To(Equal(&types.Error{
-				Code: 100,
-				Msg:  "error reading from stdin: banana",
+				Code:    types.ErrIOFailure,
+				Msg:     "error reading from stdin",
+				Details: "banana",
 			}))
 		})
 	})
@@ -283,5 +875,20 @@ var _ = Describe("dispatching to the correct callback", func() {
 				}))
 			})
 		})
+
+		Context("when it is a transient errno", func() {
+			BeforeEach(func() {
+				cmdAdd.Returns.Error = syscall.EBUSY
+			})
+
+			It("asks to try again later", func() {
+				err := dispatch.pluginMain(cmdAdd.Func, cmdDel.Func)
+
+				Expect(err).To(Equal(&types.Error{
+					Code: types.ErrTryAgainLater,
+					Msg:  syscall.EBUSY.Error(),
+				}))
+			})
+		})
 	})
 })
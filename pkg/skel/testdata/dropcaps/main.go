@@ -0,0 +1,52 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// dropcaps is a plugin which drops all capabilities in its ADD, like the
+// reference plugins do, and prints its effective capabilities to stderr
+// once PluginMain returns
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/containernetworking/cni/pkg/skel"
+)
+
+func cmdAdd(args *skel.CmdArgs) error {
+	args.DropCapabilities()
+	_, err := os.Stdout.WriteString(`{ "ip4": { "ip": "10.1.2.3/24" } }`)
+	return err
+}
+
+func cmdDel(args *skel.CmdArgs) error {
+	return nil
+}
+
+func main() {
+	skel.PluginMain(cmdAdd, cmdDel)
+
+	status, err := ioutil.ReadFile("/proc/self/status")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for _, line := range strings.Split(string(status), "\n") {
+		if strings.HasPrefix(line, "CapEff:") {
+			fmt.Fprintln(os.Stderr, line)
+		}
+	}
+}
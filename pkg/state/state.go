@@ -0,0 +1,303 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package state collects the state CNI plugins keep on a node and
+// cross-references it to find leftovers of failed or missed DELs.
+package state
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/containernetworking/cni/plugins/ipam/host-local/backend/disk"
+)
+
+// DefaultDHCPSocket is where the dhcp daemon listens by default
+const DefaultDHCPSocket = "/run/cni/dhcp.sock"
+
+// Allocation is an address reserved by host-local
+type Allocation struct {
+	Network     string `json:"network"`
+	Pool        string `json:"pool,omitempty"`
+	IP          string `json:"ip"`
+	ContainerID string `json:"containerId"`
+}
+
+// Lease is a lease maintained by the dhcp daemon
+type Lease struct {
+	Network     string `json:"network"`
+	ContainerID string `json:"containerId"`
+	Netns       string `json:"netns"`
+	IfName      string `json:"ifName"`
+	IP          string `json:"ip"`
+	// Acquired is zero if the daemon predates it
+	Acquired time.Time `json:"acquired"`
+}
+
+// Problem is an inconsistency found in the state
+type Problem struct {
+	Network     string `json:"network"`
+	Pool        string `json:"pool,omitempty"`
+	ContainerID string `json:"containerId"`
+	IP          string `json:"ip,omitempty"`
+	Message     string `json:"message"`
+}
+
+func (p Problem) String() string {
+	where := p.Network
+	if p.Pool != "" {
+		where += "/" + p.Pool
+	}
+	if p.IP != "" {
+		return fmt.Sprintf("%s: %s (%s): %s", where, p.ContainerID, p.IP, p.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", where, p.ContainerID, p.Message)
+}
+
+// State is the state of all components found on the node
+type State struct {
+	Allocations []Allocation `json:"allocations"`
+	// Leases is nil if the dhcp daemon isn't running
+	Leases   []Lease   `json:"leases"`
+	Problems []Problem `json:"problems"`
+}
+
+// Options select where the state is read from
+type Options struct {
+	// Data dir of host-local, defaults to /var/lib/cni/networks
+	DataDir string
+	// Socket of the dhcp daemon, defaults to DefaultDHCPSocket
+	DHCPSocket string
+	// IDs of the containers known to the runtime. If nil, state is
+	// not checked for containers that are gone.
+	Containers []string
+}
+
+// Inspect reads the state of all components and checks it for
+// inconsistencies
+func Inspect(opts Options) (*State, error) {
+	s := &State{
+		Allocations: []Allocation{},
+		Problems:    []Problem{},
+	}
+	if err := s.inspectHostLocal(opts.DataDir); err != nil {
+		return nil, fmt.Errorf("failed to read host-local state: %v", err)
+	}
+	if err := s.inspectDHCP(opts.DHCPSocket); err != nil {
+		return nil, fmt.Errorf("failed to query dhcp daemon: %v", err)
+	}
+	if opts.Containers != nil {
+		s.checkContainers(opts.Containers)
+	}
+	sort.Sort(byLocation(s.Problems))
+	return s, nil
+}
+
+func (s *State) inspectHostLocal(dataDir string) error {
+	networks, err := disk.Networks(dataDir)
+	if err != nil {
+		return err
+	}
+	for _, network := range networks {
+		pools, err := disk.Pools(dataDir, network)
+		if err != nil {
+			return err
+		}
+		for _, pool := range append([]string{""}, pools...) {
+			if err := s.inspectStore(dataDir, network, pool); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *State) inspectStore(dataDir, network, pool string) error {
+	store, err := disk.New(dataDir, network, pool)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	if err := store.Lock(); err != nil {
+		return err
+	}
+	defer store.Unlock()
+
+	reservations, err := store.Reservations()
+	if err != nil {
+		return err
+	}
+	index, err := store.Index()
+	if err != nil {
+		return err
+	}
+
+	problem := func(id, ip, format string, args ...interface{}) {
+		s.Problems = append(s.Problems, Problem{
+			Network:     network,
+			Pool:        pool,
+			ContainerID: id,
+			IP:          ip,
+			Message:     fmt.Sprintf(format, args...),
+		})
+	}
+
+	for ip, id := range reservations {
+		s.Allocations = append(s.Allocations, Allocation{
+			Network:     network,
+			Pool:        pool,
+			IP:          ip,
+			ContainerID: id,
+		})
+		// allocations made before the index existed have no entry at all
+		if ips, ok := index[id]; ok && !contains(ips, ip) {
+			problem(id, ip, "allocated but missing from the by-id index")
+		}
+	}
+	for id, ips := range index {
+		for _, ip := range ips {
+			if owner, ok := reservations[ip]; !ok {
+				problem(id, ip, "in the by-id index but not allocated")
+			} else if owner != id {
+				problem(id, ip, "in the by-id index but allocated to %s", owner)
+			}
+		}
+	}
+	sort.Sort(byAddress(s.Allocations))
+	return nil
+}
+
+func (s *State) inspectDHCP(socketPath string) error {
+	if socketPath == "" {
+		socketPath = DefaultDHCPSocket
+	}
+	if _, err := os.Stat(socketPath); os.IsNotExist(err) {
+		return nil
+	}
+
+	client, err := rpc.DialHTTP("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	leases := []Lease{}
+	if err := client.Call("DHCP.Leases", struct{}{}, &leases); err != nil {
+		return err
+	}
+	sort.Sort(byContainer(leases))
+	s.Leases = leases
+
+	for _, l := range leases {
+		if _, err := os.Stat(l.Netns); err != nil {
+			s.Problems = append(s.Problems, Problem{
+				Network:     l.Network,
+				ContainerID: l.ContainerID,
+				IP:          l.IP,
+				Message:     fmt.Sprintf("leased but netns %s is gone", l.Netns),
+			})
+		}
+	}
+	return nil
+}
+
+func (s *State) checkContainers(containers []string) {
+	for _, a := range s.Allocations {
+		if !contains(containers, a.ContainerID) {
+			s.Problems = append(s.Problems, Problem{
+				Network:     a.Network,
+				Pool:        a.Pool,
+				ContainerID: a.ContainerID,
+				IP:          a.IP,
+				Message:     "allocated to a container unknown to the runtime",
+			})
+		}
+	}
+	for _, l := range s.Leases {
+		if !contains(containers, l.ContainerID) {
+			s.Problems = append(s.Problems, Problem{
+				Network:     l.Network,
+				ContainerID: l.ContainerID,
+				IP:          l.IP,
+				Message:     "leased to a container unknown to the runtime",
+			})
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+type byAddress []Allocation
+
+func (a byAddress) Len() int      { return len(a) }
+func (a byAddress) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byAddress) Less(i, j int) bool {
+	if a[i].Network != a[j].Network {
+		return a[i].Network < a[j].Network
+	}
+	if a[i].Pool != a[j].Pool {
+		return a[i].Pool < a[j].Pool
+	}
+	return ipLess(a[i].IP, a[j].IP)
+}
+
+type byContainer []Lease
+
+func (l byContainer) Len() int      { return len(l) }
+func (l byContainer) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l byContainer) Less(i, j int) bool {
+	if l[i].ContainerID != l[j].ContainerID {
+		return l[i].ContainerID < l[j].ContainerID
+	}
+	return l[i].Network < l[j].Network
+}
+
+type byLocation []Problem
+
+func (p byLocation) Len() int      { return len(p) }
+func (p byLocation) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byLocation) Less(i, j int) bool {
+	if p[i].Network != p[j].Network {
+		return p[i].Network < p[j].Network
+	}
+	if p[i].Pool != p[j].Pool {
+		return p[i].Pool < p[j].Pool
+	}
+	if p[i].ContainerID != p[j].ContainerID {
+		return p[i].ContainerID < p[j].ContainerID
+	}
+	if p[i].IP != p[j].IP {
+		return ipLess(p[i].IP, p[j].IP)
+	}
+	return p[i].Message < p[j].Message
+}
+
+func ipLess(a, b string) bool {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	if ipA == nil || ipB == nil {
+		return a < b
+	}
+	return string(ipA.To16()) < string(ipB.To16())
+}
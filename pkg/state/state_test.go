@@ -0,0 +1,173 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state_test
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/rpc"
+	"os"
+	"path/filepath"
+
+	"github.com/containernetworking/cni/pkg/state"
+	"github.com/containernetworking/cni/plugins/ipam/host-local/backend/disk"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakeDHCP struct {
+	leases []state.Lease
+}
+
+func (d *fakeDHCP) Leases(args struct{}, reply *[]state.Lease) error {
+	*reply = d.leases
+	return nil
+}
+
+var _ = Describe("Inspect", func() {
+	var (
+		tmpDir string
+		opts   state.Options
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "cni-state")
+		Expect(err).NotTo(HaveOccurred())
+		opts = state.Options{
+			DataDir:    filepath.Join(tmpDir, "networks"),
+			DHCPSocket: filepath.Join(tmpDir, "dhcp.sock"),
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	reserve := func(network, pool, id, ip string) {
+		store, err := disk.New(opts.DataDir, network, pool)
+		Expect(err).NotTo(HaveOccurred())
+		defer store.Close()
+		reserved, err := store.Reserve(id, net.ParseIP(ip))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reserved).To(BeTrue())
+	}
+
+	It("lists the host-local allocations of all networks and pools", func() {
+		reserve("net2", "", "c2", "10.0.0.2")
+		reserve("net1", "", "c1", "10.0.0.10")
+		reserve("net1", "", "c1", "10.0.0.9")
+		reserve("net1", "tenant", "c3", "10.0.0.2")
+
+		s, err := state.Inspect(opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.Allocations).To(Equal([]state.Allocation{
+			{Network: "net1", IP: "10.0.0.9", ContainerID: "c1"},
+			{Network: "net1", IP: "10.0.0.10", ContainerID: "c1"},
+			{Network: "net1", Pool: "tenant", IP: "10.0.0.2", ContainerID: "c3"},
+			{Network: "net2", IP: "10.0.0.2", ContainerID: "c2"},
+		}))
+		Expect(s.Leases).To(BeNil())
+		Expect(s.Problems).To(BeEmpty())
+	})
+
+	It("flags allocations and index entries that disagree", func() {
+		reserve("net1", "", "c1", "10.0.0.2")
+		reserve("net1", "", "c1", "10.0.0.3")
+		reserve("net1", "", "c2", "10.0.0.4")
+		dir := filepath.Join(opts.DataDir, "net1")
+		// a crash between writing the IP file and the index
+		Expect(ioutil.WriteFile(filepath.Join(dir, "by-id", "c1"), []byte("10.0.0.2\n10.0.0.5\n"), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "10.0.0.4"), []byte("c3"), 0644)).To(Succeed())
+
+		s, err := state.Inspect(opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.Problems).To(Equal([]state.Problem{
+			{Network: "net1", ContainerID: "c1", IP: "10.0.0.3", Message: "allocated but missing from the by-id index"},
+			{Network: "net1", ContainerID: "c1", IP: "10.0.0.5", Message: "in the by-id index but not allocated"},
+			{Network: "net1", ContainerID: "c2", IP: "10.0.0.4", Message: "in the by-id index but allocated to c3"},
+		}))
+	})
+
+	It("does not flag allocations made before the index existed", func() {
+		Expect(os.MkdirAll(filepath.Join(opts.DataDir, "net1"), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(opts.DataDir, "net1", "10.0.0.2"), []byte("old"), 0644)).To(Succeed())
+
+		s, err := state.Inspect(opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.Allocations).To(HaveLen(1))
+		Expect(s.Problems).To(BeEmpty())
+	})
+
+	Context("when the containers of the runtime are known", func() {
+		It("flags allocations of other containers", func() {
+			reserve("net1", "", "c1", "10.0.0.2")
+			reserve("net1", "", "gone", "10.0.0.3")
+			opts.Containers = []string{"c1"}
+
+			s, err := state.Inspect(opts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s.Problems).To(Equal([]state.Problem{
+				{Network: "net1", ContainerID: "gone", IP: "10.0.0.3", Message: "allocated to a container unknown to the runtime"},
+			}))
+		})
+	})
+
+	Context("when the dhcp daemon is running", func() {
+		var (
+			listener net.Listener
+			dhcp     *fakeDHCP
+		)
+
+		BeforeEach(func() {
+			var err error
+			listener, err = net.Listen("unix", opts.DHCPSocket)
+			Expect(err).NotTo(HaveOccurred())
+
+			dhcp = &fakeDHCP{leases: []state.Lease{
+				{Network: "net1", ContainerID: "c2", Netns: tmpDir, IfName: "eth0", IP: "192.168.1.3"},
+				{Network: "net1", ContainerID: "c1", Netns: filepath.Join(tmpDir, "gone"), IfName: "eth0", IP: "192.168.1.2"},
+			}}
+			server := rpc.NewServer()
+			Expect(server.RegisterName("DHCP", dhcp)).To(Succeed())
+			go http.Serve(listener, server)
+		})
+
+		AfterEach(func() {
+			listener.Close()
+		})
+
+		It("lists the leases and flags those whose netns is gone", func() {
+			s, err := state.Inspect(opts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s.Leases).To(Equal([]state.Lease{dhcp.leases[1], dhcp.leases[0]}))
+			Expect(s.Problems).To(Equal([]state.Problem{
+				{Network: "net1", ContainerID: "c1", IP: "192.168.1.2", Message: "leased but netns " + filepath.Join(tmpDir, "gone") + " is gone"},
+			}))
+		})
+
+		It("flags leases of containers unknown to the runtime", func() {
+			opts.Containers = []string{"c1"}
+
+			s, err := state.Inspect(opts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s.Problems).To(ContainElement(state.Problem{
+				Network: "net1", ContainerID: "c2", IP: "192.168.1.3", Message: "leased to a container unknown to the runtime",
+			}))
+		})
+	})
+})
@@ -0,0 +1,126 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Memory keeps the keys in memory, e.g. for tests or for daemons whose
+// state doesn't need to survive a restart. Its lock is only shared
+// within the process.
+type Memory struct {
+	lock sync.Mutex
+
+	mux    sync.Mutex
+	values map[string][]byte
+}
+
+func NewMemory() *Memory {
+	return &Memory{values: make(map[string][]byte)}
+}
+
+func (s *Memory) Lock() error {
+	s.lock.Lock()
+	return nil
+}
+
+func (s *Memory) Unlock() error {
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *Memory) Close() error {
+	return nil
+}
+
+func (s *Memory) Get(key string) ([]byte, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	value, ok := s.values[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, value...), nil
+}
+
+func (s *Memory) Put(key string, value []byte) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.values[key] = append([]byte{}, value...)
+	return nil
+}
+
+func (s *Memory) Create(key string, value []byte) (bool, error) {
+	if err := checkKey(key); err != nil {
+		return false, err
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.values[key]; ok {
+		return false, nil
+	}
+	s.values[key] = append([]byte{}, value...)
+	return true, nil
+}
+
+func (s *Memory) Delete(key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.values[key]; !ok {
+		return ErrNotFound
+	}
+	delete(s.values, key)
+	return nil
+}
+
+func (s *Memory) List(prefix string) ([]string, error) {
+	if err := checkPrefix(prefix); err != nil {
+		return nil, err
+	}
+	if prefix != "" {
+		prefix += "/"
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	names := []string{}
+	for key := range s.values {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		name := key[len(prefix):]
+		if !strings.Contains(name, "/") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package store provides the storage plugins keep their state in, so
+// that the same plugin can keep it on disk on one deployment and
+// elsewhere on another.
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned for keys that don't exist
+var ErrNotFound = errors.New("key not found")
+
+// ErrLockingUnsupported is returned by LockFile and UnlockFile on
+// platforms without flock(2)
+var ErrLockingUnsupported = errors.New("locking files is not supported on this platform")
+
+// Store is a hierarchical key-value store. Keys are paths of
+// slash-separated names, e.g. "mynet/10.0.0.2".
+type Store interface {
+	// Lock acquires an exclusive lock on the whole store, shared
+	// with every other user of the same store, not only within this
+	// process
+	Lock() error
+	Unlock() error
+	Close() error
+
+	// Get returns the value of key, or ErrNotFound
+	Get(key string) ([]byte, error)
+	// Put sets the value of key. Readers see either the previous or the
+	// new value, never part of it.
+	Put(key string, value []byte) error
+	// Create sets the value of key unless it already exists, and
+	// returns whether it did
+	Create(key string, value []byte) (bool, error)
+	// Delete removes key, or returns ErrNotFound
+	Delete(key string) error
+	// List returns the sorted names of the keys directly below prefix.
+	// Keys further down, such as "b" in "prefix/a/b", aren't listed.
+	// An empty prefix lists the top level.
+	List(prefix string) ([]string, error)
+}
+
+// ModTimer is implemented by stores which know when a key was last set
+type ModTimer interface {
+	// ModTime returns the time key was last set at, or ErrNotFound
+	ModTime(key string) (time.Time, error)
+}
+
+// checkKey makes sure key is a path of names that can't escape the
+// store
+func checkKey(key string) error {
+	for _, name := range strings.Split(key, "/") {
+		if name == "" || name == "." || name == ".." || strings.ContainsRune(name, '\\') {
+			return fmt.Errorf("invalid key %q", key)
+		}
+	}
+	return nil
+}
+
+func checkPrefix(prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+	return checkKey(prefix)
+}
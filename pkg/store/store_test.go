@@ -0,0 +1,161 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/containernetworking/cni/pkg/store"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func describeStore(name string, newStore func() store.Store, cleanup func()) {
+	Describe(name, func() {
+		var s store.Store
+
+		BeforeEach(func() {
+			s = newStore()
+			Expect(s.Lock()).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(s.Unlock()).To(Succeed())
+			Expect(s.Close()).To(Succeed())
+			cleanup()
+		})
+
+		It("gets what was put", func() {
+			Expect(s.Put("a/b", []byte("one"))).To(Succeed())
+			Expect(s.Put("a/b", []byte("two"))).To(Succeed())
+
+			value, err := s.Get("a/b")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(value)).To(Equal("two"))
+		})
+
+		It("returns ErrNotFound for missing keys", func() {
+			_, err := s.Get("missing")
+			Expect(err).To(Equal(store.ErrNotFound))
+			Expect(s.Delete("missing")).To(Equal(store.ErrNotFound))
+		})
+
+		It("creates keys only once", func() {
+			created, err := s.Create("a", []byte("one"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(created).To(BeTrue())
+
+			created, err = s.Create("a", []byte("two"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(created).To(BeFalse())
+
+			value, err := s.Get("a")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(value)).To(Equal("one"))
+		})
+
+		It("deletes keys", func() {
+			Expect(s.Put("a", []byte("one"))).To(Succeed())
+			Expect(s.Delete("a")).To(Succeed())
+
+			_, err := s.Get("a")
+			Expect(err).To(Equal(store.ErrNotFound))
+		})
+
+		It("lists the keys directly below a prefix", func() {
+			for _, key := range []string{"b", "a", "x/c", "x/.d", "x/y/e"} {
+				Expect(s.Put(key, []byte("v"))).To(Succeed())
+			}
+
+			Expect(s.List("")).To(Equal([]string{"a", "b"}))
+			Expect(s.List("x")).To(Equal([]string{".d", "c"}))
+			Expect(s.List("x/y")).To(Equal([]string{"e"}))
+			Expect(s.List("missing")).To(BeEmpty())
+		})
+
+		It("rejects keys that would escape the store", func() {
+			for _, key := range []string{"", "..", "a/../b", "/a", "a/", `a\b`} {
+				_, err := s.Get(key)
+				Expect(err).To(MatchError(ContainSubstring("invalid key")), key)
+				Expect(s.Put(key, nil)).To(MatchError(ContainSubstring("invalid key")), key)
+			}
+			_, err := s.List("..")
+			Expect(err).To(MatchError(`invalid key ".."`))
+		})
+	})
+}
+
+var _ = Describe("stores", func() {
+	var dir string
+
+	describeStore("Filesystem", func() store.Store {
+		var err error
+		dir, err = ioutil.TempDir("", "cni-store")
+		Expect(err).NotTo(HaveOccurred())
+		s, err := store.NewFilesystem(filepath.Join(dir, "data"))
+		Expect(err).NotTo(HaveOccurred())
+		return s
+	}, func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	describeStore("Memory", func() store.Store {
+		return store.NewMemory()
+	}, func() {})
+
+	It("keeps the keys of a Filesystem in files", func() {
+		var err error
+		dir, err = ioutil.TempDir("", "cni-store")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		s, err := store.NewFilesystem(dir)
+		Expect(err).NotTo(HaveOccurred())
+		defer s.Close()
+
+		Expect(s.Put("a/b", []byte("v"))).To(Succeed())
+		data, err := ioutil.ReadFile(filepath.Join(dir, "a", "b"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal("v"))
+
+		files, err := ioutil.ReadDir(filepath.Join(dir, "a"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+	})
+
+	It("knows when the keys of a Filesystem were set", func() {
+		var err error
+		dir, err = ioutil.TempDir("", "cni-store")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		s, err := store.NewFilesystem(dir)
+		Expect(err).NotTo(HaveOccurred())
+		defer s.Close()
+
+		before := time.Now().Add(-time.Second)
+		Expect(s.Put("a/b", []byte("v"))).To(Succeed())
+		t, err := s.ModTime("a/b")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(t).To(BeTemporally(">", before))
+
+		_, err = s.ModTime("a/c")
+		Expect(err).To(Equal(store.ErrNotFound))
+	})
+})
@@ -29,6 +29,8 @@ func envCleanup() {
 	os.Unsetenv("CNI_IFNAME")
 }
 
+// CmdAddWithResult runs f, which calls a plugin's cmdAdd, with the
+// environment of an ADD and returns the result it prints
 func CmdAddWithResult(cniNetns, cniIfname string, f func() error) (*types.Result, error) {
 	os.Setenv("CNI_COMMAND", "ADD")
 	os.Setenv("CNI_PATH", os.Getenv("PATH"))
@@ -44,21 +46,33 @@ func CmdAddWithResult(cniNetns, cniIfname string, f func() error) (*types.Result
 	}
 
 	os.Stdout = w
+	defer func() { os.Stdout = oldStdout }()
+
+	// read concurrently, so that f can't block on a full pipe
+	type output struct {
+		data []byte
+		err  error
+	}
+	done := make(chan output)
+	go func() {
+		data, err := ioutil.ReadAll(r)
+		r.Close()
+		done <- output{data, err}
+	}()
+
 	err = f()
 	w.Close()
+	out := <-done
 	if err != nil {
 		return nil, err
 	}
-
-	// parse the result
-	out, err := ioutil.ReadAll(r)
-	os.Stdout = oldStdout
-	if err != nil {
-		return nil, err
+	if out.err != nil {
+		return nil, out.err
 	}
 
+	// parse the result
 	result := types.Result{}
-	err = json.Unmarshal(out, &result)
+	err = json.Unmarshal(out.data, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -66,6 +80,8 @@ func CmdAddWithResult(cniNetns, cniIfname string, f func() error) (*types.Result
 	return &result, nil
 }
 
+// CmdDelWithResult runs f, which calls a plugin's cmdDel, with the
+// environment of a DEL
 func CmdDelWithResult(cniNetns, cniIfname string, f func() error) error {
 	os.Setenv("CNI_COMMAND", "DEL")
 	os.Setenv("CNI_PATH", os.Getenv("PATH"))
@@ -0,0 +1,22 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testutils provides helpers for the tests of CNI plugins: running
+// cmdAdd and cmdDel in-process, inspecting the links of a network
+// namespace, and gomega matchers for results.
+//
+// Besides the plugins of this repository, plugins maintained elsewhere
+// are meant to use it too, so changes to its API stay backwards
+// compatible.
+package testutils
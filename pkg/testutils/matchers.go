@@ -0,0 +1,86 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutils
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/onsi/gomega"
+	gomegatypes "github.com/onsi/gomega/types"
+)
+
+// HaveIP4 succeeds if a *types.Result has the IPv4 address cidr, e.g.
+// "10.1.2.3/24"
+func HaveIP4(cidr string) gomegatypes.GomegaMatcher {
+	return gomega.WithTransform(func(r *types.Result) string {
+		if r == nil || r.IP4 == nil {
+			return ""
+		}
+		return r.IP4.IP.String()
+	}, gomega.Equal(cidr))
+}
+
+// HaveIP6 succeeds if a *types.Result has the IPv6 address cidr
+func HaveIP6(cidr string) gomegatypes.GomegaMatcher {
+	return gomega.WithTransform(func(r *types.Result) string {
+		if r == nil || r.IP6 == nil {
+			return ""
+		}
+		return r.IP6.IP.String()
+	}, gomega.Equal(cidr))
+}
+
+// HaveGateway4 succeeds if a *types.Result has the IPv4 gateway ip
+func HaveGateway4(ip string) gomegatypes.GomegaMatcher {
+	return gomega.WithTransform(func(r *types.Result) string {
+		if r == nil || r.IP4 == nil || r.IP4.Gateway == nil {
+			return ""
+		}
+		return r.IP4.Gateway.String()
+	}, gomega.Equal(ip))
+}
+
+// HaveRoute4 succeeds if a *types.Result has an IPv4 route to dst, e.g.
+// "0.0.0.0/0", via gw. An empty gw matches routes without a gateway.
+func HaveRoute4(dst, gw string) gomegatypes.GomegaMatcher {
+	return gomega.WithTransform(func(r *types.Result) []string {
+		routes := []string{}
+		if r != nil && r.IP4 != nil {
+			routes = routeStrings(r.IP4.Routes)
+		}
+		return routes
+	}, gomega.ContainElement(routeString(dst, gw)))
+}
+
+func routeStrings(routes []types.Route) []string {
+	s := []string{}
+	for _, r := range routes {
+		gw := ""
+		if r.GW != nil {
+			gw = r.GW.String()
+		}
+		s = append(s, routeString(r.Dst.String(), gw))
+	}
+	return s
+}
+
+func routeString(dst, gw string) string {
+	if gw == "" {
+		return dst
+	}
+	return fmt.Sprintf("%s via %s", dst, net.ParseIP(gw))
+}
@@ -0,0 +1,82 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutils
+
+import (
+	"fmt"
+
+	"github.com/containernetworking/cni/pkg/ns"
+	"github.com/vishvananda/netlink"
+)
+
+// WithTempNetNS runs f in a new network namespace, that is removed
+// again when f returns
+func WithTempNetNS(f func(ns.NetNS) error) error {
+	netns, err := ns.NewNS()
+	if err != nil {
+		return err
+	}
+	defer netns.Close()
+
+	return f(netns)
+}
+
+// LinkInNetNS returns the link called name in netns
+func LinkInNetNS(netns ns.NetNS, name string) (netlink.Link, error) {
+	var link netlink.Link
+	err := netns.Do(func(ns.NetNS) error {
+		var err error
+		link, err = netlink.LinkByName(name)
+		if err != nil {
+			return fmt.Errorf("failed to look up %q in %s: %v", name, netns.Path(), err)
+		}
+		return nil
+	})
+	return link, err
+}
+
+// HasLinkInNetNS reports whether netns has a link called name
+func HasLinkInNetNS(netns ns.NetNS, name string) (bool, error) {
+	found := false
+	err := netns.Do(func(ns.NetNS) error {
+		links, err := netlink.LinkList()
+		if err != nil {
+			return err
+		}
+		for _, link := range links {
+			if link.Attrs().Name == name {
+				found = true
+			}
+		}
+		return nil
+	})
+	return found, err
+}
+
+// LinkAddrsInNetNS returns the addresses of the link called name in
+// netns, restricted to family, e.g. netlink.FAMILY_V4, unless it is
+// netlink.FAMILY_ALL
+func LinkAddrsInNetNS(netns ns.NetNS, name string, family int) ([]netlink.Addr, error) {
+	var addrs []netlink.Addr
+	err := netns.Do(func(ns.NetNS) error {
+		link, err := netlink.LinkByName(name)
+		if err != nil {
+			return fmt.Errorf("failed to look up %q in %s: %v", name, netns.Path(), err)
+		}
+		addrs, err = netlink.AddrList(link, family)
+		return err
+	})
+	return addrs, err
+}
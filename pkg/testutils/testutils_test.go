@@ -0,0 +1,130 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutils_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/containernetworking/cni/pkg/ns"
+	"github.com/containernetworking/cni/pkg/testutils"
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/vishvananda/netlink"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CmdAddWithResult", func() {
+	It("returns the result printed by f", func() {
+		result, err := testutils.CmdAddWithResult("/some/netns", "eth0", func() error {
+			Expect(os.Getenv("CNI_COMMAND")).To(Equal("ADD"))
+			_, err := fmt.Println(`{"ip4": {"ip": "10.1.2.3/24"}}`)
+			return err
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(testutils.HaveIP4("10.1.2.3/24"))
+		Expect(os.Getenv("CNI_COMMAND")).To(BeEmpty())
+	})
+
+	It("restores stdout when f fails", func() {
+		stdout := os.Stdout
+		_, err := testutils.CmdAddWithResult("/some/netns", "eth0", func() error {
+			return errors.New("banana")
+		})
+		Expect(err).To(MatchError("banana"))
+		Expect(os.Stdout == stdout).To(BeTrue())
+	})
+})
+
+var _ = Describe("network namespace helpers", func() {
+	It("inspects the links of a namespace", func() {
+		err := testutils.WithTempNetNS(func(netns ns.NetNS) error {
+			defer GinkgoRecover()
+
+			err := netns.Do(func(ns.NetNS) error {
+				link, err := netlink.LinkByName("lo")
+				if err != nil {
+					return err
+				}
+				addr, err := netlink.ParseAddr("10.1.2.3/24")
+				if err != nil {
+					return err
+				}
+				return netlink.AddrAdd(link, addr)
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			link, err := testutils.LinkInNetNS(netns, "lo")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(link.Attrs().Name).To(Equal("lo"))
+
+			Expect(testutils.HasLinkInNetNS(netns, "lo")).To(BeTrue())
+			Expect(testutils.HasLinkInNetNS(netns, "missing0")).To(BeFalse())
+			_, err = testutils.LinkInNetNS(netns, "missing0")
+			Expect(err).To(HaveOccurred())
+
+			addrs, err := testutils.LinkAddrsInNetNS(netns, "lo", netlink.FAMILY_V4)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(addrs).To(HaveLen(1))
+			Expect(addrs[0].IPNet.String()).To(Equal("10.1.2.3/24"))
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
+
+var _ = Describe("result matchers", func() {
+	var result *types.Result
+
+	BeforeEach(func() {
+		ip, ipn, err := net.ParseCIDR("10.1.2.3/24")
+		Expect(err).NotTo(HaveOccurred())
+		ipn.IP = ip
+		_, dst, err := net.ParseCIDR("192.168.0.0/16")
+		Expect(err).NotTo(HaveOccurred())
+		_, defaultDst, err := net.ParseCIDR("0.0.0.0/0")
+		Expect(err).NotTo(HaveOccurred())
+
+		result = &types.Result{
+			IP4: &types.IPConfig{
+				IP:      *ipn,
+				Gateway: net.ParseIP("10.1.2.1"),
+				Routes: []types.Route{
+					{Dst: *defaultDst},
+					{Dst: *dst, GW: net.ParseIP("10.1.2.254")},
+				},
+			},
+		}
+	})
+
+	It("match the IPv4 configuration", func() {
+		Expect(result).To(testutils.HaveIP4("10.1.2.3/24"))
+		Expect(result).NotTo(testutils.HaveIP4("10.1.2.4/24"))
+		Expect(result).NotTo(testutils.HaveIP6("fd00::3/64"))
+		Expect(result).To(testutils.HaveGateway4("10.1.2.1"))
+		Expect(result).To(testutils.HaveRoute4("0.0.0.0/0", ""))
+		Expect(result).To(testutils.HaveRoute4("192.168.0.0/16", "10.1.2.254"))
+		Expect(result).NotTo(testutils.HaveRoute4("192.168.0.0/16", ""))
+	})
+
+	It("don't match a result without IPv4 configuration", func() {
+		Expect(&types.Result{}).NotTo(testutils.HaveIP4("10.1.2.3/24"))
+		Expect(&types.Result{}).NotTo(testutils.HaveGateway4("10.1.2.1"))
+		Expect(&types.Result{}).NotTo(testutils.HaveRoute4("0.0.0.0/0", ""))
+	})
+})
@@ -0,0 +1,236 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tlsconfig builds the TLS configuration of the clients and
+// servers in this repository from one description, so that regulated
+// environments can enforce the same settings everywhere.
+package tlsconfig
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Config describes a TLS configuration. It is typically read from a JSON
+// file with Load.
+type Config struct {
+	// CertFile and KeyFile hold the PEM encoded certificate and key this
+	// side presents. Servers require them.
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	// CAFile holds the PEM encoded certificates peers are verified
+	// against. Clients use the system pool without it. Servers require
+	// client certificates signed by these CAs if it is set, or any
+	// client certificate with PinnedKeys.
+	CAFile string `json:"caFile,omitempty"`
+	// ServerName is the name clients expect in the server certificate,
+	// if it differs from the host they connect to
+	ServerName string `json:"serverName,omitempty"`
+	// MinVersion is the lowest TLS version accepted, "1.2" or "1.3".
+	// Defaults to "1.2".
+	MinVersion string `json:"minVersion,omitempty"`
+	// CipherSuites restricts the TLS 1.2 cipher suites, by their IANA
+	// names, e.g. "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"
+	CipherSuites []string `json:"cipherSuites,omitempty"`
+	// FIPS restricts the connection to FIPS 140-2 approved algorithms
+	FIPS bool `json:"fips,omitempty"`
+	// PinnedKeys are the hex encoded SHA-256 hashes of the public keys
+	// (SubjectPublicKeyInfo) the peer certificate may have. If set,
+	// certificates with other keys are rejected even if they verify.
+	PinnedKeys []string `json:"pinnedKeys,omitempty"`
+}
+
+// fipsCipherSuites are the TLS 1.2 cipher suites approved by FIPS 140-2
+// and offering forward secrecy
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+var fipsCurves = []tls.CurveID{tls.CurveP256, tls.CurveP384}
+
+var versions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Load reads a Config from the JSON file at path
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse TLS config %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// ClientConfig returns the tls.Config of a client
+func (c *Config) ClientConfig() (*tls.Config, error) {
+	cfg, err := c.base()
+	if err != nil {
+		return nil, err
+	}
+	cfg.ServerName = c.ServerName
+	if c.CAFile != "" {
+		if cfg.RootCAs, err = loadPool(c.CAFile); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// ServerConfig returns the tls.Config of a server
+func (c *Config) ServerConfig() (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, fmt.Errorf("a TLS server needs a certFile and a keyFile")
+	}
+	cfg, err := c.base()
+	if err != nil {
+		return nil, err
+	}
+	if c.CAFile != "" {
+		if cfg.ClientCAs, err = loadPool(c.CAFile); err != nil {
+			return nil, err
+		}
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	} else if len(c.PinnedKeys) > 0 {
+		// the pins identify the clients
+		cfg.ClientAuth = tls.RequireAnyClientCert
+	}
+	return cfg, nil
+}
+
+// base returns the settings shared by clients and servers
+func (c *Config) base() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if c.MinVersion != "" {
+		v, ok := versions[c.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported minVersion %q", c.MinVersion)
+		}
+		cfg.MinVersion = v
+	}
+
+	if len(c.CipherSuites) > 0 {
+		suites, err := cipherSuites(c.CipherSuites)
+		if err != nil {
+			return nil, err
+		}
+		cfg.CipherSuites = suites
+	}
+
+	if c.FIPS {
+		if cfg.MinVersion > tls.VersionTLS12 {
+			// the TLS 1.3 cipher suites can't be restricted
+			return nil, fmt.Errorf("fips requires minVersion 1.2")
+		}
+		// so that only the cipher suites below can be negotiated
+		cfg.MaxVersion = tls.VersionTLS12
+		cfg.CurvePreferences = fipsCurves
+		if cfg.CipherSuites == nil {
+			cfg.CipherSuites = fipsCipherSuites
+		}
+		for _, s := range cfg.CipherSuites {
+			if !isFIPSCipherSuite(s) {
+				return nil, fmt.Errorf("cipher suite %s is not FIPS approved", tls.CipherSuiteName(s))
+			}
+		}
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(c.PinnedKeys) > 0 {
+		pins := map[string]bool{}
+		for _, p := range c.PinnedKeys {
+			pins[strings.ToLower(p)] = true
+		}
+		cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return fmt.Errorf("peer presented no certificate")
+			}
+			cert, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			if !pins[KeyHash(cert)] {
+				return fmt.Errorf("public key of %q is not pinned", cert.Subject.CommonName)
+			}
+			return nil
+		}
+	}
+
+	return cfg, nil
+}
+
+// KeyHash returns the hash cert is pinned by in PinnedKeys
+func KeyHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return hex.EncodeToString(sum[:])
+}
+
+func cipherSuites(names []string) ([]uint16, error) {
+	byName := map[string]uint16{}
+	for _, s := range tls.CipherSuites() {
+		byName[s.Name] = s.ID
+	}
+
+	suites := []uint16{}
+	for _, name := range names {
+		id, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		suites = append(suites, id)
+	}
+	return suites, nil
+}
+
+func isFIPSCipherSuite(id uint16) bool {
+	for _, s := range fipsCipherSuites {
+		if s == id {
+			return true
+		}
+	}
+	return false
+}
+
+func loadPool(path string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
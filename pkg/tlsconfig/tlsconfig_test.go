@@ -0,0 +1,206 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tlsconfig_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/containernetworking/cni/pkg/tlsconfig"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// writeCert writes a self-signed certificate for 127.0.0.1 and its key
+// to dir and returns the certificate
+func writeCert(dir, name string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	Expect(ioutil.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0644)).To(Succeed())
+	Expect(ioutil.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0600)).To(Succeed())
+
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).NotTo(HaveOccurred())
+	return cert
+}
+
+// handshake connects a client and a server with the given configs and
+// returns the state of the client's connection
+func handshake(client, server *tls.Config) (tls.ConnectionState, error) {
+	l, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	Expect(err).NotTo(HaveOccurred())
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.(*tls.Conn).Handshake()
+	}()
+
+	conn, err := tls.Dial("tcp", l.Addr().String(), client)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer conn.Close()
+	// with TLS 1.3 a rejected client certificate is only reported after
+	// the handshake, so read until the server hangs up
+	_, err = conn.Read(make([]byte, 1))
+	if err != nil && err != io.EOF {
+		return tls.ConnectionState{}, err
+	}
+	return conn.ConnectionState(), nil
+}
+
+var _ = Describe("Config", func() {
+	var (
+		dir        string
+		serverCert *x509.Certificate
+		server     *tlsconfig.Config
+		client     *tlsconfig.Config
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "cni-tls")
+		Expect(err).NotTo(HaveOccurred())
+
+		serverCert = writeCert(dir, "server")
+		writeCert(dir, "client")
+		server = &tlsconfig.Config{
+			CertFile: filepath.Join(dir, "server.crt"),
+			KeyFile:  filepath.Join(dir, "server.key"),
+		}
+		client = &tlsconfig.Config{CAFile: filepath.Join(dir, "server.crt")}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	connect := func() (tls.ConnectionState, error) {
+		serverCfg, err := server.ServerConfig()
+		Expect(err).NotTo(HaveOccurred())
+		clientCfg, err := client.ClientConfig()
+		Expect(err).NotTo(HaveOccurred())
+		return handshake(clientCfg, serverCfg)
+	}
+
+	It("loads a config from JSON", func() {
+		path := filepath.Join(dir, "tls.json")
+		Expect(ioutil.WriteFile(path, []byte(`{"caFile": "/ca.pem", "minVersion": "1.3", "fips": true}`), 0644)).To(Succeed())
+
+		c, err := tlsconfig.Load(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c).To(Equal(&tlsconfig.Config{CAFile: "/ca.pem", MinVersion: "1.3", FIPS: true}))
+	})
+
+	It("verifies the server against the CA file", func() {
+		state, err := connect()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state.Version).To(BeNumerically(">=", tls.VersionTLS12))
+
+		client.CAFile = filepath.Join(dir, "client.crt")
+		_, err = connect()
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("restricts the connection to FIPS approved algorithms", func() {
+		server.FIPS = true
+		state, err := connect()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state.Version).To(BeEquivalentTo(tls.VersionTLS12))
+		Expect(state.CipherSuite).To(BeEquivalentTo(tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256))
+
+		client.FIPS = true
+		client.CipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256"}
+		_, err = client.ClientConfig()
+		Expect(err).To(MatchError("cipher suite TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256 is not FIPS approved"))
+
+		client.CipherSuites = nil
+		client.MinVersion = "1.3"
+		_, err = client.ClientConfig()
+		Expect(err).To(MatchError("fips requires minVersion 1.2"))
+	})
+
+	It("rejects unknown settings", func() {
+		client.MinVersion = "1.0"
+		_, err := client.ClientConfig()
+		Expect(err).To(MatchError(`unsupported minVersion "1.0"`))
+
+		client.MinVersion = ""
+		client.CipherSuites = []string{"TLS_RSA_WITH_RC4_128_SHA"}
+		_, err = client.ClientConfig()
+		Expect(err).To(MatchError(`unknown or insecure cipher suite "TLS_RSA_WITH_RC4_128_SHA"`))
+
+		_, err = (&tlsconfig.Config{}).ServerConfig()
+		Expect(err).To(MatchError("a TLS server needs a certFile and a keyFile"))
+	})
+
+	It("only accepts pinned keys", func() {
+		client.PinnedKeys = []string{tlsconfig.KeyHash(serverCert)}
+		_, err := connect()
+		Expect(err).NotTo(HaveOccurred())
+
+		client.PinnedKeys = []string{"00"}
+		_, err = connect()
+		Expect(err).To(MatchError(ContainSubstring(`public key of "server" is not pinned`)))
+	})
+
+	It("requires client certificates signed by the CA file", func() {
+		server.CAFile = filepath.Join(dir, "client.crt")
+		_, err := connect()
+		Expect(err).To(HaveOccurred())
+
+		client.CertFile = filepath.Join(dir, "client.crt")
+		client.KeyFile = filepath.Join(dir, "client.key")
+		_, err = connect()
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
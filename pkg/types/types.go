@@ -57,12 +57,35 @@ func (n *IPNet) UnmarshalJSON(data []byte) error {
 
 // NetConf describes a network.
 type NetConf struct {
+	CNIVersion string `json:"cniVersion,omitempty"`
+
 	Name string `json:"name,omitempty"`
 	Type string `json:"type,omitempty"`
 	IPAM struct {
 		Type string `json:"type,omitempty"`
 	} `json:"ipam,omitempty"`
 	DNS DNS `json:"dns"`
+
+	// PrevResult is the result of an earlier plugin in a chain,
+	// if the caller supplied one
+	PrevResult *Result `json:"prevResult,omitempty"`
+}
+
+// InjectPrevResult returns a copy of netconf with its "prevResult" key
+// set to prevResult, for the next plugin of a chain or anything else run
+// after a plugin. A nil prevResult leaves netconf unchanged.
+func InjectPrevResult(netconf []byte, prevResult *Result) ([]byte, error) {
+	if prevResult == nil {
+		return netconf, nil
+	}
+
+	config := make(map[string]interface{})
+	if err := json.Unmarshal(netconf, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse netconf: %v", err)
+	}
+	config["prevResult"] = prevResult
+
+	return json.Marshal(config)
 }
 
 // Result is what gets returned from the plugin (via stdout) to the caller
@@ -105,18 +128,77 @@ type DNS struct {
 	Options     []string `json:"options,omitempty"`
 }
 
+// MergeDNS combines statically configured DNS settings with those
+// learned at runtime (e.g. from a DHCP server). Configured nameservers,
+// search domains and options come first, followed by any learned ones
+// not already present; a configured domain takes precedence.
+func MergeDNS(configured, learned DNS) DNS {
+	merged := DNS{
+		Nameservers: appendUnique(configured.Nameservers, learned.Nameservers),
+		Domain:      configured.Domain,
+		Search:      appendUnique(configured.Search, learned.Search),
+		Options:     appendUnique(configured.Options, learned.Options),
+	}
+	if merged.Domain == "" {
+		merged.Domain = learned.Domain
+	}
+	return merged
+}
+
+func appendUnique(list []string, more []string) []string {
+	var result []string
+	seen := map[string]bool{}
+	for _, s := range append(append([]string{}, list...), more...) {
+		if !seen[s] {
+			seen[s] = true
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
 type Route struct {
 	Dst net.IPNet
 	GW  net.IP
 }
 
+// Well-known error codes, see SPEC.md. Runtimes may decide on retries based
+// on them.
+const (
+	ErrUnknown                     uint = iota // 0
+	ErrIncompatibleCNIVersion                  // 1
+	ErrUnsupportedField                        // 2
+	ErrUnknownContainer                        // 3
+	ErrInvalidEnvironmentVariables             // 4
+	ErrIOFailure                               // 5
+	ErrDecodingFailure                         // 6
+	ErrInvalidNetworkConfig                    // 7
+	ErrTryAgainLater               uint = 11
+
+	// ErrPlugin is the code of any other failure of a plugin
+	ErrPlugin uint = 100
+)
+
 type Error struct {
 	Code    uint   `json:"code"`
 	Msg     string `json:"msg"`
 	Details string `json:"details,omitempty"`
 }
 
+// NewError returns an Error with the given code, a short message and
+// details, like the error that caused it
+func NewError(code uint, msg, details string) *Error {
+	return &Error{
+		Code:    code,
+		Msg:     msg,
+		Details: details,
+	}
+}
+
 func (e *Error) Error() string {
+	if e.Details != "" {
+		return fmt.Sprintf("%v; %v", e.Msg, e.Details)
+	}
 	return e.Msg
 }
 
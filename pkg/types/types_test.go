@@ -0,0 +1,88 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types_test
+
+import (
+	"net"
+
+	. "github.com/containernetworking/cni/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MergeDNS", func() {
+	It("puts configured values first and drops duplicates", func() {
+		merged := MergeDNS(
+			DNS{
+				Nameservers: []string{"10.0.0.53"},
+				Search:      []string{"svc.cluster.local"},
+			},
+			DNS{
+				Nameservers: []string{"192.168.0.1", "10.0.0.53"},
+				Domain:      "example.com",
+				Search:      []string{"example.com"},
+				Options:     []string{"ndots:5"},
+			},
+		)
+
+		Expect(merged).To(Equal(DNS{
+			Nameservers: []string{"10.0.0.53", "192.168.0.1"},
+			Domain:      "example.com",
+			Search:      []string{"svc.cluster.local", "example.com"},
+			Options:     []string{"ndots:5"},
+		}))
+	})
+
+	It("prefers the configured domain", func() {
+		merged := MergeDNS(DNS{Domain: "configured"}, DNS{Domain: "learned"})
+		Expect(merged.Domain).To(Equal("configured"))
+	})
+
+	It("returns an empty DNS when nothing is set", func() {
+		Expect(MergeDNS(DNS{}, DNS{})).To(Equal(DNS{}))
+	})
+})
+
+var _ = Describe("InjectPrevResult", func() {
+	const netconf = `{ "name": "mynet", "ipam": { "type": "host-local" } }`
+
+	It("adds the prevResult to the netconf", func() {
+		prevResult := &Result{
+			IP4: &IPConfig{
+				IP: net.IPNet{IP: net.ParseIP("10.1.2.3"), Mask: net.CIDRMask(24, 32)},
+			},
+		}
+
+		newConf, err := InjectPrevResult([]byte(netconf), prevResult)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(newConf).To(MatchJSON(`{
+			"name": "mynet",
+			"ipam": { "type": "host-local" },
+			"prevResult": { "ip4": { "ip": "10.1.2.3/24" }, "dns": {} }
+		}`))
+	})
+
+	It("leaves the netconf untouched without a prevResult", func() {
+		newConf, err := InjectPrevResult([]byte(netconf), nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(newConf).To(BeEquivalentTo(netconf))
+	})
+
+	It("returns an error for a malformed netconf", func() {
+		_, err := InjectPrevResult([]byte(`{ "name": `), &Result{})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,106 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/containernetworking/cni/pkg/types"
+)
+
+// legacy is the version of network configurations without a cniVersion
+const legacy = "0.1.0"
+
+// A ResultConverter returns a result in the format of the spec version
+// given, ready to be marshalled to JSON
+type ResultConverter func(result *types.Result, version string) (interface{}, error)
+
+var resultFormats = map[string]ResultConverter{}
+
+// RegisterResultFormat makes results printable in the given versions,
+// using conv to convert them
+func RegisterResultFormat(conv ResultConverter, versions ...string) {
+	for _, v := range versions {
+		resultFormats[v] = conv
+	}
+}
+
+func init() {
+	RegisterResultFormat(convertTo020, "0.1.0", "0.2.0")
+}
+
+// result020 is the result format of the 0.1.0 and 0.2.0 specs, which
+// only differ in the VERSION command
+type result020 struct {
+	CNIVersion string `json:"cniVersion"`
+	*types.Result
+}
+
+func convertTo020(result *types.Result, version string) (interface{}, error) {
+	return &result020{CNIVersion: version, Result: result}, nil
+}
+
+// SupportedVersions returns the spec versions results can be printed in
+func SupportedVersions() []string {
+	versions := []string{}
+	for v := range resultFormats {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// Check returns an ErrIncompatibleCNIVersion error if results can't be
+// printed in the given version. An empty version is that of network
+// configurations which predate cniVersion.
+func Check(version string) error {
+	if version == "" {
+		version = legacy
+	}
+	if _, ok := resultFormats[version]; !ok {
+		return types.NewError(types.ErrIncompatibleCNIVersion, "incompatible CNI versions",
+			fmt.Sprintf("config is %q, plugin supports %v", version, SupportedVersions()))
+	}
+	return nil
+}
+
+// ConvertResult returns result in the format of the given version
+func ConvertResult(result *types.Result, version string) (interface{}, error) {
+	if err := Check(version); err != nil {
+		return nil, err
+	}
+	if version == "" {
+		version = legacy
+	}
+	return resultFormats[version](result, version)
+}
+
+// PrintResult prints result to stdout, in the format of the version the
+// network configuration asked for
+func PrintResult(result *types.Result, version string) error {
+	r, err := ConvertResult(result, version)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
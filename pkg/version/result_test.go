@@ -0,0 +1,76 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version_test
+
+import (
+	"encoding/json"
+	"net"
+
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/version"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Results", func() {
+	var result *types.Result
+
+	BeforeEach(func() {
+		ipn, err := types.ParseCIDR("10.1.2.3/24")
+		Expect(err).NotTo(HaveOccurred())
+		result = &types.Result{
+			IP4: &types.IPConfig{IP: *ipn, Gateway: net.ParseIP("10.1.2.1")},
+			DNS: types.DNS{Nameservers: []string{"10.1.2.1"}},
+		}
+	})
+
+	marshal := func(v string) string {
+		r, err := version.ConvertResult(result, v)
+		Expect(err).NotTo(HaveOccurred())
+		data, err := json.Marshal(r)
+		Expect(err).NotTo(HaveOccurred())
+		return string(data)
+	}
+
+	It("converts results to the version asked for", func() {
+		Expect(marshal("0.2.0")).To(MatchJSON(`{
+			"cniVersion": "0.2.0",
+			"ip4": {"ip": "10.1.2.3/24", "gateway": "10.1.2.1"},
+			"dns": {"nameservers": ["10.1.2.1"]}
+		}`))
+		Expect(marshal("0.1.0")).To(MatchJSON(`{
+			"cniVersion": "0.1.0",
+			"ip4": {"ip": "10.1.2.3/24", "gateway": "10.1.2.1"},
+			"dns": {"nameservers": ["10.1.2.1"]}
+		}`))
+	})
+
+	It("uses the oldest version for configurations without one", func() {
+		Expect(version.Check("")).To(Succeed())
+		Expect(marshal("")).To(ContainSubstring(`"cniVersion":"0.1.0"`))
+	})
+
+	It("rejects versions it can't print", func() {
+		Expect(version.SupportedVersions()).To(Equal([]string{"0.1.0", "0.2.0"}))
+
+		_, err := version.ConvertResult(result, "0.3.0")
+		Expect(err).To(Equal(&types.Error{
+			Code:    types.ErrIncompatibleCNIVersion,
+			Msg:     "incompatible CNI versions",
+			Details: `config is "0.3.0", plugin supports [0.1.0 0.2.0]`,
+		}))
+	})
+})
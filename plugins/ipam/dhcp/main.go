@@ -1,4 +1,4 @@
-// Copyright 2015 CNI authors
+// Copyright 2016 CNI authors
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -12,62 +12,12 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// dhcp is an IPAM plugin which gets addresses from a DHCP server, through
+// the daemon it also runs as.
 package main
 
-import (
-	"fmt"
-	"net/rpc"
-	"os"
-	"path/filepath"
-
-	"github.com/containernetworking/cni/pkg/skel"
-	"github.com/containernetworking/cni/pkg/types"
-)
-
-const socketPath = "/run/cni/dhcp.sock"
+import "github.com/containernetworking/cni/plugins/ipam/dhcp/plugin"
 
 func main() {
-	if len(os.Args) > 1 && os.Args[1] == "daemon" {
-		runDaemon()
-	} else {
-		skel.PluginMain(cmdAdd, cmdDel)
-	}
-}
-
-func cmdAdd(args *skel.CmdArgs) error {
-	result := types.Result{}
-	if err := rpcCall("DHCP.Allocate", args, &result); err != nil {
-		return err
-	}
-	return result.Print()
-}
-
-func cmdDel(args *skel.CmdArgs) error {
-	result := struct{}{}
-	if err := rpcCall("DHCP.Release", args, &result); err != nil {
-		return fmt.Errorf("error dialing DHCP daemon: %v", err)
-	}
-	return nil
-}
-
-func rpcCall(method string, args *skel.CmdArgs, result interface{}) error {
-	client, err := rpc.DialHTTP("unix", socketPath)
-	if err != nil {
-		return fmt.Errorf("error dialing DHCP daemon: %v", err)
-	}
-
-	// The daemon may be running under a different working dir
-	// so make sure the netns path is absolute.
-	netns, err := filepath.Abs(args.Netns)
-	if err != nil {
-		return fmt.Errorf("failed to make %q an absolute path: %v", args.Netns, err)
-	}
-	args.Netns = netns
-
-	err = client.Call(method, args, result)
-	if err != nil {
-		return fmt.Errorf("error calling %v: %v", method, err)
-	}
-
-	return nil
+	plugin.Main()
 }
@@ -12,13 +12,12 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package plugin
 
 import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"net"
 	"net/http"
 	"net/rpc"
@@ -26,38 +25,114 @@ import (
 	"path/filepath"
 	"runtime"
 	"sync"
+	"time"
 
+	"github.com/containernetworking/cni/pkg/logging"
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
 	"github.com/coreos/go-systemd/activation"
 )
 
 const listenFdsStart = 3
-const resendCount = 3
 
 var errNoMoreTries = errors.New("no more tries")
 
+// NetConf is the subset of the network configuration used by the daemon
+type NetConf struct {
+	types.NetConf
+	IPAM struct {
+		DNS        types.DNS `json:"dns"`
+		Timeout    string    `json:"timeout"`
+		Retries    *int      `json:"retries"`
+		Backoff    string    `json:"backoff"`
+		MaxBackoff string    `json:"maxBackoff"`
+	} `json:"ipam"`
+}
+
+// exchangeConfig returns the configured timing of the DHCP exchange,
+// using the defaults for anything left unset
+func (n *NetConf) exchangeConfig() (exchangeConfig, error) {
+	c := defaultExchangeConfig
+
+	durations := []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"timeout", n.IPAM.Timeout, &c.timeout},
+		{"backoff", n.IPAM.Backoff, &c.backoff},
+		{"maxBackoff", n.IPAM.MaxBackoff, &c.maxBackoff},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil {
+			return c, fmt.Errorf("invalid %s %q: %v", d.name, d.value, err)
+		}
+		if v <= 0 {
+			return c, fmt.Errorf("invalid %s %q: must be positive", d.name, d.value)
+		}
+		*d.dst = v
+	}
+
+	if n.IPAM.Retries != nil {
+		if *n.IPAM.Retries < 1 {
+			return c, fmt.Errorf("invalid retries %d: must be at least 1", *n.IPAM.Retries)
+		}
+		c.retries = *n.IPAM.Retries
+	}
+
+	if c.maxBackoff < c.backoff {
+		return c, fmt.Errorf("maxBackoff %v is less than backoff %v", c.maxBackoff, c.backoff)
+	}
+
+	return c, nil
+}
+
 type DHCP struct {
 	mux    sync.Mutex
-	leases map[string]*DHCPLease
+	leases map[leaseKey]*DHCPLease
+}
+
+type leaseKey struct {
+	containerID string
+	network     string
+}
+
+// LeaseInfo describes a lease maintained by the daemon
+type LeaseInfo struct {
+	ContainerID string
+	Network     string
+	Netns       string
+	IfName      string
+	IP          string
+	// Acquired is when the daemon acquired the lease
+	Acquired time.Time
 }
 
 func newDHCP() *DHCP {
 	return &DHCP{
-		leases: make(map[string]*DHCPLease),
+		leases: make(map[leaseKey]*DHCPLease),
 	}
 }
 
 // Allocate acquires an IP from a DHCP server for a specified container.
 // The acquired lease will be maintained until Release() is called.
 func (d *DHCP) Allocate(args *skel.CmdArgs, result *types.Result) error {
-	conf := types.NetConf{}
+	conf := NetConf{}
 	if err := json.Unmarshal(args.StdinData, &conf); err != nil {
 		return fmt.Errorf("error parsing netconf: %v", err)
 	}
 
+	exchange, err := conf.exchangeConfig()
+	if err != nil {
+		return err
+	}
+
 	clientID := args.ContainerID + "/" + conf.Name
-	l, err := AcquireLease(clientID, args.Netns, args.IfName)
+	l, err := AcquireLease(clientID, args.Netns, args.IfName, exchange)
 	if err != nil {
 		return err
 	}
@@ -75,6 +150,7 @@ func (d *DHCP) Allocate(args *skel.CmdArgs, result *types.Result) error {
 		Gateway: l.Gateway(),
 		Routes:  l.Routes(),
 	}
+	result.DNS = types.MergeDNS(conf.IPAM.DNS, l.DNS())
 
 	return nil
 }
@@ -88,6 +164,7 @@ func (d *DHCP) Release(args *skel.CmdArgs, reply *struct{}) error {
 	}
 
 	if l := d.getLease(args.ContainerID, conf.Name); l != nil {
+		d.clearLease(args.ContainerID, conf.Name)
 		l.Stop()
 		return nil
 	}
@@ -95,12 +172,31 @@ func (d *DHCP) Release(args *skel.CmdArgs, reply *struct{}) error {
 	return fmt.Errorf("lease not found: %v/%v", args.ContainerID, conf.Name)
 }
 
+// Leases lists the leases maintained by the daemon
+func (d *DHCP) Leases(args struct{}, reply *[]LeaseInfo) error {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	leases := []LeaseInfo{}
+	for k, l := range d.leases {
+		leases = append(leases, LeaseInfo{
+			ContainerID: k.containerID,
+			Network:     k.network,
+			Netns:       l.netns.Path(),
+			IfName:      l.ifName,
+			IP:          l.ip,
+			Acquired:    l.acquired,
+		})
+	}
+	*reply = leases
+	return nil
+}
+
 func (d *DHCP) getLease(contID, netName string) *DHCPLease {
 	d.mux.Lock()
 	defer d.mux.Unlock()
 
-	// TODO(eyakubovich): hash it to avoid collisions
-	l, ok := d.leases[contID+netName]
+	l, ok := d.leases[leaseKey{contID, netName}]
 	if !ok {
 		return nil
 	}
@@ -111,8 +207,14 @@ func (d *DHCP) setLease(contID, netName string, l *DHCPLease) {
 	d.mux.Lock()
 	defer d.mux.Unlock()
 
-	// TODO(eyakubovich): hash it to avoid collisions
-	d.leases[contID+netName] = l
+	d.leases[leaseKey{contID, netName}] = l
+}
+
+func (d *DHCP) clearLease(contID, netName string) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	delete(d.leases, leaseKey{contID, netName})
 }
 
 func getListener() (net.Listener, error) {
@@ -144,9 +246,16 @@ func runDaemon() {
 	// ensure the RPC server does not get scheduled onto those
 	runtime.LockOSThread()
 
+	logger, err := logging.FromConfig(logging.Config{}, os.Getenv, os.Stderr)
+	if err != nil {
+		logging.Errorf("Error configuring logging: %v", err)
+		return
+	}
+	logging.SetDefault(logger)
+
 	l, err := getListener()
 	if err != nil {
-		log.Printf("Error getting listener: %v", err)
+		logging.Errorf("Error getting listener: %v", err)
 		return
 	}
 
@@ -0,0 +1,68 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func parseExchangeConfig(t *testing.T, ipam string) (exchangeConfig, error) {
+	conf := NetConf{}
+	if err := json.Unmarshal([]byte(`{"name": "net", "ipam": `+ipam+`}`), &conf); err != nil {
+		t.Fatal(err)
+	}
+	return conf.exchangeConfig()
+}
+
+func TestExchangeConfigDefaults(t *testing.T) {
+	c, err := parseExchangeConfig(t, `{"type": "dhcp"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != defaultExchangeConfig {
+		t.Errorf("got %+v, expected the defaults %+v", c, defaultExchangeConfig)
+	}
+}
+
+func TestExchangeConfig(t *testing.T) {
+	c, err := parseExchangeConfig(t, `{"type": "dhcp", "timeout": "2s", "retries": 5, "backoff": "500ms", "maxBackoff": "8s"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := exchangeConfig{
+		timeout:    2 * time.Second,
+		retries:    5,
+		backoff:    500 * time.Millisecond,
+		maxBackoff: 8 * time.Second,
+	}
+	if c != expected {
+		t.Errorf("got %+v, expected %+v", c, expected)
+	}
+}
+
+func TestExchangeConfigInvalid(t *testing.T) {
+	for _, ipam := range []string{
+		`{"timeout": "soon"}`,
+		`{"timeout": "-1s"}`,
+		`{"retries": 0}`,
+		`{"backoff": "10s", "maxBackoff": "5s"}`,
+	} {
+		if _, err := parseExchangeConfig(t, ipam); err == nil {
+			t.Errorf("expected an error for %s", ipam)
+		}
+	}
+}
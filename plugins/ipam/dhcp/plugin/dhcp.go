@@ -0,0 +1,98 @@
+// Copyright 2015 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/rpc"
+	"os"
+	"path/filepath"
+
+	"github.com/containernetworking/cni/pkg/ns"
+	"github.com/containernetworking/cni/pkg/schema"
+	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/version"
+)
+
+const socketPath = "/run/cni/dhcp.sock"
+
+// Main runs the daemon if the first argument is "daemon", or else the
+// plugin for the command in the environment
+func Main() {
+	if len(os.Args) > 1 && os.Args[1] == "daemon" {
+		runDaemon()
+	} else {
+		skel.PluginMainWithSchema(cmdAdd, cmdDel, schema.ForNetConf(&NetConf{}))
+	}
+}
+
+func cmdAdd(args *skel.CmdArgs) error {
+	// the daemon does the privileged work
+	args.DropCapabilities()
+
+	conf := types.NetConf{}
+	if err := json.Unmarshal(args.StdinData, &conf); err != nil {
+		return types.NewError(types.ErrDecodingFailure, "failed to load netconf", err.Error())
+	}
+
+	result := types.Result{}
+	if err := rpcCall("DHCP.Allocate", args, &result); err != nil {
+		return err
+	}
+	return version.PrintResult(&result, conf.CNIVersion)
+}
+
+func cmdDel(args *skel.CmdArgs) error {
+	args.DropCapabilities()
+
+	result := struct{}{}
+	if err := rpcCall("DHCP.Release", args, &result); err != nil {
+		return err
+	}
+	return nil
+}
+
+func rpcCall(method string, args *skel.CmdArgs, result interface{}) error {
+	client, err := rpc.DialHTTP("unix", socketPath)
+	if err != nil {
+		// the daemon may not be up yet
+		return types.NewError(types.ErrTryAgainLater, "error dialing DHCP daemon", err.Error())
+	}
+
+	// The daemon may be running under a different working dir
+	// so make sure the netns path is absolute.
+	netns, err := ns.ResolvePath(args.Netns)
+	if err != nil {
+		return types.NewError(types.ErrInvalidEnvironmentVariables, err.Error(), "")
+	}
+	netns, err = filepath.Abs(netns)
+	if err != nil {
+		return fmt.Errorf("failed to make %q an absolute path: %v", args.Netns, err)
+	}
+	args.Netns = netns
+
+	err = client.Call(method, args, result)
+	switch {
+	case err == rpc.ServerError(errNoMoreTries.Error()):
+		// the DHCP server didn't answer
+		return types.NewError(types.ErrTryAgainLater, fmt.Sprintf("error calling %v", method), err.Error())
+	case err != nil:
+		return fmt.Errorf("error calling %v: %v", method, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,391 @@
+// Copyright 2015 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/d2g/dhcp4"
+	"github.com/d2g/dhcp4client"
+	"github.com/vishvananda/netlink"
+
+	"github.com/containernetworking/cni/pkg/logging"
+	"github.com/containernetworking/cni/pkg/ns"
+	"github.com/containernetworking/cni/pkg/retry"
+	"github.com/containernetworking/cni/pkg/types"
+)
+
+// exchangeConfig controls how long each step of a DHCP exchange waits
+// for a reply and how failed steps are retried
+type exchangeConfig struct {
+	timeout    time.Duration
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+// RFC 2131 suggests using exponential backoff, starting with 4sec
+// and randomized to +/- 1sec
+var defaultExchangeConfig = exchangeConfig{
+	timeout:    5 * time.Second,
+	retries:    3,
+	backoff:    4 * time.Second,
+	maxBackoff: 32 * time.Second,
+}
+
+const (
+	leaseStateBound = iota
+	leaseStateRenewing
+	leaseStateRebinding
+)
+
+// Each lease is maintained by its own goroutine running in the daemon's
+// namespace. Only the operations that touch the interface or its sockets
+// switch into the container's network namespace, each on a dedicated
+// OS thread for the duration of that operation. This way a namespace that
+// stalls (or disappears) only affects the lease that lives in it.
+
+type DHCPLease struct {
+	clientID      string
+	ack           *dhcp4.Packet
+	opts          dhcp4.Options
+	netns         ns.NetNS
+	ifName        string
+	ip            string
+	acquired      time.Time
+	exchange      exchangeConfig
+	link          netlink.Link
+	renewalTime   time.Time
+	rebindingTime time.Time
+	expireTime    time.Time
+	stop          chan struct{}
+	ctx           context.Context
+	cancel        context.CancelFunc
+	wg            sync.WaitGroup
+}
+
+// AcquireLease gets an DHCP lease and then maintains it in the background
+// by periodically renewing it. The acquired lease can be released by
+// calling DHCPLease.Stop()
+func AcquireLease(clientID, netns, ifName string, exchange exchangeConfig) (*DHCPLease, error) {
+	netNS, err := ns.GetNS(netns)
+	if err != nil {
+		return nil, types.NewError(types.ErrUnknownContainer, fmt.Sprintf("failed to open netns %q", netns), err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &DHCPLease{
+		clientID: clientID,
+		netns:    netNS,
+		ifName:   ifName,
+		exchange: exchange,
+		stop:     make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	logging.Infof("%v: acquiring lease", clientID)
+
+	err = l.netns.Do(func(_ ns.NetNS) error {
+		link, err := netlink.LinkByName(ifName)
+		if err != nil {
+			return fmt.Errorf("error looking up %q: %v", ifName, err)
+		}
+		l.link = link
+		return nil
+	})
+	if err == nil {
+		err = l.acquire()
+	}
+	if err != nil {
+		cancel()
+		l.netns.Close()
+		return nil, err
+	}
+
+	logging.Infof("%v: lease acquired, expiration is %v", l.clientID, l.expireTime)
+	// renewals keep the address, so it can be read without racing maintain()
+	l.ip = l.ack.YIAddr().String()
+	l.acquired = time.Now()
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		defer l.netns.Close()
+		l.maintain()
+	}()
+
+	return l, nil
+}
+
+// Stop terminates the background task that maintains the lease, cutting
+// short any retries of an exchange, and issues a DHCP Release
+func (l *DHCPLease) Stop() {
+	l.cancel()
+	close(l.stop)
+	l.wg.Wait()
+}
+
+func (l *DHCPLease) acquire() error {
+	var pkt *dhcp4.Packet
+	err := l.netns.Do(func(_ ns.NetNS) error {
+		c, err := newDHCPClient(l.link, l.exchange.timeout)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+
+		if (l.link.Attrs().Flags & net.FlagUp) != net.FlagUp {
+			logging.Infof("Link %q down. Attempting to set up", l.link.Attrs().Name)
+			if err = netlink.LinkSetUp(l.link); err != nil {
+				return err
+			}
+		}
+
+		pkt, err = backoffRetry(l.ctx, l.exchange, func() (*dhcp4.Packet, error) {
+			ok, ack, err := c.Request()
+			switch {
+			case err != nil:
+				return nil, err
+			case !ok:
+				return nil, fmt.Errorf("DHCP server NACK'd own offer")
+			default:
+				return &ack, nil
+			}
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return l.commit(pkt)
+}
+
+func (l *DHCPLease) commit(ack *dhcp4.Packet) error {
+	opts := ack.ParseOptions()
+
+	leaseTime, err := parseLeaseTime(opts)
+	if err != nil {
+		return err
+	}
+
+	rebindingTime, err := parseRebindingTime(opts)
+	if err != nil || rebindingTime > leaseTime {
+		// Per RFC 2131 Section 4.4.5, it should default to 85% of lease time
+		rebindingTime = leaseTime * 85 / 100
+	}
+
+	renewalTime, err := parseRenewalTime(opts)
+	if err != nil || renewalTime > rebindingTime {
+		// Per RFC 2131 Section 4.4.5, it should default to 50% of lease time
+		renewalTime = leaseTime / 2
+	}
+
+	now := time.Now()
+	l.expireTime = now.Add(leaseTime)
+	l.renewalTime = now.Add(renewalTime)
+	l.rebindingTime = now.Add(rebindingTime)
+	l.ack = ack
+	l.opts = opts
+
+	return nil
+}
+
+func (l *DHCPLease) maintain() {
+	state := leaseStateBound
+
+	for {
+		var sleepDur time.Duration
+
+		switch state {
+		case leaseStateBound:
+			sleepDur = l.renewalTime.Sub(time.Now())
+			if sleepDur <= 0 {
+				logging.Infof("%v: renewing lease", l.clientID)
+				state = leaseStateRenewing
+				continue
+			}
+
+		case leaseStateRenewing:
+			if err := l.renew(); err != nil {
+				logging.Warningf("%v: %v", l.clientID, err)
+
+				if time.Now().After(l.rebindingTime) {
+					logging.Warningf("%v: renawal time expired, rebinding", l.clientID)
+					state = leaseStateRebinding
+				}
+			} else {
+				logging.Infof("%v: lease renewed, expiration is %v", l.clientID, l.expireTime)
+				state = leaseStateBound
+			}
+
+		case leaseStateRebinding:
+			if err := l.acquire(); err != nil {
+				logging.Warningf("%v: %v", l.clientID, err)
+
+				if time.Now().After(l.expireTime) {
+					logging.Warningf("%v: lease expired, bringing interface DOWN", l.clientID)
+					l.downIface()
+					return
+				}
+			} else {
+				logging.Infof("%v: lease rebound, expiration is %v", l.clientID, l.expireTime)
+				state = leaseStateBound
+			}
+		}
+
+		select {
+		case <-time.After(sleepDur):
+
+		case <-l.stop:
+			if err := l.release(); err != nil {
+				logging.Errorf("%v: failed to release DHCP lease: %v", l.clientID, err)
+			}
+			return
+		}
+	}
+}
+
+func (l *DHCPLease) downIface() {
+	err := l.netns.Do(func(_ ns.NetNS) error {
+		return netlink.LinkSetDown(l.link)
+	})
+	if err != nil {
+		logging.Errorf("%v: failed to bring %v interface DOWN: %v", l.clientID, l.link.Attrs().Name, err)
+	}
+}
+
+func (l *DHCPLease) renew() error {
+	var pkt *dhcp4.Packet
+	err := l.netns.Do(func(_ ns.NetNS) error {
+		c, err := newDHCPClient(l.link, l.exchange.timeout)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+
+		pkt, err = backoffRetry(l.ctx, l.exchange, func() (*dhcp4.Packet, error) {
+			ok, ack, err := c.Renew(*l.ack)
+			switch {
+			case err != nil:
+				return nil, err
+			case !ok:
+				return nil, fmt.Errorf("DHCP server did not renew lease")
+			default:
+				return &ack, nil
+			}
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	l.commit(pkt)
+	return nil
+}
+
+func (l *DHCPLease) release() error {
+	logging.Infof("%v: releasing lease", l.clientID)
+
+	return l.netns.Do(func(_ ns.NetNS) error {
+		c, err := newDHCPClient(l.link, l.exchange.timeout)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+
+		if err = c.Release(*l.ack); err != nil {
+			return fmt.Errorf("failed to send DHCPRELEASE")
+		}
+
+		return nil
+	})
+}
+
+func (l *DHCPLease) IPNet() (*net.IPNet, error) {
+	mask := parseSubnetMask(l.opts)
+	if mask == nil {
+		return nil, fmt.Errorf("DHCP option Subnet Mask not found in DHCPACK")
+	}
+
+	return &net.IPNet{
+		IP:   l.ack.YIAddr(),
+		Mask: mask,
+	}, nil
+}
+
+func (l *DHCPLease) Gateway() net.IP {
+	return parseRouter(l.opts)
+}
+
+func (l *DHCPLease) Routes() []types.Route {
+	routes := parseRoutes(l.opts)
+	return append(routes, parseCIDRRoutes(l.opts)...)
+}
+
+func (l *DHCPLease) DNS() types.DNS {
+	return parseDNS(l.opts)
+}
+
+// retryBackoff returns the backoff between the attempts of an exchange step
+func (c exchangeConfig) retryBackoff() retry.Backoff {
+	return retry.Backoff{
+		Steps:   c.retries,
+		Initial: c.backoff,
+		Factor:  2,
+		Max:     c.maxBackoff,
+		Jitter:  time.Second,
+	}
+}
+
+func backoffRetry(ctx context.Context, exchange exchangeConfig, f func() (*dhcp4.Packet, error)) (*dhcp4.Packet, error) {
+	var pkt *dhcp4.Packet
+	err := retry.Do(ctx, exchange.retryBackoff(), func() error {
+		var err error
+		pkt, err = f()
+		if err != nil {
+			logging.Warningf("%v", err)
+		}
+		return err
+	})
+	switch {
+	case err == nil:
+		return pkt, nil
+	case err == ctx.Err():
+		return nil, err
+	default:
+		return nil, errNoMoreTries
+	}
+}
+
+func newDHCPClient(link netlink.Link, timeout time.Duration) (*dhcp4client.Client, error) {
+	pktsock, err := dhcp4client.NewPacketSock(link.Attrs().Index)
+	if err != nil {
+		return nil, err
+	}
+
+	return dhcp4client.New(
+		dhcp4client.HardwareAddr(link.Attrs().HardwareAddr),
+		dhcp4client.Timeout(timeout),
+		dhcp4client.Broadcast(false),
+		dhcp4client.Connection(pktsock),
+	)
+}
@@ -12,12 +12,13 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package plugin
 
 import (
 	"encoding/binary"
 	"fmt"
 	"net"
+	"strings"
 	"time"
 
 	"github.com/containernetworking/cni/pkg/types"
@@ -104,6 +105,24 @@ func parseCIDRRoutes(opts dhcp4.Options) []types.Route {
 	return routes
 }
 
+func parseDNS(opts dhcp4.Options) types.DNS {
+	dns := types.DNS{}
+
+	if opt, ok := opts[dhcp4.OptionDomainNameServer]; ok {
+		for len(opt) >= 4 {
+			dns.Nameservers = append(dns.Nameservers, net.IP(opt[0:4]).String())
+			opt = opt[4:]
+		}
+	}
+
+	if opt, ok := opts[dhcp4.OptionDomainName]; ok {
+		// some servers NUL-terminate the name
+		dns.Domain = strings.TrimRight(string(opt), "\x00")
+	}
+
+	return dns
+}
+
 func parseSubnetMask(opts dhcp4.Options) net.IPMask {
 	mask, ok := opts[dhcp4.OptionSubnetMask]
 	if !ok {
@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package plugin
 
 import (
 	"net"
@@ -73,3 +73,17 @@ func TestParseCIDRRoutes(t *testing.T) {
 
 	validateRoutes(t, routes)
 }
+
+func TestParseDNS(t *testing.T) {
+	opts := make(dhcp4.Options)
+	opts[dhcp4.OptionDomainNameServer] = []byte{10, 0, 0, 53, 10, 0, 1, 53}
+	opts[dhcp4.OptionDomainName] = []byte("example.com\x00")
+	dns := parseDNS(opts)
+
+	if len(dns.Nameservers) != 2 || dns.Nameservers[0] != "10.0.0.53" || dns.Nameservers[1] != "10.0.1.53" {
+		t.Errorf("nameservers mismatch: got %v", dns.Nameservers)
+	}
+	if dns.Domain != "example.com" {
+		t.Errorf("domain mismatch: expected example.com, got %q", dns.Domain)
+	}
+}
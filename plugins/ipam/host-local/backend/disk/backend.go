@@ -15,66 +15,141 @@
 package disk
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/containernetworking/cni/pkg/store"
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/plugins/ipam/host-local/backend"
+)
+
+const (
+	lastIPFile      = "last_reserved_ip"
+	lastIP6File     = "last_reserved_ip6"
+	releaseTimeFile = "release_times"
 )
 
-const lastIPFile = "last_reserved_ip"
+// poolsDir is the subdirectory of a network's data dir that holds the
+// data dirs of its pools
+const poolsDir = "pools"
 
 var defaultDataDir = "/var/lib/cni/networks"
 
+// Store keeps the allocations of a network, or a pool of it, in a
+// store.Store. Each reserved IP is a key holding the ID of the container
+// that reserved it.
 type Store struct {
-	FileLock
-	dataDir string
+	s store.Store
 }
 
-func New(network string) (*Store, error) {
-	dir := filepath.Join(defaultDataDir, network)
-	if err := os.MkdirAll(dir, 0644); err != nil {
-		return nil, err
+// Networks returns the names of all networks with a data dir below
+// dataDir, which defaults to /var/lib/cni/networks
+func Networks(dataDir string) ([]string, error) {
+	if dataDir == "" {
+		dataDir = defaultDataDir
 	}
+	return subdirs(dataDir)
+}
 
-	lk, err := NewFileLock(dir)
-	if err != nil {
+// Pools returns the IDs of all pools of network below dataDir
+func Pools(dataDir, network string) ([]string, error) {
+	if dataDir == "" {
+		dataDir = defaultDataDir
+	}
+	return subdirs(filepath.Join(dataDir, network, poolsDir))
+}
+
+func subdirs(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	switch {
+	case os.IsNotExist(err):
+		return nil, nil
+	case err != nil:
 		return nil, err
 	}
-	return &Store{*lk, dir}, nil
+
+	names := []string{}
+	for _, f := range files {
+		if f.IsDir() {
+			names = append(names, f.Name())
+		}
+	}
+	return names, nil
 }
 
-func (s *Store) Reserve(id string, ip net.IP) (bool, error) {
-	fname := filepath.Join(s.dataDir, ip.String())
-	f, err := os.OpenFile(fname, os.O_RDWR|os.O_EXCL|os.O_CREATE, 0644)
-	if os.IsExist(err) {
-		return false, nil
+// New opens the store of network below dataDir, which defaults to
+// /var/lib/cni/networks. A non-empty poolID selects a pool of the
+// network whose allocations are kept apart from all other pools.
+func New(dataDir, network, poolID string) (*Store, error) {
+	if dataDir == "" {
+		dataDir = defaultDataDir
 	}
+	if err := checkPathElement("network name", network); err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(dataDir, network)
+	if poolID != "" {
+		if err := checkPathElement("pool ID", poolID); err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(dir, poolsDir, poolID)
+	}
+	fs, err := store.NewFilesystem(dir)
 	if err != nil {
-		return false, err
+		return nil, types.NewError(types.ErrIOFailure, fmt.Sprintf("failed to open the data dir %s", dir), err.Error())
 	}
-	if _, err := f.WriteString(id); err != nil {
-		f.Close()
-		os.Remove(f.Name())
+	return NewWithStore(fs), nil
+}
+
+// NewWithStore returns the Store keeping its allocations in s
+func NewWithStore(s store.Store) *Store {
+	return &Store{s}
+}
+
+func (s *Store) Lock() error {
+	return s.s.Lock()
+}
+
+func (s *Store) Unlock() error {
+	return s.s.Unlock()
+}
+
+func (s *Store) Close() error {
+	return s.s.Close()
+}
+
+func (s *Store) Reserve(id string, ip net.IP) (bool, error) {
+	created, err := s.s.Create(ip.String(), []byte(id))
+	if err != nil || !created {
 		return false, err
 	}
-	if err := f.Close(); err != nil {
-		os.Remove(f.Name())
+	if err := s.addToIndex(id, ip); err != nil {
+		s.s.Delete(ip.String())
 		return false, err
 	}
-	// store the reserved ip in lastIPFile
-	ipfile := filepath.Join(s.dataDir, lastIPFile)
-	err = ioutil.WriteFile(ipfile, []byte(ip.String()), 0644)
-	if err != nil {
+	// store the reserved ip in the lastIPFile of its family
+	if err := s.s.Put(lastIPKey(backend.FamilyOf(ip)), []byte(ip.String())); err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
-// LastReservedIP returns the last reserved IP if exists
-func (s *Store) LastReservedIP() (net.IP, error) {
-	ipfile := filepath.Join(s.dataDir, lastIPFile)
-	data, err := ioutil.ReadFile(ipfile)
+func lastIPKey(family int) string {
+	if family == backend.FamilyV6 {
+		return lastIP6File
+	}
+	return lastIPFile
+}
+
+// LastReservedIP returns the last reserved IP of family if exists
+func (s *Store) LastReservedIP(family int) (net.IP, error) {
+	data, err := s.s.Get(lastIPKey(family))
 	if err != nil {
 		return nil, fmt.Errorf("Failed to retrieve last reserved ip: %v", err)
 	}
@@ -82,26 +157,187 @@ func (s *Store) LastReservedIP() (net.IP, error) {
 }
 
 func (s *Store) Release(ip net.IP) error {
-	return os.Remove(filepath.Join(s.dataDir, ip.String()))
+	id, err := s.s.Get(ip.String())
+	if err != nil {
+		return err
+	}
+	if err := s.s.Delete(ip.String()); err != nil {
+		return err
+	}
+	if err := s.removeFromIndex(string(id), ip); err != nil {
+		return err
+	}
+	return s.recordRelease(ip.String())
 }
 
 // N.B. This function eats errors to be tolerant and
 // release as much as possible
 func (s *Store) ReleaseByID(id string) error {
-	err := filepath.Walk(s.dataDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil || info.IsDir() {
-			return nil
+	ips, ok, err := s.readIndex(id)
+	if err != nil || !ok {
+		// allocations made before the index existed need a full scan
+		return s.releaseByIDScan(id)
+	}
+
+	released := []string{}
+	for _, ip := range ips {
+		data, err := s.s.Get(ip)
+		if err != nil || string(data) != id {
+			continue
+		}
+		if err := s.s.Delete(ip); err != nil {
+			continue
+		}
+		released = append(released, ip)
+	}
+	if err := s.writeIndex(id, nil); err != nil {
+		return err
+	}
+	return s.recordRelease(released...)
+}
+
+func (s *Store) releaseByIDScan(id string) error {
+	reservations, err := s.Reservations()
+	if err != nil {
+		return err
+	}
+
+	released := []string{}
+	for ip, owner := range reservations {
+		if owner != id {
+			continue
+		}
+		if err := s.s.Delete(ip); err != nil {
+			continue
 		}
-		data, err := ioutil.ReadFile(path)
+		released = append(released, ip)
+	}
+	return s.recordRelease(released...)
+}
+
+// ReservedIPsByID returns the IPs held by the container with the given ID
+func (s *Store) ReservedIPsByID(id string) ([]net.IP, error) {
+	ips, ok, err := s.readIndex(id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// allocations made before the index existed need a full scan
+		reservations, err := s.Reservations()
 		if err != nil {
-			return nil
+			return nil, err
 		}
-		if string(data) == id {
-			if err := os.Remove(path); err != nil {
-				return nil
+		for ip, owner := range reservations {
+			if owner == id {
+				ips = append(ips, ip)
 			}
 		}
+	}
+
+	result := []net.IP{}
+	for _, ip := range ips {
+		if parsed := net.ParseIP(ip); parsed != nil {
+			result = append(result, parsed)
+		}
+	}
+	return result, nil
+}
+
+// Reservations returns the ID stored in each reserved IP key. The
+// index and other pools are further down, so aren't listed.
+func (s *Store) Reservations() (map[string]string, error) {
+	keys, err := s.s.List("")
+	if err != nil {
+		return nil, err
+	}
+
+	reservations := make(map[string]string)
+	for _, key := range keys {
+		if isMetadataFile(key) || net.ParseIP(key) == nil {
+			continue
+		}
+		data, err := s.s.Get(key)
+		if err == store.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		reservations[key] = string(data)
+	}
+	return reservations, nil
+}
+
+// ReservationTimes returns the time each reserved IP was reserved at. It
+// is empty if the store doesn't know when its keys were set.
+func (s *Store) ReservationTimes() (map[string]time.Time, error) {
+	times := make(map[string]time.Time)
+	mt, ok := s.s.(store.ModTimer)
+	if !ok {
+		return times, nil
+	}
+
+	reservations, err := s.Reservations()
+	if err != nil {
+		return nil, err
+	}
+	for ip := range reservations {
+		t, err := mt.ModTime(ip)
+		if err == store.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		times[ip] = t
+	}
+	return times, nil
+}
+
+// ReleaseTimes returns the time each released IP was last released at
+func (s *Store) ReleaseTimes() (map[string]time.Time, error) {
+	times := make(map[string]time.Time)
+	data, err := s.s.Get(releaseTimeFile)
+	switch {
+	case err == store.ErrNotFound:
+		return times, nil
+	case err != nil:
+		return nil, fmt.Errorf("Failed to retrieve release times: %v", err)
+	}
+	if err := json.Unmarshal(data, &times); err != nil {
+		return nil, fmt.Errorf("Failed to parse release times: %v", err)
+	}
+	return times, nil
+}
+
+func (s *Store) recordRelease(ips ...string) error {
+	if len(ips) == 0 {
 		return nil
-	})
-	return err
+	}
+	times, err := s.ReleaseTimes()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, ip := range ips {
+		times[ip] = now
+	}
+	data, err := json.Marshal(times)
+	if err != nil {
+		return err
+	}
+	return s.s.Put(releaseTimeFile, data)
+}
+
+// checkPathElement makes sure name can't escape the data dir it is
+// joined to
+func checkPathElement(what, name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsRune(name, filepath.Separator) {
+		return types.NewError(types.ErrInvalidNetworkConfig, fmt.Sprintf("invalid %s %q", what, name), "")
+	}
+	return nil
+}
+
+func isMetadataFile(name string) bool {
+	return name == lastIPFile || name == lastIP6File || name == releaseTimeFile
 }
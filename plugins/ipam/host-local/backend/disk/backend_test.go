@@ -0,0 +1,257 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disk
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	statestore "github.com/containernetworking/cni/pkg/store"
+	"github.com/containernetworking/cni/plugins/ipam/host-local/backend"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("disk store", func() {
+	var (
+		tmpDir string
+		store  *Store
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "host-local-disk")
+		Expect(err).NotTo(HaveOccurred())
+
+		store, err = New(tmpDir, "mynet", "")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(store.Close()).To(Succeed())
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	ipStrings := func(ips []net.IP) []string {
+		s := []string{}
+		for _, ip := range ips {
+			s = append(s, ip.String())
+		}
+		return s
+	}
+
+	reserve := func(id, ip string) {
+		reserved, err := store.Reserve(id, net.ParseIP(ip))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reserved).To(BeTrue())
+	}
+
+	It("indexes the IPs reserved by each container", func() {
+		reserve("c1", "10.0.0.2")
+		reserve("c2", "10.0.0.3")
+		reserve("c1", "10.0.0.4")
+
+		ips, err := store.ReservedIPsByID("c1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ipStrings(ips)).To(ConsistOf("10.0.0.2", "10.0.0.4"))
+
+		reservations, err := store.Reservations()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reservations).To(Equal(map[string]string{
+			"10.0.0.2": "c1",
+			"10.0.0.3": "c2",
+			"10.0.0.4": "c1",
+		}))
+	})
+
+	It("keeps the index in sync on release", func() {
+		reserve("c1", "10.0.0.2")
+		reserve("c1", "10.0.0.3")
+
+		Expect(store.Release(net.ParseIP("10.0.0.2"))).To(Succeed())
+		ips, err := store.ReservedIPsByID("c1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ipStrings(ips)).To(ConsistOf("10.0.0.3"))
+
+		Expect(store.ReleaseByID("c1")).To(Succeed())
+		ips, err = store.ReservedIPsByID("c1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ips).To(BeEmpty())
+		Expect(filepath.Join(tmpDir, "mynet", "10.0.0.3")).NotTo(BeAnExistingFile())
+		Expect(filepath.Join(tmpDir, "mynet", indexDir, "c1")).NotTo(BeAnExistingFile())
+
+		times, err := store.ReleaseTimes()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(times).To(HaveKey("10.0.0.2"))
+		Expect(times).To(HaveKey("10.0.0.3"))
+	})
+
+	It("remembers the last reserved IP of each family", func() {
+		reserve("c1", "10.0.0.2")
+		reserve("c1", "2001:db8::2")
+
+		ip, err := store.LastReservedIP(backend.FamilyV4)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ip.String()).To(Equal("10.0.0.2"))
+		ip, err = store.LastReservedIP(backend.FamilyV6)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ip.String()).To(Equal("2001:db8::2"))
+
+		reservations, err := store.Reservations()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reservations).To(HaveLen(2))
+	})
+
+	It("returns the whole index", func() {
+		reserve("c1", "10.0.0.2")
+		reserve("c/2", "10.0.0.3")
+		reserve("c1", "10.0.0.4")
+
+		index, err := store.Index()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(index).To(Equal(map[string][]string{
+			"c1":  {"10.0.0.2", "10.0.0.4"},
+			"c/2": {"10.0.0.3"},
+		}))
+	})
+
+	It("falls back to scanning for allocations without an index", func() {
+		Expect(ioutil.WriteFile(filepath.Join(tmpDir, "mynet", "10.0.0.9"), []byte("old"), 0644)).To(Succeed())
+
+		ips, err := store.ReservedIPsByID("old")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ipStrings(ips)).To(ConsistOf("10.0.0.9"))
+
+		Expect(store.ReleaseByID("old")).To(Succeed())
+		Expect(filepath.Join(tmpDir, "mynet", "10.0.0.9")).NotTo(BeAnExistingFile())
+	})
+
+	It("reserves and releases IPs for an empty ID without indexing them", func() {
+		reserve("", "10.0.0.2")
+		reserve("", "10.0.0.3")
+		reserve("c1", "10.0.0.4")
+
+		ips, err := store.ReservedIPsByID("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ipStrings(ips)).To(ConsistOf("10.0.0.2", "10.0.0.3"))
+		index, err := store.Index()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(index).To(Equal(map[string][]string{"c1": {"10.0.0.4"}}))
+
+		Expect(store.Release(net.ParseIP("10.0.0.2"))).To(Succeed())
+		Expect(store.ReleaseByID("")).To(Succeed())
+		reservations, err := store.Reservations()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reservations).To(Equal(map[string]string{"10.0.0.4": "c1"}))
+	})
+
+	It("knows when each IP was reserved", func() {
+		before := time.Now().Add(-time.Second)
+		reserve("c1", "10.0.0.2")
+
+		times, err := store.ReservationTimes()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(times).To(HaveLen(1))
+		Expect(times["10.0.0.2"]).To(BeTemporally(">", before))
+	})
+
+	It("lists the networks on the host", func() {
+		other, err := New(tmpDir, "othernet", "")
+		Expect(err).NotTo(HaveOccurred())
+		defer other.Close()
+
+		networks, err := Networks(tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(networks).To(ConsistOf("mynet", "othernet"))
+	})
+
+	Context("with pools", func() {
+		var pool *Store
+
+		BeforeEach(func() {
+			var err error
+			pool, err = New(tmpDir, "mynet", "tenant-a")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(pool.Close()).To(Succeed())
+		})
+
+		It("keeps the allocations of a pool apart from the network's", func() {
+			reserve("c1", "10.0.0.2")
+
+			reserved, err := pool.Reserve("c1", net.ParseIP("10.0.0.2"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reserved).To(BeTrue())
+			Expect(filepath.Join(tmpDir, "mynet", poolsDir, "tenant-a", "10.0.0.2")).To(BeAnExistingFile())
+
+			Expect(store.ReleaseByID("c1")).To(Succeed())
+			ips, err := pool.ReservedIPsByID("c1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ipStrings(ips)).To(ConsistOf("10.0.0.2"))
+		})
+
+		It("is not touched by a scan of the network", func() {
+			Expect(ioutil.WriteFile(filepath.Join(tmpDir, "mynet", poolsDir, "tenant-a", "10.0.0.9"), []byte("old"), 0644)).To(Succeed())
+
+			Expect(store.ReleaseByID("old")).To(Succeed())
+			Expect(filepath.Join(tmpDir, "mynet", poolsDir, "tenant-a", "10.0.0.9")).To(BeAnExistingFile())
+		})
+
+		It("lists the pools of a network", func() {
+			pools, err := Pools(tmpDir, "mynet")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pools).To(ConsistOf("tenant-a"))
+
+			pools, err = Pools(tmpDir, "othernet")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pools).To(BeEmpty())
+		})
+	})
+
+	It("rejects names that would escape the data dir", func() {
+		_, err := New(tmpDir, "..", "")
+		Expect(err).To(MatchError(`invalid network name ".."`))
+
+		_, err = New(tmpDir, "mynet", "a/b")
+		Expect(err).To(MatchError(`invalid pool ID "a/b"`))
+	})
+	It("keeps its allocations in any store", func() {
+		memStore := NewWithStore(statestore.NewMemory())
+		defer memStore.Close()
+
+		reserved, err := memStore.Reserve("c1", net.ParseIP("10.0.0.2"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reserved).To(BeTrue())
+		reserved, err = memStore.Reserve("c2", net.ParseIP("10.0.0.2"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reserved).To(BeFalse())
+
+		index, err := memStore.Index()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(index).To(Equal(map[string][]string{"c1": {"10.0.0.2"}}))
+
+		Expect(memStore.ReleaseByID("c1")).To(Succeed())
+		reservations, err := memStore.Reservations()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reservations).To(BeEmpty())
+		Expect(filepath.Join(tmpDir, "mynet", "10.0.0.2")).NotTo(BeAnExistingFile())
+	})
+})
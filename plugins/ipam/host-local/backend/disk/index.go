@@ -0,0 +1,119 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disk
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/containernetworking/cni/pkg/store"
+)
+
+// The reverse index maps container IDs to the IPs they hold. It lives in
+// the indexDir subdirectory of the network's data dir, with one key per
+// container ID listing its IPs, one per line. CNI_CONTAINERID is optional,
+// and the allocations of the empty ID aren't indexed, but found by a scan
+// like those made before the index existed.
+const indexDir = "by-id"
+
+func indexKey(id string) string {
+	return indexDir + "/" + url.QueryEscape(id)
+}
+
+// readIndex returns the IPs recorded for id. The boolean is false if
+// there is no index entry for id at all.
+func (s *Store) readIndex(id string) ([]string, bool, error) {
+	if id == "" {
+		return nil, false, nil
+	}
+	data, err := s.s.Get(indexKey(id))
+	switch {
+	case err == store.ErrNotFound:
+		return nil, false, nil
+	case err != nil:
+		return nil, false, err
+	}
+
+	ips := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			ips = append(ips, line)
+		}
+	}
+	return ips, true, nil
+}
+
+func (s *Store) writeIndex(id string, ips []string) error {
+	if id == "" {
+		return nil
+	}
+	if len(ips) == 0 {
+		err := s.s.Delete(indexKey(id))
+		if err == store.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+	return s.s.Put(indexKey(id), []byte(strings.Join(ips, "\n")+"\n"))
+}
+
+func (s *Store) addToIndex(id string, ip net.IP) error {
+	ips, _, err := s.readIndex(id)
+	if err != nil {
+		return err
+	}
+	return s.writeIndex(id, append(ips, ip.String()))
+}
+
+func (s *Store) removeFromIndex(id string, ip net.IP) error {
+	ips, ok, err := s.readIndex(id)
+	if err != nil || !ok {
+		return err
+	}
+
+	kept := []string{}
+	for _, i := range ips {
+		if i != ip.String() {
+			kept = append(kept, i)
+		}
+	}
+	return s.writeIndex(id, kept)
+}
+
+// Index returns the IPs recorded for each container ID in the reverse
+// index
+func (s *Store) Index() (map[string][]string, error) {
+	keys, err := s.s.List(indexDir)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string][]string)
+	for _, key := range keys {
+		id, err := url.QueryUnescape(key)
+		if err != nil {
+			continue
+		}
+		ips, ok, err := s.readIndex(id)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			index[id] = ips
+		}
+	}
+	return index, nil
+}
@@ -14,14 +14,39 @@
 
 package backend
 
-import "net"
+import (
+	"net"
+	"time"
+)
+
+// The IP families, as returned by FamilyOf
+const (
+	FamilyV4 = 4
+	FamilyV6 = 6
+)
+
+// FamilyOf returns the family of ip
+func FamilyOf(ip net.IP) int {
+	if ip.To4() != nil {
+		return FamilyV4
+	}
+	return FamilyV6
+}
 
 type Store interface {
 	Lock() error
 	Unlock() error
 	Close() error
 	Reserve(id string, ip net.IP) (bool, error)
-	LastReservedIP() (net.IP, error)
+	// LastReservedIP returns the IP of family that was reserved last
+	LastReservedIP(family int) (net.IP, error)
 	Release(ip net.IP) error
 	ReleaseByID(id string) error
+	// ReservedIPsByID returns the IPs held by the container with the given ID
+	ReservedIPsByID(id string) ([]net.IP, error)
+	// Reservations returns the IDs holding each reserved IP, keyed by IP
+	Reservations() (map[string]string, error)
+	// ReleaseTimes returns the time each previously released IP
+	// was last given back to the pool
+	ReleaseTimes() (map[string]time.Time, error)
 }
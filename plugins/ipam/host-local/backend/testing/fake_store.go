@@ -16,15 +16,18 @@ package testing
 
 import (
 	"net"
+	"time"
 )
 
 type FakeStore struct {
 	ipMap          map[string]string
 	lastReservedIP net.IP
+	releaseTimes   map[string]time.Time
+	releaseCount   int64
 }
 
 func NewFakeStore(ipmap map[string]string, lastIP net.IP) *FakeStore {
-	return &FakeStore{ipmap, lastIP}
+	return &FakeStore{ipmap, lastIP, map[string]time.Time{}, 0}
 }
 
 func (s *FakeStore) Lock() error {
@@ -49,12 +52,13 @@ func (s *FakeStore) Reserve(id string, ip net.IP) (bool, error) {
 	return false, nil
 }
 
-func (s *FakeStore) LastReservedIP() (net.IP, error) {
+func (s *FakeStore) LastReservedIP(family int) (net.IP, error) {
 	return s.lastReservedIP, nil
 }
 
 func (s *FakeStore) Release(ip net.IP) error {
 	delete(s.ipMap, ip.String())
+	s.recordRelease(ip.String())
 	return nil
 }
 
@@ -67,6 +71,36 @@ func (s *FakeStore) ReleaseByID(id string) error {
 	}
 	for _, ip := range toDelete {
 		delete(s.ipMap, ip)
+		s.recordRelease(ip)
 	}
 	return nil
 }
+
+func (s *FakeStore) ReservedIPsByID(id string) ([]net.IP, error) {
+	ips := []net.IP{}
+	for ip, owner := range s.ipMap {
+		if owner == id {
+			ips = append(ips, net.ParseIP(ip))
+		}
+	}
+	return ips, nil
+}
+
+func (s *FakeStore) Reservations() (map[string]string, error) {
+	reservations := make(map[string]string, len(s.ipMap))
+	for ip, id := range s.ipMap {
+		reservations[ip] = id
+	}
+	return reservations, nil
+}
+
+func (s *FakeStore) ReleaseTimes() (map[string]time.Time, error) {
+	return s.releaseTimes, nil
+}
+
+// recordRelease uses a counter instead of the wall clock so that
+// the release order is deterministic in tests
+func (s *FakeStore) recordRelease(ip string) {
+	s.releaseCount++
+	s.releaseTimes[ip] = time.Unix(0, s.releaseCount)
+}
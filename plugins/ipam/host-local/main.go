@@ -1,4 +1,4 @@
-// Copyright 2015 CNI authors
+// Copyright 2016 CNI authors
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -12,63 +12,12 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// host-local is an IPAM plugin which allocates addresses out of ranges,
+// keeping its allocations on the host.
 package main
 
-import (
-	"github.com/containernetworking/cni/plugins/ipam/host-local/backend/disk"
-
-	"github.com/containernetworking/cni/pkg/skel"
-	"github.com/containernetworking/cni/pkg/types"
-)
+import "github.com/containernetworking/cni/plugins/ipam/host-local/plugin"
 
 func main() {
-	skel.PluginMain(cmdAdd, cmdDel)
-}
-
-func cmdAdd(args *skel.CmdArgs) error {
-	ipamConf, err := LoadIPAMConfig(args.StdinData, args.Args)
-	if err != nil {
-		return err
-	}
-
-	store, err := disk.New(ipamConf.Name)
-	if err != nil {
-		return err
-	}
-	defer store.Close()
-
-	allocator, err := NewIPAllocator(ipamConf, store)
-	if err != nil {
-		return err
-	}
-
-	ipConf, err := allocator.Get(args.ContainerID)
-	if err != nil {
-		return err
-	}
-
-	r := &types.Result{
-		IP4: ipConf,
-	}
-	return r.Print()
-}
-
-func cmdDel(args *skel.CmdArgs) error {
-	ipamConf, err := LoadIPAMConfig(args.StdinData, args.Args)
-	if err != nil {
-		return err
-	}
-
-	store, err := disk.New(ipamConf.Name)
-	if err != nil {
-		return err
-	}
-	defer store.Close()
-
-	allocator, err := NewIPAllocator(ipamConf, store)
-	if err != nil {
-		return err
-	}
-
-	return allocator.Release(args.ContainerID)
+	plugin.Main()
 }
@@ -12,18 +12,31 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package plugin
 
 import (
 	"fmt"
-	"log"
+	"math/big"
+	"math/rand"
 	"net"
+	"sort"
+	"time"
 
 	"github.com/containernetworking/cni/pkg/ip"
+	"github.com/containernetworking/cni/pkg/logging"
 	"github.com/containernetworking/cni/pkg/types"
 	"github.com/containernetworking/cni/plugins/ipam/host-local/backend"
 )
 
+const (
+	// strategySequential hands out the next free IP after the last reserved one
+	strategySequential = "sequential"
+	// strategyRandom starts the search for a free IP at a random point in the range
+	strategyRandom = "random"
+	// strategyLRU prefers IPs that were never used, then the ones released longest ago
+	strategyLRU = "lru"
+)
+
 type IPAllocator struct {
 	// start is inclusive and may be allocated
 	start net.IP
@@ -31,6 +44,7 @@ type IPAllocator struct {
 	end   net.IP
 	conf  *IPAMConfig
 	store backend.Store
+	rand  *rand.Rand
 }
 
 func NewIPAllocator(conf *IPAMConfig, store backend.Store) (*IPAllocator, error) {
@@ -38,7 +52,13 @@ func NewIPAllocator(conf *IPAMConfig, store backend.Store) (*IPAllocator, error)
 	// a /32 or /31
 	ones, masklen := conf.Subnet.Mask.Size()
 	if ones > masklen-2 {
-		return nil, fmt.Errorf("Network %v too small to allocate from", conf.Subnet)
+		return nil, invalidConfig(fmt.Errorf("Network %v too small to allocate from", conf.Subnet))
+	}
+
+	switch conf.AllocationStrategy {
+	case "", strategySequential, strategyRandom, strategyLRU:
+	default:
+		return nil, invalidConfig(fmt.Errorf("unknown allocationStrategy %q", conf.AllocationStrategy))
 	}
 
 	var (
@@ -48,7 +68,7 @@ func NewIPAllocator(conf *IPAMConfig, store backend.Store) (*IPAllocator, error)
 	)
 	start, end, err = networkRange((*net.IPNet)(&conf.Subnet))
 	if err != nil {
-		return nil, err
+		return nil, invalidConfig(err)
 	}
 
 	// skip the .0 address
@@ -56,17 +76,23 @@ func NewIPAllocator(conf *IPAMConfig, store backend.Store) (*IPAllocator, error)
 
 	if conf.RangeStart != nil {
 		if err := validateRangeIP(conf.RangeStart, (*net.IPNet)(&conf.Subnet), nil, nil); err != nil {
-			return nil, err
+			return nil, invalidConfig(err)
 		}
 		start = conf.RangeStart
 	}
 	if conf.RangeEnd != nil {
 		if err := validateRangeIP(conf.RangeEnd, (*net.IPNet)(&conf.Subnet), start, nil); err != nil {
-			return nil, err
+			return nil, invalidConfig(err)
 		}
 		end = conf.RangeEnd
 	}
-	return &IPAllocator{start, end, conf, store}, nil
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return &IPAllocator{start, end, conf, store, rnd}, nil
+}
+
+// invalidConfig returns err as an error of the IPAM configuration
+func invalidConfig(err error) error {
+	return types.NewError(types.ErrInvalidNetworkConfig, err.Error(), "")
 }
 
 func canonicalizeIP(ip net.IP) (net.IP, error) {
@@ -172,8 +198,11 @@ func (a *IPAllocator) Get(id string) (*types.IPConfig, error) {
 		return nil, fmt.Errorf("requested IP address %q is not available in network: %s", requestedIP, a.conf.Name)
 	}
 
-	startIP, endIP := a.getSearchRange()
-	for cur := startIP; !cur.Equal(endIP); cur = a.nextIP(cur) {
+	next, err := a.candidates()
+	if err != nil {
+		return nil, err
+	}
+	for cur := next(); cur != nil; cur = next() {
 		// don't allocate gateway IP
 		if gw != nil && cur.Equal(gw) {
 			continue
@@ -238,14 +267,124 @@ func (a *IPAllocator) nextIP(curIP net.IP) net.IP {
 	return ip.NextIP(curIP)
 }
 
+// candidates returns an iterator over the IPs to try, in the order
+// given by the configured allocation strategy. The iterator returns
+// nil once the range is exhausted.
+func (a *IPAllocator) candidates() (func() net.IP, error) {
+	switch a.conf.AllocationStrategy {
+	case strategyRandom:
+		return a.randomIPs(), nil
+	case strategyLRU:
+		return a.leastRecentlyReleasedIPs()
+	default:
+		return a.sequentialIPs(), nil
+	}
+}
+
+// sequentialIPs iterates the range starting after the last reserved ip
+func (a *IPAllocator) sequentialIPs() func() net.IP {
+	cur, endIP := a.getSearchRange()
+	return func() net.IP {
+		if cur.Equal(endIP) {
+			return nil
+		}
+		ip := cur
+		cur = a.nextIP(cur)
+		return ip
+	}
+}
+
+// randomIPs iterates the whole range once, starting at a random ip
+func (a *IPAllocator) randomIPs() func() net.IP {
+	first := a.randomIP()
+	cur := first
+	return func() net.IP {
+		if cur == nil {
+			return nil
+		}
+		ip := cur
+		cur = a.nextIP(cur)
+		if cur.Equal(first) {
+			cur = nil
+		}
+		return ip
+	}
+}
+
+// randomIP returns a random ip between start and end, inclusive
+func (a *IPAllocator) randomIP() net.IP {
+	start, _ := canonicalizeIP(a.start)
+	end, _ := canonicalizeIP(a.end)
+
+	lo := big.NewInt(0).SetBytes(start)
+	span := big.NewInt(0).SetBytes(end)
+	span.Sub(span, lo).Add(span, big.NewInt(1))
+
+	offset := big.NewInt(0).Rand(a.rand, span)
+	b := offset.Add(offset, lo).Bytes()
+
+	ip := make(net.IP, len(start))
+	copy(ip[len(ip)-len(b):], b)
+	return ip
+}
+
+// leastRecentlyReleasedIPs first iterates all ips which were never
+// released, and then the released ones ordered by their release time
+func (a *IPAllocator) leastRecentlyReleasedIPs() (func() net.IP, error) {
+	times, err := a.store.ReleaseTimes()
+	if err != nil {
+		return nil, err
+	}
+
+	subnet := net.IPNet{
+		IP:   a.conf.Subnet.IP,
+		Mask: a.conf.Subnet.Mask,
+	}
+	released := byReleaseTime{times: times}
+	for s := range times {
+		ip := net.ParseIP(s)
+		if ip == nil || validateRangeIP(ip, &subnet, a.start, a.end) != nil {
+			continue
+		}
+		released.ips = append(released.ips, ip)
+	}
+	sort.Sort(released)
+
+	unused := a.sequentialIPs()
+	return func() net.IP {
+		for ip := unused(); ip != nil; ip = unused() {
+			if _, ok := times[ip.String()]; !ok {
+				return ip
+			}
+		}
+		if len(released.ips) == 0 {
+			return nil
+		}
+		ip := released.ips[0]
+		released.ips = released.ips[1:]
+		return ip
+	}, nil
+}
+
+type byReleaseTime struct {
+	ips   []net.IP
+	times map[string]time.Time
+}
+
+func (b byReleaseTime) Len() int      { return len(b.ips) }
+func (b byReleaseTime) Swap(i, j int) { b.ips[i], b.ips[j] = b.ips[j], b.ips[i] }
+func (b byReleaseTime) Less(i, j int) bool {
+	return b.times[b.ips[i].String()].Before(b.times[b.ips[j].String()])
+}
+
 // getSearchRange returns the start and end ip based on the last reserved ip
 func (a *IPAllocator) getSearchRange() (net.IP, net.IP) {
 	var startIP net.IP
 	var endIP net.IP
 	startFromLastReservedIP := false
-	lastReservedIP, err := a.store.LastReservedIP()
+	lastReservedIP, err := a.store.LastReservedIP(backend.FamilyOf(a.start))
 	if err != nil {
-		log.Printf("Error retriving last reserved ip: %v", err)
+		logging.Debugf("Error retriving last reserved ip: %v", err)
 	} else if lastReservedIP != nil {
 		subnet := net.IPNet{
 			IP:   a.conf.Subnet.IP,
@@ -0,0 +1,63 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/containernetworking/cni/pkg/types"
+	fakestore "github.com/containernetworking/cni/plugins/ipam/host-local/backend/testing"
+)
+
+// benchmarkAllocator allocates and releases an address in a /16 that
+// is half full, which is where the strategies differ the most
+func benchmarkAllocator(b *testing.B, strategy string) {
+	_, subnet, err := net.ParseCIDR("10.1.0.0/16")
+	if err != nil {
+		b.Fatal(err)
+	}
+	conf := IPAMConfig{
+		Name:               "test",
+		Type:               "host-local",
+		Subnet:             types.IPNet(*subnet),
+		AllocationStrategy: strategy,
+	}
+
+	ipmap := map[string]string{}
+	for i := 0; i < 32768; i++ {
+		ipmap[fmt.Sprintf("10.1.%d.%d", i/256, i%256)] = fmt.Sprintf("c%d", i)
+	}
+	store := fakestore.NewFakeStore(ipmap, nil)
+	alloc, err := NewIPAllocator(&conf, store)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := alloc.Get("bench"); err != nil {
+			b.Fatal(err)
+		}
+		if err := alloc.Release("bench"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAllocatorSequential(b *testing.B) { benchmarkAllocator(b, "sequential") }
+func BenchmarkAllocatorRandom(b *testing.B)     { benchmarkAllocator(b, "random") }
+func BenchmarkAllocatorLRU(b *testing.B)        { benchmarkAllocator(b, "lru") }
@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package plugin
 
 import (
 	"fmt"
@@ -290,6 +290,81 @@ var _ = Describe("host-local ip allocator", func() {
 		})
 	})
 
+	Context("when an allocationStrategy is configured", func() {
+		var (
+			conf  IPAMConfig
+			store *fakestore.FakeStore
+		)
+
+		BeforeEach(func() {
+			subnet, err := types.ParseCIDR("10.0.0.0/29")
+			Expect(err).ToNot(HaveOccurred())
+
+			conf = IPAMConfig{
+				Name:   "test",
+				Type:   "host-local",
+				Subnet: types.IPNet{IP: subnet.IP, Mask: subnet.Mask},
+			}
+			store = fakestore.NewFakeStore(map[string]string{}, nil)
+		})
+
+		It("rejects unknown strategies", func() {
+			conf.AllocationStrategy = "banana"
+			_, err := NewIPAllocator(&conf, store)
+			Expect(err).To(MatchError(`unknown allocationStrategy "banana"`))
+		})
+
+		It("random hands out every free ip exactly once", func() {
+			conf.AllocationStrategy = "random"
+			alloc, err := NewIPAllocator(&conf, store)
+			Expect(err).ToNot(HaveOccurred())
+
+			allocated := []string{}
+			for i := 0; i < 5; i++ {
+				res, err := alloc.Get(fmt.Sprintf("ID%d", i))
+				Expect(err).ToNot(HaveOccurred())
+				allocated = append(allocated, res.IP.IP.String())
+			}
+			Expect(allocated).To(ConsistOf("10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"))
+
+			_, err = alloc.Get("ID")
+			Expect(err).To(MatchError("no IP addresses available in network: test"))
+		})
+
+		It("lru prefers never released ips", func() {
+			conf.AllocationStrategy = "lru"
+			Expect(store.Release(net.ParseIP("10.0.0.2"))).To(Succeed())
+
+			alloc, err := NewIPAllocator(&conf, store)
+			Expect(err).ToNot(HaveOccurred())
+
+			res, err := alloc.Get("ID")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.IP.IP.String()).To(Equal("10.0.0.3"))
+		})
+
+		It("lru hands out the ip released longest ago", func() {
+			conf.AllocationStrategy = "lru"
+			alloc, err := NewIPAllocator(&conf, store)
+			Expect(err).ToNot(HaveOccurred())
+
+			for i := 0; i < 5; i++ {
+				_, err := alloc.Get(fmt.Sprintf("ID%d", i))
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(store.Release(net.ParseIP("10.0.0.5"))).To(Succeed())
+			Expect(store.Release(net.ParseIP("10.0.0.3"))).To(Succeed())
+
+			res, err := alloc.Get("ID")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.IP.IP.String()).To(Equal("10.0.0.5"))
+
+			res, err = alloc.Get("ID")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.IP.IP.String()).To(Equal("10.0.0.3"))
+		})
+	})
+
 	Context("when out of ips", func() {
 		It("returns a meaningful error", func() {
 			testCases := []AllocatorTestCase{
@@ -0,0 +1,126 @@
+// Copyright 2015 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"encoding/json"
+	"net"
+
+	"github.com/containernetworking/cni/pkg/types"
+)
+
+// IPAMConfig represents the IP related network configuration.
+type IPAMConfig struct {
+	Name               string
+	CNIVersion         string        `json:"-"`
+	Type               string        `json:"type"`
+	RangeStart         net.IP        `json:"rangeStart"`
+	RangeEnd           net.IP        `json:"rangeEnd"`
+	Subnet             types.IPNet   `json:"subnet"`
+	Gateway            net.IP        `json:"gateway"`
+	Routes             []types.Route `json:"routes"`
+	AllocationStrategy string        `json:"allocationStrategy"`
+	DNS                types.DNS     `json:"dns"`
+	DataDir            string        `json:"dataDir"`
+	PoolID             string        `json:"poolId"`
+	// IP6 is an IPv6 range that is allocated from in addition to the
+	// subnet, for dual-stack networks
+	IP6  *IPRange  `json:"ip6"`
+	Args *IPAMArgs `json:"-"`
+}
+
+// IPRange is a range of a network besides its subnet. The other settings,
+// like the data dir, are those of the network.
+type IPRange struct {
+	RangeStart net.IP        `json:"rangeStart"`
+	RangeEnd   net.IP        `json:"rangeEnd"`
+	Subnet     types.IPNet   `json:"subnet"`
+	Gateway    net.IP        `json:"gateway"`
+	Routes     []types.Route `json:"routes"`
+}
+
+type IPAMArgs struct {
+	types.CommonArgs
+	IP  net.IP `json:"ip,omitempty"`
+	IP6 net.IP `json:"ip6,omitempty"`
+}
+
+type Net struct {
+	CNIVersion string      `json:"cniVersion"`
+	Name       string      `json:"name"`
+	IPAM       *IPAMConfig `json:"ipam"`
+}
+
+// NewIPAMConfig creates a NetworkConfig from the given network name.
+func LoadIPAMConfig(bytes []byte, args string) (*IPAMConfig, error) {
+	n := Net{}
+	if err := json.Unmarshal(bytes, &n); err != nil {
+		return nil, types.NewError(types.ErrDecodingFailure, "failed to load netconf", err.Error())
+	}
+
+	if n.IPAM == nil {
+		return nil, types.NewError(types.ErrInvalidNetworkConfig, "IPAM config missing 'ipam' key", "")
+	}
+
+	if args != "" {
+		n.IPAM.Args = &IPAMArgs{}
+		err := types.LoadArgs(args, n.IPAM.Args)
+		if err != nil {
+			return nil, types.NewError(types.ErrInvalidEnvironmentVariables, "invalid CNI_ARGS", err.Error())
+		}
+	}
+
+	// Copy net name into IPAM so not to drag Net struct around
+	n.IPAM.Name = n.Name
+	n.IPAM.CNIVersion = n.CNIVersion
+
+	if _, err := n.IPAM.IP6Config(); err != nil {
+		return nil, err
+	}
+
+	return n.IPAM, nil
+}
+
+// IP6Config returns the config of the IPv6 range of a dual-stack network,
+// or nil if there is none
+func (c *IPAMConfig) IP6Config() (*IPAMConfig, error) {
+	r := c.IP6
+	if r == nil {
+		return nil, nil
+	}
+	if c.Subnet.IP == nil || c.Subnet.IP.To4() == nil {
+		return nil, types.NewError(types.ErrInvalidNetworkConfig, "the subnet of a network with an ip6 range must be IPv4", "")
+	}
+	if r.Subnet.IP == nil || r.Subnet.IP.To4() != nil {
+		return nil, types.NewError(types.ErrInvalidNetworkConfig, "the subnet of ip6 must be IPv6", "")
+	}
+
+	ip6 := &IPAMConfig{
+		Name:               c.Name,
+		Type:               c.Type,
+		RangeStart:         r.RangeStart,
+		RangeEnd:           r.RangeEnd,
+		Subnet:             r.Subnet,
+		Gateway:            r.Gateway,
+		Routes:             r.Routes,
+		AllocationStrategy: c.AllocationStrategy,
+		DataDir:            c.DataDir,
+		PoolID:             c.PoolID,
+	}
+	if c.Args != nil {
+		ip6.Args = &IPAMArgs{CommonArgs: c.Args.CommonArgs, IP: c.Args.IP6}
+	}
+	return ip6, nil
+}
@@ -0,0 +1,261 @@
+// Copyright 2015 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/containernetworking/cni/plugins/ipam/host-local/backend/disk"
+
+	"github.com/containernetworking/cni/pkg/schema"
+	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/version"
+)
+
+// Main runs the tool if there are arguments, or else the plugin for the
+// command in the environment
+func Main() {
+	if len(os.Args) > 1 {
+		if err := runTool(os.Args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	skel.PluginMainWithSchema(cmdAdd, cmdDel, schema.ForNetConf(&Net{}))
+}
+
+// runTool implements the invocations of host-local that happen outside
+// of the CNI protocol, e.g. by an operator
+func runTool(args []string) error {
+	if len(args) == 0 {
+		return usage()
+	}
+
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	dataDir := flags.String("data-dir", "", "directory holding the network data dirs")
+	poolID := flags.String("pool", "", "pool of the network")
+	if err := flags.Parse(args[1:]); err != nil {
+		return usage()
+	}
+	if flags.NArg() != 1 {
+		return usage()
+	}
+	arg := flags.Arg(0)
+
+	switch args[0] {
+	case "export":
+		store, err := disk.New(*dataDir, arg, *poolID)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		snap, err := ExportSnapshot(arg, store)
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(snap, "", "    ")
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(append(data, '\n'))
+		return err
+
+	case "import":
+		snap := &Snapshot{}
+		if err := json.NewDecoder(os.Stdin).Decode(snap); err != nil {
+			return fmt.Errorf("failed to parse snapshot: %v", err)
+		}
+
+		// the snapshot is checked against the network configuration
+		netconf, err := ioutil.ReadFile(arg)
+		if err != nil {
+			return err
+		}
+		conf, err := LoadIPAMConfig(netconf, "")
+		if err != nil {
+			return err
+		}
+		if *dataDir != "" {
+			conf.DataDir = *dataDir
+		}
+		if *poolID != "" {
+			conf.PoolID = *poolID
+		}
+
+		store, err := disk.New(conf.DataDir, conf.Name, conf.PoolID)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		return ImportSnapshot(snap, conf, store)
+
+	case "show":
+		held, err := showContainer(*dataDir, arg)
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(held, "", "    ")
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(append(data, '\n'))
+		return err
+
+	default:
+		return usage()
+	}
+}
+
+// NetworkIPs lists the IPs a container holds in a network
+type NetworkIPs struct {
+	Network string   `json:"network"`
+	Pool    string   `json:"pool,omitempty"`
+	IPs     []net.IP `json:"ips"`
+}
+
+// showContainer looks up the IPs held by containerID in all networks
+// and pools below dataDir
+func showContainer(dataDir, containerID string) ([]NetworkIPs, error) {
+	networks, err := disk.Networks(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	held := []NetworkIPs{}
+	for _, network := range networks {
+		pools, err := disk.Pools(dataDir, network)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pool := range append([]string{""}, pools...) {
+			ips, err := reservedIPs(dataDir, network, pool, containerID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to look up %v in network %v: %v", containerID, network, err)
+			}
+
+			if len(ips) > 0 {
+				held = append(held, NetworkIPs{Network: network, Pool: pool, IPs: ips})
+			}
+		}
+	}
+	return held, nil
+}
+
+func reservedIPs(dataDir, network, poolID, containerID string) ([]net.IP, error) {
+	store, err := disk.New(dataDir, network, poolID)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	store.Lock()
+	defer store.Unlock()
+	return store.ReservedIPsByID(containerID)
+}
+
+func usage() error {
+	exe := filepath.Base(os.Args[0])
+	return fmt.Errorf("usage:\n  %s export [-data-dir <dir>] [-pool <id>] <network> > snapshot.json\n  %s import [-data-dir <dir>] [-pool <id>] <netconf file> < snapshot.json\n  %s show [-data-dir <dir>] <containerID>", exe, exe, exe)
+}
+
+func cmdAdd(args *skel.CmdArgs) error {
+	// host-local only touches its data dir, which root owns anyway
+	args.DropCapabilities()
+
+	ipamConf, err := LoadIPAMConfig(args.StdinData, args.Args)
+	if err != nil {
+		return err
+	}
+
+	store, err := disk.New(ipamConf.DataDir, ipamConf.Name, ipamConf.PoolID)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	allocator, err := NewIPAllocator(ipamConf, store)
+	if err != nil {
+		return err
+	}
+	var allocator6 *IPAllocator
+	ip6Conf, err := ipamConf.IP6Config()
+	if err != nil {
+		return err
+	}
+	if ip6Conf != nil {
+		allocator6, err = NewIPAllocator(ip6Conf, store)
+		if err != nil {
+			return err
+		}
+	}
+
+	ipConf, err := allocator.Get(args.ContainerID)
+	if err != nil {
+		return err
+	}
+
+	r := &types.Result{
+		DNS: ipamConf.DNS,
+	}
+	if ipConf.IP.IP.To4() != nil {
+		r.IP4 = ipConf
+	} else {
+		r.IP6 = ipConf
+	}
+
+	if allocator6 != nil {
+		r.IP6, err = allocator6.Get(args.ContainerID)
+		if err != nil {
+			// don't leak the IPv4 address of a failed ADD
+			allocator.Release(args.ContainerID)
+			return err
+		}
+	}
+	return version.PrintResult(r, ipamConf.CNIVersion)
+}
+
+func cmdDel(args *skel.CmdArgs) error {
+	// host-local only touches its data dir, which root owns anyway
+	args.DropCapabilities()
+
+	ipamConf, err := LoadIPAMConfig(args.StdinData, args.Args)
+	if err != nil {
+		return err
+	}
+
+	store, err := disk.New(ipamConf.DataDir, ipamConf.Name, ipamConf.PoolID)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	allocator, err := NewIPAllocator(ipamConf, store)
+	if err != nil {
+		return err
+	}
+
+	return allocator.Release(args.ContainerID)
+}
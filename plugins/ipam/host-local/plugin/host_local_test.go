@@ -0,0 +1,146 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/cni/pkg/testutils"
+	"github.com/containernetworking/cni/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("host-local", func() {
+	var dataDir string
+
+	BeforeEach(func() {
+		var err error
+		dataDir, err = ioutil.TempDir("", "host-local")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dataDir)).To(Succeed())
+	})
+
+	dualStackConf := func() []byte {
+		return []byte(fmt.Sprintf(`{
+			"name": "mynet",
+			"ipam": {
+				"type": "host-local",
+				"subnet": "10.1.2.0/24",
+				"dataDir": %q,
+				"ip6": {
+					"subnet": "2001:db8::/64",
+					"routes": [{"dst": "::/0"}]
+				}
+			}
+		}`, dataDir))
+	}
+
+	It("allocates from both families of a dual-stack network", func() {
+		args := &skel.CmdArgs{ContainerID: "c1", IfName: "eth0", StdinData: dualStackConf()}
+
+		result, err := testutils.CmdAddWithResult("", "eth0", func() error {
+			return cmdAdd(args)
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(testutils.HaveIP4("10.1.2.2/24"))
+		Expect(result).To(testutils.HaveGateway4("10.1.2.1"))
+		Expect(result).To(testutils.HaveIP6("2001:db8::2/64"))
+		Expect(result.IP6.Gateway.String()).To(Equal("2001:db8::1"))
+		Expect(result.IP6.Routes).To(HaveLen(1))
+
+		Expect(filepath.Join(dataDir, "mynet", "10.1.2.2")).To(BeAnExistingFile())
+		Expect(filepath.Join(dataDir, "mynet", "2001:db8::2")).To(BeAnExistingFile())
+
+		Expect(cmdDel(args)).To(Succeed())
+		Expect(filepath.Join(dataDir, "mynet", "10.1.2.2")).NotTo(BeAnExistingFile())
+		Expect(filepath.Join(dataDir, "mynet", "2001:db8::2")).NotTo(BeAnExistingFile())
+	})
+
+	It("returns only IPv6 for an IPv6 subnet", func() {
+		conf := fmt.Sprintf(`{"name": "mynet", "ipam": {"type": "host-local", "subnet": "2001:db8::/64", "dataDir": %q}}`, dataDir)
+		args := &skel.CmdArgs{ContainerID: "c1", IfName: "eth0", StdinData: []byte(conf)}
+
+		result, err := testutils.CmdAddWithResult("", "eth0", func() error {
+			return cmdAdd(args)
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.IP4).To(BeNil())
+		Expect(result).To(testutils.HaveIP6("2001:db8::2/64"))
+	})
+
+	It("allocates the requested IP of each family", func() {
+		args := &skel.CmdArgs{
+			ContainerID: "c1",
+			IfName:      "eth0",
+			Args:        "IP=10.1.2.9;IP6=2001:db8::9",
+			StdinData:   dualStackConf(),
+		}
+
+		result, err := testutils.CmdAddWithResult("", "eth0", func() error {
+			return cmdAdd(args)
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(testutils.HaveIP4("10.1.2.9/24"))
+		Expect(result).To(testutils.HaveIP6("2001:db8::9/64"))
+	})
+
+	It("releases the IPv4 address if the IPv6 one can't be allocated", func() {
+		add := func(id string) error {
+			_, err := testutils.CmdAddWithResult("", "eth0", func() error {
+				return cmdAdd(&skel.CmdArgs{ContainerID: id, IfName: "eth0", Args: "IP6=2001:db8::9", StdinData: dualStackConf()})
+			})
+			return err
+		}
+
+		Expect(add("c1")).To(Succeed())
+		Expect(add("c2")).To(MatchError(`requested IP address "2001:db8::9" is not available in network: mynet`))
+		Expect(filepath.Join(dataDir, "mynet", "10.1.2.3")).NotTo(BeAnExistingFile())
+	})
+
+	It("rejects an ip6 range that isn't IPv6", func() {
+		_, err := LoadIPAMConfig([]byte(`{
+			"name": "mynet",
+			"ipam": {"type": "host-local", "subnet": "10.1.2.0/24", "ip6": {"subnet": "10.1.3.0/24"}}
+		}`), "")
+		Expect(err).To(MatchError("the subnet of ip6 must be IPv6"))
+	})
+
+	It("reports the class of errors in their code", func() {
+		code := func(conf, args string) uint {
+			err := cmdAdd(&skel.CmdArgs{ContainerID: "c1", IfName: "eth0", Args: args, StdinData: []byte(conf)})
+			Expect(err).To(HaveOccurred())
+			Expect(err).To(BeAssignableToTypeOf(&types.Error{}))
+			return err.(*types.Error).Code
+		}
+		conf := func(name, subnet string) string {
+			return fmt.Sprintf(`{"name": %q, "ipam": {"type": "host-local", "subnet": %q, "dataDir": %q}}`, name, subnet, dataDir)
+		}
+
+		Expect(code(`{"name": `, "")).To(Equal(types.ErrDecodingFailure))
+		Expect(code(`{"name": "mynet"}`, "")).To(Equal(types.ErrInvalidNetworkConfig))
+		Expect(code(conf("mynet", "10.1.2.0/31"), "")).To(Equal(types.ErrInvalidNetworkConfig))
+		Expect(code(conf("..", "10.1.2.0/24"), "")).To(Equal(types.ErrInvalidNetworkConfig))
+		Expect(code(conf("mynet", "10.1.2.0/24"), "IP=banana")).To(Equal(types.ErrInvalidEnvironmentVariables))
+	})
+})
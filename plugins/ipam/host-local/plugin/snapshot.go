@@ -0,0 +1,153 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/containernetworking/cni/plugins/ipam/host-local/backend"
+)
+
+// Snapshot is a portable representation of the allocations of a network,
+// independent of the on-disk layout of the store
+type Snapshot struct {
+	Network     string       `json:"network"`
+	Allocations []Allocation `json:"allocations"`
+}
+
+// Allocation records that IP is held by the container with ID
+type Allocation struct {
+	IP net.IP `json:"ip"`
+	ID string `json:"id"`
+}
+
+// ExportSnapshot returns all current allocations of the store
+func ExportSnapshot(network string, store backend.Store) (*Snapshot, error) {
+	store.Lock()
+	defer store.Unlock()
+
+	reservations, err := store.Reservations()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reservations: %v", err)
+	}
+
+	snap := &Snapshot{
+		Network:     network,
+		Allocations: []Allocation{},
+	}
+	for ip, id := range reservations {
+		snap.Allocations = append(snap.Allocations, Allocation{IP: net.ParseIP(ip), ID: id})
+	}
+	sort.Sort(byIP(snap.Allocations))
+
+	return snap, nil
+}
+
+// ImportSnapshot reserves all allocations of snap in the store of the
+// network conf configures. The snapshot must be of that network, and all
+// its IPs in its ranges, or nothing is reserved. IPs which are already
+// held by the same ID are skipped; IPs held by a different ID are reported
+// as conflicts and left untouched.
+func ImportSnapshot(snap *Snapshot, conf *IPAMConfig, store backend.Store) error {
+	if snap.Network != conf.Name {
+		return fmt.Errorf("snapshot is of network %q, not %q", snap.Network, conf.Name)
+	}
+	ranges, err := allocators(conf, store)
+	if err != nil {
+		return err
+	}
+	for _, a := range snap.Allocations {
+		if a.IP == nil || a.ID == "" {
+			return fmt.Errorf("invalid allocation in snapshot: ip %q id %q", a.IP, a.ID)
+		}
+		if !inRanges(a.IP, ranges) {
+			return fmt.Errorf("%s of %s is outside the ranges of network %q", a.IP, a.ID, conf.Name)
+		}
+	}
+
+	store.Lock()
+	defer store.Unlock()
+
+	reservations, err := store.Reservations()
+	if err != nil {
+		return fmt.Errorf("failed to list reservations: %v", err)
+	}
+
+	conflicts := []string{}
+	for _, a := range snap.Allocations {
+		if id, ok := reservations[a.IP.String()]; ok {
+			if id != a.ID {
+				conflicts = append(conflicts, a.IP.String())
+			}
+			continue
+		}
+
+		reserved, err := store.Reserve(a.ID, a.IP)
+		if err != nil {
+			return fmt.Errorf("failed to reserve %v for %v: %v", a.IP, a.ID, err)
+		}
+		if !reserved {
+			conflicts = append(conflicts, a.IP.String())
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("IPs already reserved by other containers: %v", conflicts)
+	}
+	return nil
+}
+
+// allocators returns the allocators of the IPv4 range and, for dual-stack
+// networks, the IPv6 range of conf
+func allocators(conf *IPAMConfig, store backend.Store) ([]*IPAllocator, error) {
+	a, err := NewIPAllocator(conf, store)
+	if err != nil {
+		return nil, err
+	}
+	ranges := []*IPAllocator{a}
+
+	ip6Conf, err := conf.IP6Config()
+	if err != nil {
+		return nil, err
+	}
+	if ip6Conf != nil {
+		a6, err := NewIPAllocator(ip6Conf, store)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, a6)
+	}
+	return ranges, nil
+}
+
+func inRanges(ip net.IP, ranges []*IPAllocator) bool {
+	for _, a := range ranges {
+		if validateRangeIP(ip, (*net.IPNet)(&a.conf.Subnet), a.start, a.end) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+type byIP []Allocation
+
+func (b byIP) Len() int      { return len(b) }
+func (b byIP) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byIP) Less(i, j int) bool {
+	return bytes.Compare(b[i].IP.To16(), b[j].IP.To16()) < 0
+}
@@ -0,0 +1,152 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"encoding/json"
+	"net"
+
+	"github.com/containernetworking/cni/pkg/types"
+	fakestore "github.com/containernetworking/cni/plugins/ipam/host-local/backend/testing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("host-local snapshots", func() {
+	var conf *IPAMConfig
+
+	BeforeEach(func() {
+		subnet, err := types.ParseCIDR("10.0.0.0/24")
+		Expect(err).NotTo(HaveOccurred())
+		conf = &IPAMConfig{
+			Name:     "test",
+			Subnet:   types.IPNet(*subnet),
+			RangeEnd: net.ParseIP("10.0.0.100"),
+		}
+	})
+
+	It("exports all reservations ordered by IP", func() {
+		store := fakestore.NewFakeStore(map[string]string{
+			"10.0.0.10": "id2",
+			"10.0.0.2":  "id1",
+		}, nil)
+
+		snap, err := ExportSnapshot("test", store)
+		Expect(err).ToNot(HaveOccurred())
+
+		data, err := json.Marshal(snap)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(MatchJSON(`{
+			"network": "test",
+			"allocations": [
+				{ "ip": "10.0.0.2", "id": "id1" },
+				{ "ip": "10.0.0.10", "id": "id2" }
+			]
+		}`))
+	})
+
+	It("imports a snapshot into an empty store", func() {
+		snap := &Snapshot{
+			Network: "test",
+			Allocations: []Allocation{
+				{IP: net.ParseIP("10.0.0.2"), ID: "id1"},
+				{IP: net.ParseIP("10.0.0.3"), ID: "id2"},
+			},
+		}
+		store := fakestore.NewFakeStore(map[string]string{}, nil)
+
+		Expect(ImportSnapshot(snap, conf, store)).To(Succeed())
+
+		reservations, err := store.Reservations()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reservations).To(Equal(map[string]string{
+			"10.0.0.2": "id1",
+			"10.0.0.3": "id2",
+		}))
+	})
+
+	It("reports IPs held by other containers", func() {
+		snap := &Snapshot{
+			Network: "test",
+			Allocations: []Allocation{
+				{IP: net.ParseIP("10.0.0.2"), ID: "id1"},
+				{IP: net.ParseIP("10.0.0.3"), ID: "id2"},
+				{IP: net.ParseIP("10.0.0.4"), ID: "id3"},
+			},
+		}
+		store := fakestore.NewFakeStore(map[string]string{
+			"10.0.0.2": "id1",
+			"10.0.0.3": "other",
+		}, nil)
+
+		err := ImportSnapshot(snap, conf, store)
+		Expect(err).To(MatchError("IPs already reserved by other containers: [10.0.0.3]"))
+
+		reservations, err := store.Reservations()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reservations).To(HaveKeyWithValue("10.0.0.4", "id3"))
+		Expect(reservations).To(HaveKeyWithValue("10.0.0.3", "other"))
+	})
+
+	It("rejects the snapshot of another network", func() {
+		snap := &Snapshot{
+			Network:     "other",
+			Allocations: []Allocation{{IP: net.ParseIP("10.0.0.2"), ID: "id1"}},
+		}
+		store := fakestore.NewFakeStore(map[string]string{}, nil)
+
+		err := ImportSnapshot(snap, conf, store)
+		Expect(err).To(MatchError(`snapshot is of network "other", not "test"`))
+		Expect(store.Reservations()).To(BeEmpty())
+	})
+
+	It("reserves nothing if an IP is outside the range", func() {
+		snap := &Snapshot{
+			Network: "test",
+			Allocations: []Allocation{
+				{IP: net.ParseIP("10.0.0.2"), ID: "id1"},
+				{IP: net.ParseIP("10.0.0.200"), ID: "id2"},
+			},
+		}
+		store := fakestore.NewFakeStore(map[string]string{}, nil)
+
+		err := ImportSnapshot(snap, conf, store)
+		Expect(err).To(MatchError(`10.0.0.200 of id2 is outside the ranges of network "test"`))
+		Expect(store.Reservations()).To(BeEmpty())
+
+		snap.Allocations[1].IP = net.ParseIP("10.1.0.2")
+		err = ImportSnapshot(snap, conf, store)
+		Expect(err).To(MatchError(`10.1.0.2 of id2 is outside the ranges of network "test"`))
+		Expect(store.Reservations()).To(BeEmpty())
+	})
+
+	It("accepts IPs in the IPv6 range of a dual-stack network", func() {
+		subnet6, err := types.ParseCIDR("2001:db8::/64")
+		Expect(err).NotTo(HaveOccurred())
+		dualStack := *conf
+		dualStack.IP6 = &IPRange{Subnet: types.IPNet(*subnet6)}
+		snap := &Snapshot{
+			Network: "test",
+			Allocations: []Allocation{
+				{IP: net.ParseIP("10.0.0.2"), ID: "id1"},
+				{IP: net.ParseIP("2001:db8::2"), ID: "id1"},
+			},
+		}
+		store := fakestore.NewFakeStore(map[string]string{}, nil)
+
+		Expect(ImportSnapshot(snap, &dualStack, store)).To(Succeed())
+		Expect(store.Reservations()).To(HaveLen(2))
+	})
+})
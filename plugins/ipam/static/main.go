@@ -0,0 +1,23 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// static is an IPAM plugin which assigns the addresses it is given,
+// for workloads which have to keep fixed addresses. It keeps no state.
+package main
+
+import "github.com/containernetworking/cni/plugins/ipam/static/plugin"
+
+func main() {
+	plugin.Main()
+}
@@ -0,0 +1,206 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package plugin is the static IPAM plugin, which assigns the addresses it
+// is given, for workloads which have to keep fixed addresses. It keeps no
+// state. Main runs it, both from its own binary and from cni-plugins.
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/containernetworking/cni/pkg/schema"
+	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/version"
+)
+
+// IPAMConfig is the "ipam" section of the network configuration
+type IPAMConfig struct {
+	Type      string        `json:"type"`
+	Addresses []Address     `json:"addresses,omitempty"`
+	Routes    []types.Route `json:"routes,omitempty"`
+	DNS       types.DNS     `json:"dns"`
+}
+
+// Address is an address with its prefix, and the gateway reached
+// through it
+type Address struct {
+	Address types.IPNet `json:"address"`
+	Gateway net.IP      `json:"gateway,omitempty"`
+}
+
+// RuntimeConfig holds the addresses the runtime passes in the "ips"
+// capability
+type RuntimeConfig struct {
+	IPs []string `json:"ips,omitempty"`
+}
+
+type Net struct {
+	CNIVersion    string         `json:"cniVersion"`
+	Name          string         `json:"name"`
+	IPAM          *IPAMConfig    `json:"ipam"`
+	RuntimeConfig *RuntimeConfig `json:"runtimeConfig,omitempty"`
+}
+
+// IPAMArgs are the CNI_ARGS understood by static. IP and GATEWAY are
+// comma-separated lists, e.g. IP=10.1.2.3/24,2001:db8::3/64.
+type IPAMArgs struct {
+	types.CommonArgs
+	IP      addressList `json:"ip,omitempty"`
+	GATEWAY gatewayList `json:"gateway,omitempty"`
+}
+
+type addressList []types.IPNet
+
+func (l *addressList) UnmarshalText(data []byte) error {
+	for _, s := range strings.Split(string(data), ",") {
+		ipn, err := types.ParseCIDR(s)
+		if err != nil {
+			return err
+		}
+		*l = append(*l, types.IPNet(*ipn))
+	}
+	return nil
+}
+
+type gatewayList []net.IP
+
+func (l *gatewayList) UnmarshalText(data []byte) error {
+	for _, s := range strings.Split(string(data), ",") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return fmt.Errorf("invalid gateway %q", s)
+		}
+		*l = append(*l, ip)
+	}
+	return nil
+}
+
+// loadConfig returns the configuration of the network with the addresses
+// to assign. The addresses of the "ips" capability take precedence over
+// those of CNI_ARGS, which take precedence over those of the "ipam"
+// section.
+func loadConfig(stdin []byte, args string) (*Net, []Address, error) {
+	n := &Net{}
+	if err := json.Unmarshal(stdin, n); err != nil {
+		return nil, nil, types.NewError(types.ErrDecodingFailure, "failed to load netconf", err.Error())
+	}
+	if n.IPAM == nil {
+		return nil, nil, types.NewError(types.ErrInvalidNetworkConfig, "IPAM config missing 'ipam' key", "")
+	}
+
+	ipamArgs := &IPAMArgs{}
+	if err := types.LoadArgs(args, ipamArgs); err != nil {
+		return nil, nil, types.NewError(types.ErrInvalidEnvironmentVariables, "invalid CNI_ARGS", err.Error())
+	}
+
+	addresses := n.IPAM.Addresses
+	if n.RuntimeConfig != nil && len(n.RuntimeConfig.IPs) > 0 {
+		ips := addressList{}
+		if err := ips.UnmarshalText([]byte(strings.Join(n.RuntimeConfig.IPs, ","))); err != nil {
+			return nil, nil, types.NewError(types.ErrInvalidNetworkConfig, "invalid address in runtimeConfig", err.Error())
+		}
+		addresses = withGateways(ips, ipamArgs.GATEWAY)
+	} else if len(ipamArgs.IP) > 0 {
+		addresses = withGateways(ipamArgs.IP, ipamArgs.GATEWAY)
+	}
+
+	if err := validateAddresses(addresses); err != nil {
+		return nil, nil, err
+	}
+	return n, addresses, nil
+}
+
+// withGateways pairs each address with the gateway of the same family
+func withGateways(ips []types.IPNet, gateways []net.IP) []Address {
+	addresses := []Address{}
+	for _, ip := range ips {
+		a := Address{Address: ip}
+		for _, gw := range gateways {
+			if (gw.To4() != nil) == (ip.IP.To4() != nil) {
+				a.Gateway = gw
+				break
+			}
+		}
+		addresses = append(addresses, a)
+	}
+	return addresses
+}
+
+func validateAddresses(addresses []Address) error {
+	if len(addresses) == 0 {
+		return types.NewError(types.ErrInvalidNetworkConfig, "no addresses to assign", "")
+	}
+
+	// the result has room for one address of each family
+	families := map[bool]bool{}
+	for _, a := range addresses {
+		v4 := a.Address.IP.To4() != nil
+		if families[v4] {
+			return types.NewError(types.ErrInvalidNetworkConfig, "more than one address of the same family", (*net.IPNet)(&a.Address).String())
+		}
+		families[v4] = true
+		if a.Gateway != nil && (a.Gateway.To4() != nil) != v4 {
+			return types.NewError(types.ErrInvalidNetworkConfig, "gateway of a different family than its address", a.Gateway.String())
+		}
+	}
+	return nil
+}
+
+func cmdAdd(args *skel.CmdArgs) error {
+	// static only reads its configuration
+	args.DropCapabilities()
+
+	n, addresses, err := loadConfig(args.StdinData, args.Args)
+	if err != nil {
+		return err
+	}
+
+	r := &types.Result{
+		DNS: n.IPAM.DNS,
+	}
+	for _, a := range addresses {
+		ipConf := &types.IPConfig{
+			IP:      net.IPNet(a.Address),
+			Gateway: a.Gateway,
+		}
+		v4 := a.Address.IP.To4() != nil
+		// routes go with the address of their family
+		for _, route := range n.IPAM.Routes {
+			if (route.Dst.IP.To4() != nil) == v4 {
+				ipConf.Routes = append(ipConf.Routes, route)
+			}
+		}
+		if v4 {
+			r.IP4 = ipConf
+		} else {
+			r.IP6 = ipConf
+		}
+	}
+	return version.PrintResult(r, n.CNIVersion)
+}
+
+func cmdDel(args *skel.CmdArgs) error {
+	// nothing was allocated, so there is nothing to release
+	return nil
+}
+
+// Main runs the plugin for the command in the environment
+func Main() {
+	skel.PluginMainWithSchema(cmdAdd, cmdDel, schema.ForNetConf(&Net{}))
+}
@@ -0,0 +1,106 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/cni/pkg/testutils"
+	"github.com/containernetworking/cni/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("static", func() {
+	const conf = `{
+		"name": "mynet",
+		"ipam": {
+			"type": "static",
+			"addresses": [
+				{"address": "10.1.2.3/24", "gateway": "10.1.2.1"},
+				{"address": "2001:db8::3/64"}
+			],
+			"routes": [
+				{"dst": "0.0.0.0/0"},
+				{"dst": "192.168.0.0/16", "gw": "10.1.2.254"},
+				{"dst": "::/0", "gw": "2001:db8::1"}
+			],
+			"dns": {"nameservers": ["10.1.2.1"], "search": ["example.com"]}
+		}
+	}`
+
+	add := func(args, stdin string) (*types.Result, error) {
+		return testutils.CmdAddWithResult("", "eth0", func() error {
+			return cmdAdd(&skel.CmdArgs{ContainerID: "c1", IfName: "eth0", Args: args, StdinData: []byte(stdin)})
+		})
+	}
+
+	It("assigns the configured addresses, routes and DNS settings", func() {
+		result, err := add("", conf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(testutils.HaveIP4("10.1.2.3/24"))
+		Expect(result).To(testutils.HaveGateway4("10.1.2.1"))
+		Expect(result).To(testutils.HaveRoute4("0.0.0.0/0", ""))
+		Expect(result).To(testutils.HaveRoute4("192.168.0.0/16", "10.1.2.254"))
+		Expect(result.IP4.Routes).To(HaveLen(2))
+		Expect(result).To(testutils.HaveIP6("2001:db8::3/64"))
+		Expect(result.IP6.Gateway).To(BeNil())
+		Expect(result.IP6.Routes).To(HaveLen(1))
+		Expect(result.IP6.Routes[0].GW.String()).To(Equal("2001:db8::1"))
+		Expect(result.DNS).To(Equal(types.DNS{Nameservers: []string{"10.1.2.1"}, Search: []string{"example.com"}}))
+	})
+
+	It("prefers the addresses of CNI_ARGS", func() {
+		result, err := add("IgnoreUnknown=1;IP=10.1.2.9/24;GATEWAY=10.1.2.1;K8S_POD_NAME=pod", conf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(testutils.HaveIP4("10.1.2.9/24"))
+		Expect(result).To(testutils.HaveGateway4("10.1.2.1"))
+		Expect(result.IP6).To(BeNil())
+	})
+
+	It("prefers the addresses of the ips capability", func() {
+		result, err := add("IP=10.1.2.9/24", `{
+			"name": "mynet",
+			"ipam": {"type": "static"},
+			"runtimeConfig": {"ips": ["2001:db8::9/64", "10.1.2.10/24"]}
+		}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(testutils.HaveIP4("10.1.2.10/24"))
+		Expect(result).To(testutils.HaveIP6("2001:db8::9/64"))
+	})
+
+	It("releases nothing", func() {
+		Expect(testutils.CmdDelWithResult("", "eth0", func() error {
+			return cmdDel(&skel.CmdArgs{ContainerID: "c1", IfName: "eth0", StdinData: []byte(conf)})
+		})).To(Succeed())
+	})
+
+	It("reports the class of errors in their code", func() {
+		code := func(args, conf string) uint {
+			_, err := add(args, conf)
+			Expect(err).To(HaveOccurred())
+			Expect(err).To(BeAssignableToTypeOf(&types.Error{}))
+			return err.(*types.Error).Code
+		}
+
+		Expect(code("", `{"name": `)).To(Equal(types.ErrDecodingFailure))
+		Expect(code("", `{"name": "mynet"}`)).To(Equal(types.ErrInvalidNetworkConfig))
+		Expect(code("", `{"name": "mynet", "ipam": {"type": "static"}}`)).To(Equal(types.ErrInvalidNetworkConfig))
+		Expect(code("IP=10.1.2.3/24,10.1.2.4/24", conf)).To(Equal(types.ErrInvalidNetworkConfig))
+		Expect(code("IP=10.1.2.3", conf)).To(Equal(types.ErrInvalidEnvironmentVariables))
+		Expect(code("", `{"name": "mynet", "ipam": {"type": "static"}, "runtimeConfig": {"ips": ["banana"]}}`)).To(Equal(types.ErrInvalidNetworkConfig))
+		Expect(code("", `{"name": "mynet", "ipam": {"type": "static", "addresses": [{"address": "10.1.2.3/24", "gateway": "2001:db8::1"}]}}`)).To(Equal(types.ErrInvalidNetworkConfig))
+	})
+})
@@ -12,9 +12,10 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package plugin
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -25,9 +26,12 @@ import (
 	"github.com/containernetworking/cni/pkg/ip"
 	"github.com/containernetworking/cni/pkg/ipam"
 	"github.com/containernetworking/cni/pkg/ns"
+	"github.com/containernetworking/cni/pkg/retry"
+	"github.com/containernetworking/cni/pkg/schema"
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
 	"github.com/containernetworking/cni/pkg/utils"
+	"github.com/containernetworking/cni/pkg/version"
 	"github.com/vishvananda/netlink"
 )
 
@@ -56,13 +60,17 @@ func loadNetConf(bytes []byte) (*NetConf, error) {
 		BrName: defaultBrName,
 	}
 	if err := json.Unmarshal(bytes, n); err != nil {
-		return nil, fmt.Errorf("failed to load netconf: %v", err)
+		return nil, types.NewError(types.ErrDecodingFailure, "failed to load netconf", err.Error())
 	}
 	return n, nil
 }
 
 func ensureBridgeAddr(br *netlink.Bridge, ipn *net.IPNet, forceAddress bool) error {
-	addrs, err := netlink.AddrList(br, syscall.AF_INET)
+	family := netlink.FAMILY_V4
+	if ipn.IP.To4() == nil {
+		family = netlink.FAMILY_V6
+	}
+	addrs, err := netlink.AddrList(br, family)
 	if err != nil && err != syscall.ENOENT {
 		return fmt.Errorf("could not get list of IP addresses: %v", err)
 	}
@@ -71,6 +79,11 @@ func ensureBridgeAddr(br *netlink.Bridge, ipn *net.IPNet, forceAddress bool) err
 	if len(addrs) > 0 {
 		ipnStr := ipn.String()
 		for _, a := range addrs {
+			// the kernel adds an IPv6 link-local address to every link
+			if a.IP.IsLinkLocalUnicast() {
+				continue
+			}
+
 			// string comp is actually easiest for doing IPNet comps
 			if a.IPNet.String() == ipnStr {
 				return nil
@@ -82,7 +95,7 @@ func ensureBridgeAddr(br *netlink.Bridge, ipn *net.IPNet, forceAddress bool) err
 					return err
 				}
 			} else {
-				return fmt.Errorf("%q already has an IP address different from %v", br.Name, ipn.String())
+				return types.NewError(types.ErrInvalidNetworkConfig, fmt.Sprintf("%q already has an IP address different from %v", br.Name, ipn.String()), "")
 			}
 		}
 	}
@@ -112,6 +125,10 @@ func deleteBridgeAddr(br *netlink.Bridge, ipn *net.IPNet) error {
 	return nil
 }
 
+func notABridge(name string) error {
+	return types.NewError(types.ErrInvalidNetworkConfig, fmt.Sprintf("%q already exists but is not a bridge", name), "")
+}
+
 func bridgeByName(name string) (*netlink.Bridge, error) {
 	l, err := netlink.LinkByName(name)
 	if err != nil {
@@ -119,7 +136,7 @@ func bridgeByName(name string) (*netlink.Bridge, error) {
 	}
 	br, ok := l.(*netlink.Bridge)
 	if !ok {
-		return nil, fmt.Errorf("%q already exists but is not a bridge", name)
+		return nil, notABridge(name)
 	}
 	return br, nil
 }
@@ -137,19 +154,34 @@ func ensureBridge(brName string, mtu int) (*netlink.Bridge, error) {
 		},
 	}
 
-	if err := netlink.LinkAdd(br); err != nil {
+	err := retry.Do(context.Background(), retry.DefaultNetlink, func() error {
+		err := netlink.LinkAdd(br)
+		if err == nil {
+			return nil
+		}
 		if err != syscall.EEXIST {
-			return nil, fmt.Errorf("could not add %q: %v", brName, err)
+			return retry.Permanent(fmt.Errorf("could not add %q: %v", brName, err))
 		}
 
-		// it's ok if the device already exists as long as config is similar
-		br, err = bridgeByName(brName)
+		// it's ok if the device already exists as long as config is
+		// similar. If it can't be found anymore, it was deleted since
+		// and is added again.
+		l, err := netlink.LinkByName(brName)
 		if err != nil {
-			return nil, err
+			return fmt.Errorf("could not lookup %q: %v", brName, err)
 		}
+		existing, ok := l.(*netlink.Bridge)
+		if !ok {
+			return retry.Permanent(notABridge(brName))
+		}
+		br = existing
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if err := netlink.LinkSetUp(br); err != nil {
+	if err := retry.OnTransient(func() error { return netlink.LinkSetUp(br) }); err != nil {
 		return nil, err
 	}
 
@@ -180,7 +212,8 @@ func setupVeth(netns ns.NetNS, br *netlink.Bridge, ifName string, mtu int, hairp
 	}
 
 	// connect host veth end to the bridge
-	if err = netlink.LinkSetMaster(hostVeth, br); err != nil {
+	err = retry.OnTransient(func() error { return netlink.LinkSetMaster(hostVeth, br) })
+	if err != nil {
 		return fmt.Errorf("failed to connect %q to bridge %v: %v", hostVethName, br.Attrs().Name, err)
 	}
 
@@ -192,6 +225,40 @@ func setupVeth(netns ns.NetNS, br *netlink.Bridge, ifName string, mtu int, hairp
 	return nil
 }
 
+// addDefaultRoute adds a default route of the family of ipc via its
+// gateway, unless IPAM sets one via another gateway
+func addDefaultRoute(ipc *types.IPConfig) error {
+	defaultNet := &net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)}
+	if ipc.IP.IP.To4() == nil {
+		defaultNet = &net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)}
+	}
+
+	for _, route := range ipc.Routes {
+		if defaultNet.String() == route.Dst.String() {
+			if route.GW != nil && !route.GW.Equal(ipc.Gateway) {
+				return fmt.Errorf(
+					"isDefaultGateway ineffective because IPAM sets default route via %q",
+					route.GW,
+				)
+			}
+		}
+	}
+
+	ipc.Routes = append(
+		ipc.Routes,
+		types.Route{Dst: *defaultNet, GW: ipc.Gateway},
+	)
+	return nil
+}
+
+// resultIP returns the IP of ipc, if any
+func resultIP(ipc *types.IPConfig) net.IP {
+	if ipc == nil {
+		return nil
+	}
+	return ipc.IP.IP
+}
+
 func calcGatewayIP(ipn *net.IPNet) net.IP {
 	nid := ipn.IP.Mask(ipn.Mask)
 	return ip.NextIP(nid)
@@ -224,7 +291,7 @@ func cmdAdd(args *skel.CmdArgs) error {
 
 	netns, err := ns.GetNS(args.Netns)
 	if err != nil {
-		return fmt.Errorf("failed to open netns %q: %v", args.Netns, err)
+		return types.NewError(types.ErrUnknownContainer, fmt.Sprintf("failed to open netns %q", args.Netns), err.Error())
 	}
 	defer netns.Close()
 
@@ -232,53 +299,42 @@ func cmdAdd(args *skel.CmdArgs) error {
 		return err
 	}
 
-	// run the IPAM plugin and get back the config to apply
-	result, err := ipam.ExecAdd(n.IPAM.Type, args.StdinData)
+	// run the IPAM plugin and get back the config to apply, handing it
+	// the result of the earlier plugins of a chain
+	result, err := ipam.ExecAddWithPrevResult(n.IPAM.Type, args.StdinData, n.PrevResult)
 	if err != nil {
 		return err
 	}
 
-	// TODO: make this optional when IPv6 is supported
-	if result.IP4 == nil {
-		return errors.New("IPAM plugin returned missing IPv4 config")
+	if result.IP4 == nil && result.IP6 == nil {
+		return errors.New("IPAM plugin returned neither IPv4 nor IPv6 config")
 	}
-
-	if result.IP4.Gateway == nil && n.IsGW {
-		result.IP4.Gateway = calcGatewayIP(&result.IP4.IP)
+	ipConfigs := []*types.IPConfig{}
+	for _, ipc := range []*types.IPConfig{result.IP4, result.IP6} {
+		if ipc == nil {
+			continue
+		}
+		if ipc.Gateway == nil && n.IsGW {
+			ipc.Gateway = calcGatewayIP(&ipc.IP)
+		}
+		ipConfigs = append(ipConfigs, ipc)
 	}
 
 	if err := netns.Do(func(_ ns.NetNS) error {
 		// set the default gateway if requested
 		if n.IsDefaultGW {
-			_, defaultNet, err := net.ParseCIDR("0.0.0.0/0")
-			if err != nil {
-				return err
-			}
-
-			for _, route := range result.IP4.Routes {
-				if defaultNet.String() == route.Dst.String() {
-					if route.GW != nil && !route.GW.Equal(result.IP4.Gateway) {
-						return fmt.Errorf(
-							"isDefaultGateway ineffective because IPAM sets default route via %q",
-							route.GW,
-						)
-					}
+			for _, ipc := range ipConfigs {
+				if err := addDefaultRoute(ipc); err != nil {
+					return err
 				}
 			}
-
-			result.IP4.Routes = append(
-				result.IP4.Routes,
-				types.Route{Dst: *defaultNet, GW: result.IP4.Gateway},
-			)
-
-			// TODO: IPV6
 		}
 
 		if err := ipam.ConfigureIface(args.IfName, result); err != nil {
 			return err
 		}
 
-		if err := ip.SetHWAddrByIP(args.IfName, result.IP4.IP.IP, nil /* TODO IPv6 */); err != nil {
+		if err := ip.SetHWAddrByIP(args.IfName, resultIP(result.IP4), resultIP(result.IP6)); err != nil {
 			return err
 		}
 
@@ -288,25 +344,36 @@ func cmdAdd(args *skel.CmdArgs) error {
 	}
 
 	if n.IsGW {
-		gwn := &net.IPNet{
-			IP:   result.IP4.Gateway,
-			Mask: result.IP4.IP.Mask,
-		}
+		var gw4, gw6 net.IP
+		for _, ipc := range ipConfigs {
+			gwn := &net.IPNet{
+				IP:   ipc.Gateway,
+				Mask: ipc.IP.Mask,
+			}
 
-		if err = ensureBridgeAddr(br, gwn, n.ForceAddress); err != nil {
-			return err
-		}
+			if err = ensureBridgeAddr(br, gwn, n.ForceAddress); err != nil {
+				return err
+			}
 
-		if err := ip.SetHWAddrByIP(n.BrName, gwn.IP, nil /* TODO IPv6 */); err != nil {
-			return err
+			if ipc == result.IP4 {
+				gw4 = ipc.Gateway
+				err = ip.EnableIP4Forward()
+			} else {
+				gw6 = ipc.Gateway
+				err = ip.EnableIP6Forward()
+			}
+			if err != nil {
+				return fmt.Errorf("failed to enable forwarding: %v", err)
+			}
 		}
 
-		if err := ip.EnableIP4Forward(); err != nil {
-			return fmt.Errorf("failed to enable forwarding: %v", err)
+		if err := ip.SetHWAddrByIP(n.BrName, gw4, gw6); err != nil {
+			return err
 		}
 	}
 
-	if n.IPMasq {
+	// only IPv4 is masqueraded, there is no NAT for IPv6
+	if n.IPMasq && result.IP4 != nil {
 		chain := utils.FormatChainName(n.Name, args.ContainerID)
 		comment := utils.FormatComment(n.Name, args.ContainerID)
 		if err = ip.SetupIPMasq(ip.Network(&result.IP4.IP), chain, comment); err != nil {
@@ -314,8 +381,10 @@ func cmdAdd(args *skel.CmdArgs) error {
 		}
 	}
 
-	result.DNS = n.DNS
-	return result.Print()
+	// the interfaces are set up, nothing below needs privileges
+	args.DropCapabilities()
+	result.DNS = types.MergeDNS(n.DNS, result.DNS)
+	return version.PrintResult(result, n.CNIVersion)
 }
 
 func cmdDel(args *skel.CmdArgs) error {
@@ -332,20 +401,20 @@ func cmdDel(args *skel.CmdArgs) error {
 		return nil
 	}
 
-	var ipn *net.IPNet
+	var ipns []*net.IPNet
 	err = ns.WithNetNSPath(args.Netns, func(_ ns.NetNS) error {
 		var err error
-		ipn, err = ip.DelLinkByNameAddr(args.IfName, netlink.FAMILY_V4)
+		ipns, err = ip.DelLinkByNameAddrs(args.IfName, netlink.FAMILY_V4)
 		return err
 	})
 	if err != nil {
 		return err
 	}
 
-	if n.IPMasq {
+	if n.IPMasq && len(ipns) > 0 {
 		chain := utils.FormatChainName(n.Name, args.ContainerID)
 		comment := utils.FormatComment(n.Name, args.ContainerID)
-		if err = ip.TeardownIPMasq(ipn, chain, comment); err != nil {
+		if err = ip.TeardownIPMasq(ipns[0], chain, comment); err != nil {
 			return err
 		}
 	}
@@ -353,6 +422,7 @@ func cmdDel(args *skel.CmdArgs) error {
 	return nil
 }
 
-func main() {
-	skel.PluginMain(cmdAdd, cmdDel)
+// Main runs the plugin for the command in the environment
+func Main() {
+	skel.PluginMainWithSchema(cmdAdd, cmdDel, schema.ForNetConf(&NetConf{}))
 }
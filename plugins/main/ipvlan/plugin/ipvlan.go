@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package plugin
 
 import (
 	"encoding/json"
@@ -23,8 +23,10 @@ import (
 	"github.com/containernetworking/cni/pkg/ip"
 	"github.com/containernetworking/cni/pkg/ipam"
 	"github.com/containernetworking/cni/pkg/ns"
+	"github.com/containernetworking/cni/pkg/schema"
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/version"
 	"github.com/vishvananda/netlink"
 )
 
@@ -45,10 +47,10 @@ func init() {
 func loadConf(bytes []byte) (*NetConf, error) {
 	n := &NetConf{}
 	if err := json.Unmarshal(bytes, n); err != nil {
-		return nil, fmt.Errorf("failed to load netconf: %v", err)
+		return nil, types.NewError(types.ErrDecodingFailure, "failed to load netconf", err.Error())
 	}
 	if n.Master == "" {
-		return nil, fmt.Errorf(`"master" field is required. It specifies the host interface name to virtualize`)
+		return nil, types.NewError(types.ErrInvalidNetworkConfig, `"master" field is required. It specifies the host interface name to virtualize`, "")
 	}
 	return n, nil
 }
@@ -60,7 +62,7 @@ func modeFromString(s string) (netlink.IPVlanMode, error) {
 	case "l3":
 		return netlink.IPVLAN_MODE_L3, nil
 	default:
-		return 0, fmt.Errorf("unknown ipvlan mode: %q", s)
+		return 0, types.NewError(types.ErrInvalidNetworkConfig, fmt.Sprintf("unknown ipvlan mode: %q", s), "")
 	}
 }
 
@@ -72,7 +74,7 @@ func createIpvlan(conf *NetConf, ifName string, netns ns.NetNS) error {
 
 	m, err := netlink.LinkByName(conf.Master)
 	if err != nil {
-		return fmt.Errorf("failed to lookup master %q: %v", conf.Master, err)
+		return types.NewError(types.ErrInvalidNetworkConfig, fmt.Sprintf("failed to lookup master %q", conf.Master), err.Error())
 	}
 
 	// due to kernel bug we have to create with tmpname or it might
@@ -113,7 +115,7 @@ func cmdAdd(args *skel.CmdArgs) error {
 
 	netns, err := ns.GetNS(args.Netns)
 	if err != nil {
-		return fmt.Errorf("failed to open netns %q: %v", args.Netns, err)
+		return types.NewError(types.ErrUnknownContainer, fmt.Sprintf("failed to open netns %q", args.Netns), err.Error())
 	}
 	defer netns.Close()
 
@@ -126,8 +128,8 @@ func cmdAdd(args *skel.CmdArgs) error {
 	if err != nil {
 		return err
 	}
-	if result.IP4 == nil {
-		return errors.New("IPAM plugin returned missing IPv4 config")
+	if result.IP4 == nil && result.IP6 == nil {
+		return errors.New("IPAM plugin returned neither IPv4 nor IPv6 config")
 	}
 
 	err = netns.Do(func(_ ns.NetNS) error {
@@ -137,8 +139,10 @@ func cmdAdd(args *skel.CmdArgs) error {
 		return err
 	}
 
-	result.DNS = n.DNS
-	return result.Print()
+	// the interfaces are set up, nothing below needs privileges
+	args.DropCapabilities()
+	result.DNS = types.MergeDNS(n.DNS, result.DNS)
+	return version.PrintResult(result, n.CNIVersion)
 }
 
 func cmdDel(args *skel.CmdArgs) error {
@@ -170,6 +174,7 @@ func renameLink(curName, newName string) error {
 	return netlink.LinkSetName(link, newName)
 }
 
-func main() {
-	skel.PluginMain(cmdAdd, cmdDel)
+// Main runs the plugin for the command in the environment
+func Main() {
+	skel.PluginMainWithSchema(cmdAdd, cmdDel, schema.ForNetConf(&NetConf{}))
 }
@@ -12,12 +12,16 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package plugin
 
 import (
+	"encoding/json"
+
 	"github.com/containernetworking/cni/pkg/ns"
+	"github.com/containernetworking/cni/pkg/schema"
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/version"
 	"github.com/vishvananda/netlink"
 )
 
@@ -40,8 +44,12 @@ func cmdAdd(args *skel.CmdArgs) error {
 		return err // not tested
 	}
 
+	// loopback ignores its config but for the version to reply in
+	conf := types.NetConf{}
+	json.Unmarshal(args.StdinData, &conf)
+
 	result := types.Result{}
-	return result.Print()
+	return version.PrintResult(&result, conf.CNIVersion)
 }
 
 func cmdDel(args *skel.CmdArgs) error {
@@ -66,6 +74,7 @@ func cmdDel(args *skel.CmdArgs) error {
 	return nil
 }
 
-func main() {
-	skel.PluginMain(cmdAdd, cmdDel)
+// Main runs the plugin for the command in the environment
+func Main() {
+	skel.PluginMainWithSchema(cmdAdd, cmdDel, schema.ForNetConf(&types.NetConf{}))
 }
@@ -12,13 +12,14 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main_test
+package plugin_test
 
 import (
 	"fmt"
 	"net"
 	"os/exec"
 	"strings"
+	"syscall"
 
 	"github.com/containernetworking/cni/pkg/ns"
 	. "github.com/onsi/ginkgo"
@@ -97,4 +98,34 @@ var _ = Describe("Loopback", func() {
 			Expect(lo.Flags & net.FlagUp).NotTo(Equal(net.FlagUp))
 		})
 	})
+
+	Context("when given the PID of a process in a network namespace", func() {
+		It("sets the lo device of its namespace to UP", func() {
+			sandbox := exec.Command("sleep", "60")
+			sandbox.SysProcAttr = &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWNET}
+			Expect(sandbox.Start()).To(Succeed())
+			defer func() {
+				sandbox.Process.Kill()
+				sandbox.Wait()
+			}()
+
+			command.Env = append(environ,
+				"CNI_COMMAND=ADD",
+				fmt.Sprintf("CNI_NETNS=pid:%d", sandbox.Process.Pid),
+			)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+
+			var lo *net.Interface
+			err = ns.WithNetNSPath(fmt.Sprintf("%d", sandbox.Process.Pid), func(ns.NetNS) error {
+				var err error
+				lo, err = net.InterfaceByName("lo")
+				return err
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(lo.Flags & net.FlagUp).To(Equal(net.FlagUp))
+		})
+	})
 })
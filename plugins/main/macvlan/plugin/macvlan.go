@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package plugin
 
 import (
 	"encoding/json"
@@ -23,9 +23,11 @@ import (
 	"github.com/containernetworking/cni/pkg/ip"
 	"github.com/containernetworking/cni/pkg/ipam"
 	"github.com/containernetworking/cni/pkg/ns"
+	"github.com/containernetworking/cni/pkg/schema"
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
 	"github.com/containernetworking/cni/pkg/utils/sysctl"
+	"github.com/containernetworking/cni/pkg/version"
 	"github.com/vishvananda/netlink"
 )
 
@@ -50,10 +52,10 @@ func init() {
 func loadConf(bytes []byte) (*NetConf, error) {
 	n := &NetConf{}
 	if err := json.Unmarshal(bytes, n); err != nil {
-		return nil, fmt.Errorf("failed to load netconf: %v", err)
+		return nil, types.NewError(types.ErrDecodingFailure, "failed to load netconf", err.Error())
 	}
 	if n.Master == "" {
-		return nil, fmt.Errorf(`"master" field is required. It specifies the host interface name to virtualize`)
+		return nil, types.NewError(types.ErrInvalidNetworkConfig, `"master" field is required. It specifies the host interface name to virtualize`, "")
 	}
 	return n, nil
 }
@@ -69,7 +71,7 @@ func modeFromString(s string) (netlink.MacvlanMode, error) {
 	case "passthru":
 		return netlink.MACVLAN_MODE_PASSTHRU, nil
 	default:
-		return 0, fmt.Errorf("unknown macvlan mode: %q", s)
+		return 0, types.NewError(types.ErrInvalidNetworkConfig, fmt.Sprintf("unknown macvlan mode: %q", s), "")
 	}
 }
 
@@ -81,7 +83,7 @@ func createMacvlan(conf *NetConf, ifName string, netns ns.NetNS) error {
 
 	m, err := netlink.LinkByName(conf.Master)
 	if err != nil {
-		return fmt.Errorf("failed to lookup master %q: %v", conf.Master, err)
+		return types.NewError(types.ErrInvalidNetworkConfig, fmt.Sprintf("failed to lookup master %q", conf.Master), err.Error())
 	}
 
 	// due to kernel bug we have to create with tmpName or it might
@@ -131,7 +133,7 @@ func cmdAdd(args *skel.CmdArgs) error {
 
 	netns, err := ns.GetNS(args.Netns)
 	if err != nil {
-		return fmt.Errorf("failed to open netns %q: %v", netns, err)
+		return types.NewError(types.ErrUnknownContainer, fmt.Sprintf("failed to open netns %q", netns), err.Error())
 	}
 	defer netns.Close()
 
@@ -144,13 +146,15 @@ func cmdAdd(args *skel.CmdArgs) error {
 	if err != nil {
 		return err
 	}
-	if result.IP4 == nil {
-		return errors.New("IPAM plugin returned missing IPv4 config")
+	if result.IP4 == nil && result.IP6 == nil {
+		return errors.New("IPAM plugin returned neither IPv4 nor IPv6 config")
 	}
 
 	err = netns.Do(func(_ ns.NetNS) error {
-		if err := ip.SetHWAddrByIP(args.IfName, result.IP4.IP.IP, nil /* TODO IPv6 */); err != nil {
-			return err
+		if result.IP4 != nil {
+			if err := ip.SetHWAddrByIP(args.IfName, result.IP4.IP.IP, nil); err != nil {
+				return err
+			}
 		}
 
 		return ipam.ConfigureIface(args.IfName, result)
@@ -159,8 +163,10 @@ func cmdAdd(args *skel.CmdArgs) error {
 		return err
 	}
 
-	result.DNS = n.DNS
-	return result.Print()
+	// the interfaces are set up, nothing below needs privileges
+	args.DropCapabilities()
+	result.DNS = types.MergeDNS(n.DNS, result.DNS)
+	return version.PrintResult(result, n.CNIVersion)
 }
 
 func cmdDel(args *skel.CmdArgs) error {
@@ -192,6 +198,7 @@ func renameLink(curName, newName string) error {
 	return netlink.LinkSetName(link, newName)
 }
 
-func main() {
-	skel.PluginMain(cmdAdd, cmdDel)
+// Main runs the plugin for the command in the environment
+func Main() {
+	skel.PluginMainWithSchema(cmdAdd, cmdDel, schema.ForNetConf(&NetConf{}))
 }
@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package plugin
 
 import (
 	"encoding/json"
@@ -27,9 +27,11 @@ import (
 	"github.com/containernetworking/cni/pkg/ip"
 	"github.com/containernetworking/cni/pkg/ipam"
 	"github.com/containernetworking/cni/pkg/ns"
+	"github.com/containernetworking/cni/pkg/schema"
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
 	"github.com/containernetworking/cni/pkg/utils"
+	"github.com/containernetworking/cni/pkg/version"
 )
 
 func init() {
@@ -56,6 +58,7 @@ func setupContainerVeth(netns, ifName string, mtu int, pr *types.Result) (string
 	// "192.168.3.0/24 dev $ifName" route that was automatically added. Then we add
 	// "192.168.3.1/32 dev $ifName" and "192.168.3.0/24 via 192.168.3.1 dev $ifName".
 	// In other words we force all traffic to ARP via the gateway except for GW itself.
+	// IPv6 is set up the same way, with /128 instead of /32.
 
 	var hostVethName string
 	err := ns.WithNetNSPath(netns, func(hostNS ns.NetNS) error {
@@ -66,7 +69,10 @@ func setupContainerVeth(netns, ifName string, mtu int, pr *types.Result) (string
 
 		hostNS.Do(func(_ ns.NetNS) error {
 			hostVethName = hostVeth.Attrs().Name
-			if err := ip.SetHWAddrByIP(hostVethName, pr.IP4.IP.IP, nil /* TODO IPv6 */); err != nil {
+			if pr.IP4 == nil {
+				return nil
+			}
+			if err := ip.SetHWAddrByIP(hostVethName, pr.IP4.IP.IP, nil); err != nil {
 				return fmt.Errorf("failed to set hardware addr by IP: %v", err)
 			}
 
@@ -82,53 +88,85 @@ func setupContainerVeth(netns, ifName string, mtu int, pr *types.Result) (string
 			return fmt.Errorf("failed to look up %q: %v", ifName, err)
 		}
 
-		if err := ip.SetHWAddrByIP(contVeth.Attrs().Name, pr.IP4.IP.IP, nil /* TODO IPv6 */); err != nil {
-			return fmt.Errorf("failed to set hardware addr by IP: %v", err)
+		if pr.IP4 != nil {
+			if err := ip.SetHWAddrByIP(contVeth.Attrs().Name, pr.IP4.IP.IP, nil); err != nil {
+				return fmt.Errorf("failed to set hardware addr by IP: %v", err)
+			}
+		}
+
+		for _, ipc := range ipConfigs(pr) {
+			if err := setupContainerRoutes(contVeth, ipc); err != nil {
+				return err
+			}
 		}
 
-		// Delete the route that was automatically added
-		route := netlink.Route{
+		return nil
+	})
+	return hostVethName, err
+}
+
+// setupContainerRoutes replaces the route to the subnet of ipc that was
+// added automatically by a route via its gateway
+func setupContainerRoutes(contVeth netlink.Link, ipc *types.IPConfig) error {
+	_, bits := ipc.IP.Mask.Size()
+	subnet := &net.IPNet{
+		IP:   ipc.IP.IP.Mask(ipc.IP.Mask),
+		Mask: ipc.IP.Mask,
+	}
+
+	// Delete the route that was automatically added
+	route := netlink.Route{
+		LinkIndex: contVeth.Attrs().Index,
+		Dst:       subnet,
+		Scope:     netlink.SCOPE_NOWHERE,
+	}
+
+	if err := netlink.RouteDel(&route); err != nil {
+		return fmt.Errorf("failed to delete route %v: %v", route, err)
+	}
+
+	// IPv6 addresses are tentative until duplicate address detection
+	// finishes, and can't be used as a route's source before
+	src := ipc.IP.IP
+	if bits == 128 {
+		src = nil
+	}
+
+	for _, r := range []netlink.Route{
+		netlink.Route{
 			LinkIndex: contVeth.Attrs().Index,
 			Dst: &net.IPNet{
-				IP:   pr.IP4.IP.IP.Mask(pr.IP4.IP.Mask),
-				Mask: pr.IP4.IP.Mask,
+				IP:   ipc.Gateway,
+				Mask: net.CIDRMask(bits, bits),
 			},
-			Scope: netlink.SCOPE_NOWHERE,
+			Scope: netlink.SCOPE_LINK,
+			Src:   src,
+		},
+		netlink.Route{
+			LinkIndex: contVeth.Attrs().Index,
+			Dst:       subnet,
+			Scope:     netlink.SCOPE_UNIVERSE,
+			Gw:        ipc.Gateway,
+			Src:       src,
+		},
+	} {
+		if err := netlink.RouteAdd(&r); err != nil {
+			return fmt.Errorf("failed to add route %v: %v", r, err)
 		}
+	}
 
-		if err := netlink.RouteDel(&route); err != nil {
-			return fmt.Errorf("failed to delete route %v: %v", route, err)
-		}
+	return nil
+}
 
-		for _, r := range []netlink.Route{
-			netlink.Route{
-				LinkIndex: contVeth.Attrs().Index,
-				Dst: &net.IPNet{
-					IP:   pr.IP4.Gateway,
-					Mask: net.CIDRMask(32, 32),
-				},
-				Scope: netlink.SCOPE_LINK,
-				Src:   pr.IP4.IP.IP,
-			},
-			netlink.Route{
-				LinkIndex: contVeth.Attrs().Index,
-				Dst: &net.IPNet{
-					IP:   pr.IP4.IP.IP.Mask(pr.IP4.IP.Mask),
-					Mask: pr.IP4.IP.Mask,
-				},
-				Scope: netlink.SCOPE_UNIVERSE,
-				Gw:    pr.IP4.Gateway,
-				Src:   pr.IP4.IP.IP,
-			},
-		} {
-			if err := netlink.RouteAdd(&r); err != nil {
-				return fmt.Errorf("failed to add route %v: %v", r, err)
-			}
+// ipConfigs returns the IPv4 and IPv6 configs of result, whichever exist
+func ipConfigs(result *types.Result) []*types.IPConfig {
+	ipcs := []*types.IPConfig{}
+	for _, ipc := range []*types.IPConfig{result.IP4, result.IP6} {
+		if ipc != nil {
+			ipcs = append(ipcs, ipc)
 		}
-
-		return nil
-	})
-	return hostVethName, err
+	}
+	return ipcs
 }
 
 func setupHostVeth(vethName string, ipConf *types.IPConfig) error {
@@ -138,10 +176,10 @@ func setupHostVeth(vethName string, ipConf *types.IPConfig) error {
 		return fmt.Errorf("failed to lookup %q: %v", vethName, err)
 	}
 
-	// TODO(eyakubovich): IPv6
+	_, bits := ipConf.IP.Mask.Size()
 	ipn := &net.IPNet{
 		IP:   ipConf.Gateway,
-		Mask: net.CIDRMask(32, 32),
+		Mask: net.CIDRMask(bits, bits),
 	}
 	addr := &netlink.Addr{IPNet: ipn, Label: ""}
 	if err = netlink.AddrAdd(veth, addr); err != nil {
@@ -150,7 +188,7 @@ func setupHostVeth(vethName string, ipConf *types.IPConfig) error {
 
 	ipn = &net.IPNet{
 		IP:   ipConf.IP.IP,
-		Mask: net.CIDRMask(32, 32),
+		Mask: net.CIDRMask(bits, bits),
 	}
 	// dst happens to be the same as IP/net of host veth
 	if err = ip.AddHostRoute(ipn, nil, veth); err != nil && !os.IsExist(err) {
@@ -163,20 +201,27 @@ func setupHostVeth(vethName string, ipConf *types.IPConfig) error {
 func cmdAdd(args *skel.CmdArgs) error {
 	conf := NetConf{}
 	if err := json.Unmarshal(args.StdinData, &conf); err != nil {
-		return fmt.Errorf("failed to load netconf: %v", err)
-	}
-
-	if err := ip.EnableIP4Forward(); err != nil {
-		return fmt.Errorf("failed to enable forwarding: %v", err)
+		return types.NewError(types.ErrDecodingFailure, "failed to load netconf", err.Error())
 	}
 
-	// run the IPAM plugin and get back the config to apply
-	result, err := ipam.ExecAdd(conf.IPAM.Type, args.StdinData)
+	// run the IPAM plugin and get back the config to apply, handing it
+	// the result of the earlier plugins of a chain
+	result, err := ipam.ExecAddWithPrevResult(conf.IPAM.Type, args.StdinData, conf.PrevResult)
 	if err != nil {
 		return err
 	}
-	if result.IP4 == nil {
-		return errors.New("IPAM plugin returned missing IPv4 config")
+	if result.IP4 == nil && result.IP6 == nil {
+		return errors.New("IPAM plugin returned neither IPv4 nor IPv6 config")
+	}
+
+	if result.IP4 != nil {
+		err = ip.EnableIP4Forward()
+	}
+	if err == nil && result.IP6 != nil {
+		err = ip.EnableIP6Forward()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to enable forwarding: %v", err)
 	}
 
 	hostVethName, err := setupContainerVeth(args.Netns, args.IfName, conf.MTU, result)
@@ -184,11 +229,14 @@ func cmdAdd(args *skel.CmdArgs) error {
 		return err
 	}
 
-	if err = setupHostVeth(hostVethName, result.IP4); err != nil {
-		return err
+	for _, ipc := range ipConfigs(result) {
+		if err = setupHostVeth(hostVethName, ipc); err != nil {
+			return err
+		}
 	}
 
-	if conf.IPMasq {
+	// only IPv4 is masqueraded, there is no NAT for IPv6
+	if conf.IPMasq && result.IP4 != nil {
 		chain := utils.FormatChainName(conf.Name, args.ContainerID)
 		comment := utils.FormatComment(conf.Name, args.ContainerID)
 		if err = ip.SetupIPMasq(&result.IP4.IP, chain, comment); err != nil {
@@ -196,14 +244,16 @@ func cmdAdd(args *skel.CmdArgs) error {
 		}
 	}
 
-	result.DNS = conf.DNS
-	return result.Print()
+	// the interfaces are set up, nothing below needs privileges
+	args.DropCapabilities()
+	result.DNS = types.MergeDNS(conf.DNS, result.DNS)
+	return version.PrintResult(result, conf.CNIVersion)
 }
 
 func cmdDel(args *skel.CmdArgs) error {
 	conf := NetConf{}
 	if err := json.Unmarshal(args.StdinData, &conf); err != nil {
-		return fmt.Errorf("failed to load netconf: %v", err)
+		return types.NewError(types.ErrDecodingFailure, "failed to load netconf", err.Error())
 	}
 
 	if err := ipam.ExecDel(conf.IPAM.Type, args.StdinData); err != nil {
@@ -214,20 +264,20 @@ func cmdDel(args *skel.CmdArgs) error {
 		return nil
 	}
 
-	var ipn *net.IPNet
+	var ipns []*net.IPNet
 	err := ns.WithNetNSPath(args.Netns, func(_ ns.NetNS) error {
 		var err error
-		ipn, err = ip.DelLinkByNameAddr(args.IfName, netlink.FAMILY_V4)
+		ipns, err = ip.DelLinkByNameAddrs(args.IfName, netlink.FAMILY_V4)
 		return err
 	})
 	if err != nil {
 		return err
 	}
 
-	if conf.IPMasq {
+	if conf.IPMasq && len(ipns) > 0 {
 		chain := utils.FormatChainName(conf.Name, args.ContainerID)
 		comment := utils.FormatComment(conf.Name, args.ContainerID)
-		if err = ip.TeardownIPMasq(ipn, chain, comment); err != nil {
+		if err = ip.TeardownIPMasq(ipns[0], chain, comment); err != nil {
 			return err
 		}
 	}
@@ -235,6 +285,7 @@ func cmdDel(args *skel.CmdArgs) error {
 	return nil
 }
 
-func main() {
-	skel.PluginMain(cmdAdd, cmdDel)
+// Main runs the plugin for the command in the environment
+func Main() {
+	skel.PluginMainWithSchema(cmdAdd, cmdDel, schema.ForNetConf(&NetConf{}))
 }
@@ -0,0 +1,251 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This is a "meta-plugin" for testing runtimes. It wraps the plugin in its
+// "delegate" section, or passes on the prevResult of a chain, and injects
+// delays, errors and corrupted results with the configured probabilities.
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/containernetworking/cni/pkg/invoke"
+	"github.com/containernetworking/cni/pkg/schema"
+	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/version"
+)
+
+const defaultErrorCode = 100
+
+// NetConf is the configuration of the chaos plugin
+type NetConf struct {
+	types.NetConf
+	Delegate map[string]interface{} `json:"delegate"`
+
+	// Commands the faults are injected into, all if empty
+	Commands []string `json:"commands"`
+	// Seed of the random number generator, so that a sequence of
+	// invocations can be reproduced. It is mixed with the container,
+	// interface and command of each invocation.
+	Seed *int64 `json:"seed"`
+
+	Delay            string   `json:"delay"`
+	DelayProbability *float64 `json:"delayProbability"`
+
+	ErrorProbability float64 `json:"errorProbability"`
+	ErrorCode        uint    `json:"errorCode"`
+	ErrorMessage     string  `json:"errorMessage"`
+	// FailAfterDelegate injects the error after the delegate succeeded,
+	// leaving behind what it set up for the runtime to roll back
+	FailAfterDelegate bool `json:"failAfterDelegate"`
+
+	CorruptProbability float64 `json:"corruptProbability"`
+
+	delay time.Duration
+}
+
+func loadNetConf(bytes []byte) (*NetConf, error) {
+	n := &NetConf{}
+	if err := json.Unmarshal(bytes, n); err != nil {
+		return nil, types.NewError(types.ErrDecodingFailure, "failed to load netconf", err.Error())
+	}
+
+	if n.Delay != "" {
+		d, err := time.ParseDuration(n.Delay)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf(`invalid "delay" %q`, n.Delay)
+		}
+		n.delay = d
+	}
+	if n.DelayProbability == nil {
+		always := 1.0
+		n.DelayProbability = &always
+	}
+
+	probabilities := []struct {
+		name  string
+		value float64
+	}{
+		{"delayProbability", *n.DelayProbability},
+		{"errorProbability", n.ErrorProbability},
+		{"corruptProbability", n.CorruptProbability},
+	}
+	for _, p := range probabilities {
+		if p.value < 0 || p.value > 1 {
+			return nil, fmt.Errorf("%s must be between 0 and 1, not %v", p.name, p.value)
+		}
+	}
+
+	if n.ErrorCode == 0 {
+		n.ErrorCode = defaultErrorCode
+	}
+	if n.ErrorMessage == "" {
+		n.ErrorMessage = "failure injected by chaos plugin"
+	}
+	return n, nil
+}
+
+// chaos decides which faults to inject into a single invocation
+type chaos struct {
+	conf    *NetConf
+	rand    *rand.Rand
+	enabled bool
+}
+
+func newChaos(n *NetConf, args *skel.CmdArgs, command string) *chaos {
+	seed := time.Now().UnixNano()
+	if n.Seed != nil {
+		seed = invocationSeed(*n.Seed, args, command)
+	}
+
+	enabled := len(n.Commands) == 0
+	for _, c := range n.Commands {
+		if c == command {
+			enabled = true
+		}
+	}
+	return &chaos{conf: n, rand: rand.New(rand.NewSource(seed)), enabled: enabled}
+}
+
+// invocationSeed mixes seed with what tells the invocations of a sequence
+// apart, so that they don't all draw the same numbers
+func invocationSeed(seed int64, args *skel.CmdArgs, command string) int64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d\x00%s\x00%s\x00%s", seed, args.ContainerID, args.IfName, command)
+	return int64(h.Sum64())
+}
+
+func (c *chaos) happens(probability float64) bool {
+	// always draw, so the faults of an invocation only depend on the seed
+	return c.rand.Float64() < probability && c.enabled
+}
+
+func (c *chaos) decide() (delay, fail, corrupt bool) {
+	delay = c.happens(*c.conf.DelayProbability) && c.conf.delay > 0
+	fail = c.happens(c.conf.ErrorProbability)
+	corrupt = c.happens(c.conf.CorruptProbability)
+	return
+}
+
+func (c *chaos) err() error {
+	return &types.Error{Code: c.conf.ErrorCode, Msg: c.conf.ErrorMessage}
+}
+
+func delegateConf(n *NetConf, prevResult *types.Result) ([]byte, error) {
+	if _, ok := n.Delegate["type"].(string); !ok {
+		return nil, fmt.Errorf(`"delegate" must have a "type" field`)
+	}
+	if _, ok := n.Delegate["name"]; !ok {
+		n.Delegate["name"] = n.Name
+	}
+	if _, ok := n.Delegate["cniVersion"]; !ok && n.CNIVersion != "" {
+		n.Delegate["cniVersion"] = n.CNIVersion
+	}
+	if prevResult != nil {
+		n.Delegate["prevResult"] = prevResult
+	}
+	return json.Marshal(n.Delegate)
+}
+
+func cmdAdd(args *skel.CmdArgs) error {
+	n, err := loadNetConf(args.StdinData)
+	if err != nil {
+		return err
+	}
+	c := newChaos(n, args, "ADD")
+	delay, fail, corrupt := c.decide()
+
+	if delay {
+		time.Sleep(n.delay)
+	}
+	if fail && !n.FailAfterDelegate {
+		return c.err()
+	}
+
+	result := n.PrevResult
+	if n.Delegate != nil {
+		netconf, err := delegateConf(n, n.PrevResult)
+		if err != nil {
+			return err
+		}
+		if result, err = invoke.DelegateAdd(n.Delegate["type"].(string), netconf); err != nil {
+			return err
+		}
+	}
+	if result == nil {
+		result = &types.Result{}
+	}
+
+	if fail {
+		return c.err()
+	}
+	if corrupt {
+		return printCorrupted(result)
+	}
+	return version.PrintResult(result, n.CNIVersion)
+}
+
+// printCorrupted prints the first half of result, which a runtime
+// can't parse
+func printCorrupted(result *types.Result) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data[:len(data)/2])
+	return err
+}
+
+func cmdDel(args *skel.CmdArgs) error {
+	n, err := loadNetConf(args.StdinData)
+	if err != nil {
+		return err
+	}
+	c := newChaos(n, args, "DEL")
+	delay, fail, _ := c.decide()
+
+	if delay {
+		time.Sleep(n.delay)
+	}
+	if fail && !n.FailAfterDelegate {
+		return c.err()
+	}
+
+	if n.Delegate != nil {
+		netconf, err := delegateConf(n, nil)
+		if err != nil {
+			return err
+		}
+		if err := invoke.DelegateDel(n.Delegate["type"].(string), netconf); err != nil {
+			return err
+		}
+	}
+
+	if fail {
+		return c.err()
+	}
+	return nil
+}
+
+// Main runs the plugin for the command in the environment
+func Main() {
+	skel.PluginMainWithSchema(cmdAdd, cmdDel, schema.ForNetConf(&NetConf{}))
+}
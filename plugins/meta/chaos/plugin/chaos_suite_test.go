@@ -0,0 +1,46 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+
+	"strings"
+	"testing"
+)
+
+func TestChaos(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Chaos Suite")
+}
+
+var pathToPlugin, pathToTestPlugin string
+
+var _ = SynchronizedBeforeSuite(func() []byte {
+	chaos, err := gexec.Build("github.com/containernetworking/cni/plugins/meta/chaos")
+	Expect(err).NotTo(HaveOccurred())
+	testPlugin, err := gexec.Build("github.com/containernetworking/cni/plugins/test/test-plugin")
+	Expect(err).NotTo(HaveOccurred())
+	return []byte(chaos + "\n" + testPlugin)
+}, func(crossNodeData []byte) {
+	paths := strings.Split(string(crossNodeData), "\n")
+	pathToPlugin, pathToTestPlugin = paths[0], paths[1]
+})
+
+var _ = SynchronizedAfterSuite(func() {}, func() {
+	gexec.CleanupBuildArtifacts()
+})
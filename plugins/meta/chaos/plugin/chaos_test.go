@@ -0,0 +1,186 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/containernetworking/cni/plugins/test/test-plugin/record"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Chaos plugin", func() {
+	var (
+		recordDir string
+		cmd       *exec.Cmd
+	)
+
+	const delegateResult = `{ "ip4": { "ip": "10.1.2.3/24" }, "dns": {} }`
+
+	// printedResult is delegateResult in the version of the network
+	// configuration, which has none
+	const printedResult = `{ "cniVersion": "0.1.0", "ip4": { "ip": "10.1.2.3/24" }, "dns": {} }`
+
+	// setConf wraps test-plugin, which replies with delegateResult, in a
+	// chaos plugin with the given settings
+	setConf := func(settings string) {
+		conf := fmt.Sprintf(`{
+			"name": "mynet",
+			"type": "chaos",
+			"delegate": {
+				"type": "test-plugin",
+				"recordDir": %q,
+				"replies": {"ADD": {"result": %s}}
+			}%s
+		}`, recordDir, delegateResult, settings)
+		cmd.Stdin = strings.NewReader(conf)
+	}
+
+	run := func(exitCode int) *gexec.Session {
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(exitCode))
+		return session
+	}
+
+	delegateInvocations := func() []record.Invocation {
+		invocations, err := record.Read(recordDir)
+		Expect(err).NotTo(HaveOccurred())
+		return invocations
+	}
+
+	BeforeEach(func() {
+		var err error
+		recordDir, err = ioutil.TempDir("", "chaos")
+		Expect(err).NotTo(HaveOccurred())
+
+		cmd = exec.Command(pathToPlugin)
+		cmd.Env = []string{
+			"CNI_COMMAND=ADD",
+			"CNI_CONTAINERID=some-container-id",
+			"CNI_NETNS=/some/netns/path",
+			"CNI_IFNAME=eth0",
+			"CNI_PATH=" + filepath.Dir(pathToTestPlugin),
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(recordDir)).To(Succeed())
+	})
+
+	It("passes the result of the delegate through", func() {
+		setConf("")
+		session := run(0)
+		Expect(session.Out.Contents()).To(MatchJSON(printedResult))
+
+		invocations := delegateInvocations()
+		Expect(invocations).To(HaveLen(1))
+		Expect(invocations[0].Command).To(Equal("ADD"))
+		var delegateConf map[string]interface{}
+		Expect(json.Unmarshal(invocations[0].Stdin, &delegateConf)).To(Succeed())
+		Expect(delegateConf).To(HaveKeyWithValue("name", "mynet"))
+	})
+
+	It("fails with the configured error before invoking the delegate", func() {
+		setConf(`, "errorProbability": 1, "errorCode": 111, "errorMessage": "boom"`)
+		session := run(1)
+		Expect(session.Out.Contents()).To(MatchJSON(`{"code": 111, "msg": "boom"}`))
+		Expect(delegateInvocations()).To(BeEmpty())
+	})
+
+	It("fails after the delegate succeeded if failAfterDelegate is set", func() {
+		setConf(`, "errorProbability": 1, "failAfterDelegate": true`)
+		session := run(1)
+		Expect(session.Out.Contents()).To(MatchJSON(`{"code": 100, "msg": "failure injected by chaos plugin"}`))
+		Expect(delegateInvocations()).To(HaveLen(1))
+	})
+
+	It("corrupts the result", func() {
+		setConf(`, "corruptProbability": 1`)
+		session := run(0)
+		var result interface{}
+		Expect(json.Unmarshal(session.Out.Contents(), &result)).NotTo(Succeed())
+	})
+
+	It("delays the invocation", func() {
+		setConf(`, "delay": "300ms"`)
+		start := time.Now()
+		run(0)
+		Expect(time.Since(start)).To(BeNumerically(">=", 300*time.Millisecond))
+	})
+
+	It("injects faults into the configured commands only", func() {
+		setConf(`, "errorProbability": 1, "commands": ["DEL"]`)
+		run(0)
+
+		cmd = exec.Command(pathToPlugin)
+		cmd.Env = []string{
+			"CNI_COMMAND=DEL",
+			"CNI_CONTAINERID=some-container-id",
+			"CNI_NETNS=/some/netns/path",
+			"CNI_IFNAME=eth0",
+			"CNI_PATH=" + filepath.Dir(pathToTestPlugin),
+		}
+		setConf(`, "errorProbability": 1, "commands": ["DEL"]`)
+		run(1)
+	})
+
+	It("injects the same faults into the same invocations for the same seed", func() {
+		outcomes := func() []int {
+			codes := []int{}
+			for i := 0; i < 16; i++ {
+				cmd = exec.Command(pathToPlugin)
+				cmd.Env = []string{
+					"CNI_COMMAND=ADD",
+					fmt.Sprintf("CNI_CONTAINERID=container-%d", i),
+					"CNI_NETNS=/some/netns/path",
+					"CNI_IFNAME=eth0",
+					"CNI_PATH=" + filepath.Dir(pathToTestPlugin),
+				}
+				setConf(`, "errorProbability": 0.5, "seed": 42`)
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit())
+				codes = append(codes, session.ExitCode())
+			}
+			return codes
+		}
+		codes := outcomes()
+		Expect(codes).To(ContainElement(0))
+		Expect(codes).To(ContainElement(1))
+		Expect(outcomes()).To(Equal(codes))
+	})
+
+	It("passes the prevResult of a chain through without a delegate", func() {
+		cmd.Stdin = strings.NewReader(`{"name": "mynet", "type": "chaos", "prevResult": ` + delegateResult + `}`)
+		session := run(0)
+		Expect(session.Out.Contents()).To(MatchJSON(printedResult))
+	})
+
+	It("rejects invalid probabilities", func() {
+		setConf(`, "errorProbability": 2`)
+		session := run(1)
+		Expect(string(session.Out.Contents())).To(ContainSubstring("errorProbability must be between 0 and 1"))
+	})
+})
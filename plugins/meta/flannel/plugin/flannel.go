@@ -16,7 +16,7 @@
 // the data from flannel generated subnet file and then invokes a plugin
 // like bridge or ipvlan to do the real work.
 
-package main
+package plugin
 
 import (
 	"bufio"
@@ -30,8 +30,10 @@ import (
 	"strings"
 
 	"github.com/containernetworking/cni/pkg/invoke"
+	"github.com/containernetworking/cni/pkg/schema"
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/version"
 )
 
 const (
@@ -75,15 +77,27 @@ func loadFlannelNetConf(bytes []byte) (*NetConf, error) {
 		SubnetFile: defaultSubnetFile,
 	}
 	if err := json.Unmarshal(bytes, n); err != nil {
-		return nil, fmt.Errorf("failed to load netconf: %v", err)
+		return nil, types.NewError(types.ErrDecodingFailure, "failed to load netconf", err.Error())
 	}
 	return n, nil
 }
 
+func invalidNetConf(msg string) error {
+	return types.NewError(types.ErrInvalidNetworkConfig, msg, "")
+}
+
+func invalidSubnetEnv(fn string, err error) error {
+	return types.NewError(types.ErrDecodingFailure, fmt.Sprintf("failed to parse %v", fn), err.Error())
+}
+
 func loadFlannelSubnetEnv(fn string) (*subnetEnv, error) {
 	f, err := os.Open(fn)
-	if err != nil {
-		return nil, err
+	switch {
+	case os.IsNotExist(err):
+		// flanneld writes it once it has acquired a subnet
+		return nil, types.NewError(types.ErrTryAgainLater, fmt.Sprintf("%v does not exist yet", fn), err.Error())
+	case err != nil:
+		return nil, types.NewError(types.ErrIOFailure, fmt.Sprintf("failed to open %v", fn), err.Error())
 	}
 	defer f.Close()
 
@@ -96,19 +110,19 @@ func loadFlannelSubnetEnv(fn string) (*subnetEnv, error) {
 		case "FLANNEL_NETWORK":
 			_, se.nw, err = net.ParseCIDR(parts[1])
 			if err != nil {
-				return nil, err
+				return nil, invalidSubnetEnv(fn, err)
 			}
 
 		case "FLANNEL_SUBNET":
 			_, se.sn, err = net.ParseCIDR(parts[1])
 			if err != nil {
-				return nil, err
+				return nil, invalidSubnetEnv(fn, err)
 			}
 
 		case "FLANNEL_MTU":
 			mtu, err := strconv.ParseUint(parts[1], 10, 32)
 			if err != nil {
-				return nil, err
+				return nil, invalidSubnetEnv(fn, err)
 			}
 			se.mtu = new(uint)
 			*se.mtu = uint(mtu)
@@ -119,11 +133,11 @@ func loadFlannelSubnetEnv(fn string) (*subnetEnv, error) {
 		}
 	}
 	if err := s.Err(); err != nil {
-		return nil, err
+		return nil, types.NewError(types.ErrIOFailure, fmt.Sprintf("failed to read %v", fn), err.Error())
 	}
 
 	if m := se.missing(); m != "" {
-		return nil, fmt.Errorf("%v is missing %v", fn, m)
+		return nil, types.NewError(types.ErrDecodingFailure, fmt.Sprintf("%v is missing %v", fn, m), "")
 	}
 
 	return se, nil
@@ -144,7 +158,7 @@ func consumeScratchNetConf(containerID string) ([]byte, error) {
 	return ioutil.ReadFile(path)
 }
 
-func delegateAdd(cid string, netconf map[string]interface{}) error {
+func delegateAdd(cid, cniVersion string, netconf map[string]interface{}) error {
 	netconfBytes, err := json.Marshal(netconf)
 	if err != nil {
 		return fmt.Errorf("error serializing delegate netconf: %v", err)
@@ -152,7 +166,7 @@ func delegateAdd(cid string, netconf map[string]interface{}) error {
 
 	// save the rendered netconf for cmdDel
 	if err = saveScratchNetConf(cid, netconfBytes); err != nil {
-		return err
+		return types.NewError(types.ErrIOFailure, "failed to save the delegate netconf", err.Error())
 	}
 
 	result, err := invoke.DelegateAdd(netconf["type"].(string), netconfBytes)
@@ -160,7 +174,7 @@ func delegateAdd(cid string, netconf map[string]interface{}) error {
 		return err
 	}
 
-	return result.Print()
+	return version.PrintResult(result, cniVersion)
 }
 
 func hasKey(m map[string]interface{}, k string) bool {
@@ -188,17 +202,20 @@ func cmdAdd(args *skel.CmdArgs) error {
 		n.Delegate = make(map[string]interface{})
 	} else {
 		if hasKey(n.Delegate, "type") && !isString(n.Delegate["type"]) {
-			return fmt.Errorf("'delegate' dictionary, if present, must have (string) 'type' field")
+			return invalidNetConf("'delegate' dictionary, if present, must have (string) 'type' field")
 		}
 		if hasKey(n.Delegate, "name") {
-			return fmt.Errorf("'delegate' dictionary must not have 'name' field, it'll be set by flannel")
+			return invalidNetConf("'delegate' dictionary must not have 'name' field, it'll be set by flannel")
 		}
 		if hasKey(n.Delegate, "ipam") {
-			return fmt.Errorf("'delegate' dictionary must not have 'ipam' field, it'll be set by flannel")
+			return invalidNetConf("'delegate' dictionary must not have 'ipam' field, it'll be set by flannel")
 		}
 	}
 
 	n.Delegate["name"] = n.Name
+	if n.CNIVersion != "" {
+		n.Delegate["cniVersion"] = n.CNIVersion
+	}
 
 	if !hasKey(n.Delegate, "type") {
 		n.Delegate["type"] = "bridge"
@@ -231,23 +248,24 @@ func cmdAdd(args *skel.CmdArgs) error {
 		},
 	}
 
-	return delegateAdd(args.ContainerID, n.Delegate)
+	return delegateAdd(args.ContainerID, n.CNIVersion, n.Delegate)
 }
 
 func cmdDel(args *skel.CmdArgs) error {
 	netconfBytes, err := consumeScratchNetConf(args.ContainerID)
 	if err != nil {
-		return err
+		return types.NewError(types.ErrIOFailure, "failed to read the delegate netconf", err.Error())
 	}
 
 	n := &types.NetConf{}
 	if err = json.Unmarshal(netconfBytes, n); err != nil {
-		return fmt.Errorf("failed to parse netconf: %v", err)
+		return types.NewError(types.ErrDecodingFailure, "failed to parse netconf", err.Error())
 	}
 
 	return invoke.DelegateDel(n.Type, netconfBytes)
 }
 
-func main() {
-	skel.PluginMain(cmdAdd, cmdDel)
+// Main runs the plugin for the command in the environment
+func Main() {
+	skel.PluginMainWithSchema(cmdAdd, cmdDel, schema.ForNetConf(&NetConf{}))
 }
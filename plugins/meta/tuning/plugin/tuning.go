@@ -15,7 +15,7 @@
 // This is a "meta-plugin". It reads in its own netconf, it does not create
 // any network interface but just changes the network sysctl.
 
-package main
+package plugin
 
 import (
 	"encoding/json"
@@ -25,8 +25,10 @@ import (
 	"strings"
 
 	"github.com/containernetworking/cni/pkg/ns"
+	"github.com/containernetworking/cni/pkg/schema"
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/version"
 )
 
 // TuningConf represents the network tuning configuration.
@@ -38,7 +40,7 @@ type TuningConf struct {
 func cmdAdd(args *skel.CmdArgs) error {
 	tuningConf := TuningConf{}
 	if err := json.Unmarshal(args.StdinData, &tuningConf); err != nil {
-		return fmt.Errorf("failed to load netconf: %v", err)
+		return types.NewError(types.ErrDecodingFailure, "failed to load netconf", err.Error())
 	}
 
 	// The directory /proc/sys/net is per network namespace. Enter in the
@@ -52,12 +54,12 @@ func cmdAdd(args *skel.CmdArgs) error {
 			// Refuse to modify sysctl parameters that don't belong
 			// to the network subsystem.
 			if !strings.HasPrefix(fileName, "/proc/sys/net/") {
-				return fmt.Errorf("invalid net sysctl key: %q", key)
+				return types.NewError(types.ErrInvalidNetworkConfig, fmt.Sprintf("invalid net sysctl key: %q", key), "")
 			}
 			content := []byte(value)
 			err := ioutil.WriteFile(fileName, content, 0644)
 			if err != nil {
-				return err
+				return types.NewError(types.ErrIOFailure, fmt.Sprintf("failed to set sysctl %q", key), err.Error())
 			}
 		}
 		return nil
@@ -67,7 +69,7 @@ func cmdAdd(args *skel.CmdArgs) error {
 	}
 
 	result := types.Result{}
-	return result.Print()
+	return version.PrintResult(&result, tuningConf.CNIVersion)
 }
 
 func cmdDel(args *skel.CmdArgs) error {
@@ -77,6 +79,7 @@ func cmdDel(args *skel.CmdArgs) error {
 	return nil
 }
 
-func main() {
-	skel.PluginMain(cmdAdd, cmdDel)
+// Main runs the plugin for the command in the environment
+func Main() {
+	skel.PluginMainWithSchema(cmdAdd, cmdDel, schema.ForNetConf(&TuningConf{}))
 }
@@ -0,0 +1,114 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Test-plugin is a CNI plugin designed for use as a test-double in
+integration tests.
+
+Every invocation is recorded, with its CNI_* environment and stdin, to
+the "recordDir" of the network configuration. The plugin replies with
+the canned "result" or "error" configured for the CNI_COMMAND in
+"replies". Unlike noop it does not use skel, so it records invocations
+with missing or malformed parameters too.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/version"
+	"github.com/containernetworking/cni/plugins/test/test-plugin/record"
+)
+
+// Reply is the canned answer to one CNI_COMMAND
+type Reply struct {
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *types.Error    `json:"error,omitempty"`
+}
+
+type NetConf struct {
+	types.NetConf
+	RecordDir string           `json:"recordDir"`
+	Replies   map[string]Reply `json:"replies"`
+}
+
+func cniEnv() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, "CNI_") {
+			parts := strings.SplitN(kv, "=", 2)
+			env[parts[0]] = parts[1]
+		}
+	}
+	return env
+}
+
+func run() error {
+	stdin, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("error reading from stdin: %v", err)
+	}
+	command := os.Getenv("CNI_COMMAND")
+
+	// VERSION may be called without a network configuration
+	conf := NetConf{}
+	if len(bytes.TrimSpace(stdin)) > 0 {
+		if err := json.Unmarshal(stdin, &conf); err != nil {
+			return fmt.Errorf("failed to load netconf: %v", err)
+		}
+	}
+
+	if conf.RecordDir != "" {
+		inv := &record.Invocation{
+			Command: command,
+			Env:     cniEnv(),
+			Stdin:   stdin,
+		}
+		if err := record.Write(conf.RecordDir, inv); err != nil {
+			return fmt.Errorf("failed to record invocation: %v", err)
+		}
+	}
+
+	reply, ok := conf.Replies[command]
+	switch {
+	case reply.Error != nil:
+		return reply.Error
+	case reply.Result != nil:
+		_, err := os.Stdout.Write(reply.Result)
+		return err
+	case !ok && command == "ADD":
+		fmt.Printf(`{}`)
+	case !ok && command == "VERSION":
+		return version.DefaultPluginVersioner.Encode(os.Stdout)
+	}
+	return nil
+}
+
+func main() {
+	if err := run(); err != nil {
+		e, ok := err.(*types.Error)
+		if !ok {
+			e = &types.Error{Code: types.ErrPlugin, Msg: err.Error()}
+		}
+		e.Print()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// record supports tests that use the test-plugin
+package record
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Invocation is what the test-plugin records about each time it is run
+type Invocation struct {
+	Command string
+	Env     map[string]string
+	Stdin   []byte
+}
+
+// Write stores inv in dir, after all the invocations already stored there
+func Write(dir string, inv *Invocation) error {
+	data, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	// O_EXCL makes concurrent invocations pick distinct sequence numbers
+	for seq := len(files); ; seq++ {
+		name := filepath.Join(dir, fmt.Sprintf("%06d-%s.json", seq, strings.ToLower(inv.Command)))
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+}
+
+// Read returns the invocations stored in dir, oldest first
+func Read(dir string) ([]Invocation, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	invocations := []Invocation{}
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		inv := Invocation{}
+		if err := json.Unmarshal(data, &inv); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %v", f, err)
+		}
+		invocations = append(invocations, inv)
+	}
+	return invocations, nil
+}
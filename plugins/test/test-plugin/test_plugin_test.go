@@ -0,0 +1,126 @@
+// Copyright 2016 CNI authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/containernetworking/cni/plugins/test/test-plugin/record"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Test plugin", func() {
+	var (
+		recordDir string
+		cmd       *exec.Cmd
+	)
+
+	const reportResult = `{ "ip4": { "ip": "10.1.2.3/24" }, "dns": {} }`
+
+	setConf := func(replies string) string {
+		conf := fmt.Sprintf(`{"name": "t", "type": "test-plugin", "recordDir": %q, "replies": %s}`, recordDir, replies)
+		cmd.Stdin = strings.NewReader(conf)
+		return conf
+	}
+
+	run := func(exitCode int) *gexec.Session {
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit(exitCode))
+		return session
+	}
+
+	BeforeEach(func() {
+		var err error
+		recordDir, err = ioutil.TempDir("", "test-plugin")
+		Expect(err).NotTo(HaveOccurred())
+
+		cmd = exec.Command(pathToPlugin)
+		cmd.Env = []string{
+			"CNI_COMMAND=ADD",
+			"CNI_CONTAINERID=some-container-id",
+			"CNI_NETNS=/some/netns/path",
+			"CNI_IFNAME=some-eth0",
+			"CNI_PATH=/some/bin/path",
+			"OTHER=ignored",
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(recordDir)).To(Succeed())
+	})
+
+	It("replies with the configured result", func() {
+		setConf(`{"ADD": {"result": ` + reportResult + `}}`)
+		session := run(0)
+		Expect(session.Out.Contents()).To(MatchJSON(reportResult))
+	})
+
+	It("records every invocation", func() {
+		conf := setConf(`{}`)
+		run(0)
+
+		cmd = exec.Command(pathToPlugin)
+		cmd.Env = []string{"CNI_COMMAND=DEL", "CNI_CONTAINERID=some-container-id"}
+		setConf(`{}`)
+		run(0)
+
+		invocations, err := record.Read(recordDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(invocations).To(HaveLen(2))
+
+		Expect(invocations[0].Command).To(Equal("ADD"))
+		Expect(invocations[0].Env).To(Equal(map[string]string{
+			"CNI_COMMAND":     "ADD",
+			"CNI_CONTAINERID": "some-container-id",
+			"CNI_NETNS":       "/some/netns/path",
+			"CNI_IFNAME":      "some-eth0",
+			"CNI_PATH":        "/some/bin/path",
+		}))
+		Expect(string(invocations[0].Stdin)).To(Equal(conf))
+
+		Expect(invocations[1].Command).To(Equal("DEL"))
+		Expect(invocations[1].Env).To(HaveKeyWithValue("CNI_CONTAINERID", "some-container-id"))
+	})
+
+	It("replies with the configured error", func() {
+		setConf(`{"DEL": {"error": {"code": 7, "msg": "banana", "details": "peel"}}}`)
+		cmd.Env[0] = "CNI_COMMAND=DEL"
+		session := run(1)
+		Expect(session.Out.Contents()).To(MatchJSON(`{"code": 7, "msg": "banana", "details": "peel"}`))
+
+		invocations, err := record.Read(recordDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(invocations).To(HaveLen(1))
+	})
+
+	It("reports its version without a configuration", func() {
+		cmd.Env = []string{"CNI_COMMAND=VERSION"}
+		session := run(0)
+		Expect(session.Out.Contents()).To(MatchJSON(`{"cniVersion": "0.2.0"}`))
+	})
+
+	It("fails on a malformed configuration", func() {
+		cmd.Stdin = strings.NewReader(`{"name": `)
+		session := run(1)
+		Expect(string(session.Out.Contents())).To(ContainSubstring("failed to load netconf"))
+	})
+})